@@ -50,6 +50,9 @@ var signalChannel chan os.Signal
 var sourceDatabaseConnectionsMap map[string]map[string]interface{}
 var tfmContextMap = make(map[string]*TrcFlowMachineContext, 5)
 
+// flowShutdownTimeout - how long a stopping flow has to persist its remaining changes to vault.
+const flowShutdownTimeout = 30 * time.Second
+
 const (
 	TableSyncFlow FlowType = iota
 	TableEnrichFlow
@@ -434,7 +437,10 @@ func (tfmContext *TrcFlowMachineContext) seedVaultCycle(tfContext *TrcFlowContex
 			eUtils.LogErrorMessage(&tfmContext.DriverConfig.CoreConfig, "Receiving shutdown presumably from vault.", true)
 			os.Exit(0)
 		case <-flowChangedChannel.Ch:
+			// Cancelling the flow abandons vault requests in flight.  Changes they fail to
+			// persist are put back in the change table and persisted on shutdown below.
 			tfmContext.vaultPersistPushRemoteChanges(
+				tfContext.Context,
 				tfContext,
 				identityColumnName,
 				indexColumnNames,
@@ -444,13 +450,16 @@ func (tfmContext *TrcFlowMachineContext) seedVaultCycle(tfContext *TrcFlowContex
 			flowChangedChannel.Clear()
 		case <-tfContext.Context.Done():
 			tfmContext.Log(fmt.Sprintf("Flow shutdown: %s", tfContext.Flow), nil)
+			shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), flowShutdownTimeout)
 			tfmContext.vaultPersistPushRemoteChanges(
+				shutdownCtx,
 				tfContext,
 				identityColumnName,
 				indexColumnNames,
 				mysqlPushEnabled,
 				getIndexedPathExt,
 				flowPushRemote)
+			cancelShutdown()
 			if tfContext.Restart {
 				tfmContext.Log(fmt.Sprintf("Restarting flow: %s", tfContext.Flow), nil)
 				// Reload table from vault...
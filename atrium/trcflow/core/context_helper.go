@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -52,6 +53,13 @@ func getCompositeChangeIdQuery(databaseName string, changeTable string, indexCol
 	return fmt.Sprintf("SELECT %s, %s FROM %s.%s", indexColumnNames.([]string)[0], indexColumnNames.([]string)[1], databaseName, changeTable)
 }
 
+func getCompositeInsertChangeQuery(databaseName string, changeTable string, indexColVal interface{}, secIndexColVal interface{}) string {
+	if first, second := indexColVal.(string), secIndexColVal.(string); first != "" && second != "" {
+		return fmt.Sprintf("INSERT IGNORE INTO %s.%s VALUES ('%s', '%s', current_timestamp())", databaseName, changeTable, indexColVal, secIndexColVal)
+	}
+	return ""
+}
+
 func getCompositeDeleteChangeQuery(databaseName string, changeTable string, indexColumnNames interface{}, indexColumnValues interface{}) string {
 	if first, second, third, fourth := indexColumnNames.([]string)[0], indexColumnValues.([]string)[0], indexColumnNames.([]string)[1], indexColumnValues.([]string)[1]; first != "" && second != "" && third != "" && fourth != "" {
 		return fmt.Sprintf("DELETE FROM %s.%s WHERE %s='%s' AND %s='%s'", databaseName, changeTable, indexColumnNames.([]string)[0], indexColumnValues.([]string)[0], indexColumnNames.([]string)[1], indexColumnValues.([]string)[1])
@@ -231,8 +239,31 @@ func (tfmContext *TrcFlowMachineContext) removeStatisticChangedTableEntries(tfCo
 	return matrixChangedEntries, nil
 }
 
+// requeueChange - puts a changed entry removed from the change table back so a later cycle persists it.
+func (tfmContext *TrcFlowMachineContext) requeueChange(tfContext *TrcFlowContext, changedEntry []interface{}) {
+	var insertChangeQuery string
+	switch len(changedEntry) {
+	case 1:
+		insertChangeQuery = getInsertChangeQuery(tfContext.FlowSourceAlias, tfContext.ChangeFlowName, changedEntry[0])
+	case 2:
+		insertChangeQuery = getCompositeInsertChangeQuery(tfContext.FlowSourceAlias, tfContext.ChangeFlowName, changedEntry[0], changedEntry[1])
+	case 3:
+		insertChangeQuery = getStatisticInsertChangeQuery(tfContext.FlowSourceAlias, tfContext.ChangeFlowName, changedEntry[0], changedEntry[1], changedEntry[2])
+	}
+	if insertChangeQuery == "" {
+		eUtils.LogErrorObject(&tfmContext.DriverConfig.CoreConfig, fmt.Errorf("unable to requeue change for %s: %v", tfContext.Flow.TableName(), changedEntry), false)
+		return
+	}
+	_, _, _, err := trcdb.Query(tfmContext.TierceronEngine, insertChangeQuery, tfContext.FlowLock)
+	if err != nil {
+		eUtils.LogErrorObject(&tfmContext.DriverConfig.CoreConfig, err, false)
+	}
+}
+
 // vaultPersistPushRemoteChanges - Persists any local mysql changes to vault and pushed any changes to a remote data source.
+// Changes not persisted before ctx is done are put back in the change table for a later cycle.
 func (tfmContext *TrcFlowMachineContext) vaultPersistPushRemoteChanges(
+	ctx context.Context,
 	tfContext *TrcFlowContext,
 	identityColumnName string,
 	indexColumnNames interface{},
@@ -267,8 +298,12 @@ func (tfmContext *TrcFlowMachineContext) vaultPersistPushRemoteChanges(
 	}
 
 	for _, changedEntry := range matrixChangedEntries {
+		if ctx.Err() != nil {
+			tfmContext.requeueChange(tfContext, changedEntry)
+			continue
+		}
 		var changedTableQuery string
-		var changedId interface{}
+		var changedId interface{} = changedEntry[0]
 		var changeTableError error
 		changedTableQuery, changeTableError = getStatisticChangedByIdQuery(tfContext.FlowSourceAlias, tfContext.Flow.TableName(), identityColumnName, indexColumnNames, changedEntry)
 		if changeTableError != nil {
@@ -335,9 +370,10 @@ func (tfmContext *TrcFlowMachineContext) vaultPersistPushRemoteChanges(
 					}
 				}
 
-				deleteMap, deleteErr := tfContext.GoMod.SoftDelete(indexPath, tfContext.Log)
+				deleteMap, deleteErr := tfContext.GoMod.SoftDeleteWithContext(ctx, indexPath, tfContext.Log)
 				if deleteErr != nil || deleteMap != nil {
 					eUtils.LogErrorObject(&tfmContext.DriverConfig.CoreConfig, errors.New("Unable to process a delete query for "+tfContext.Flow.TableName()), false)
+					tfmContext.requeueChange(tfContext, changedEntry)
 				}
 			}
 			continue
@@ -389,14 +425,11 @@ func (tfmContext *TrcFlowMachineContext) vaultPersistPushRemoteChanges(
 		}
 
 		if !tfContext.ReadOnly {
-			seedError := trcvutils.SeedVaultById(tfmContext.DriverConfig, tfContext.GoMod, tfContext.Flow.ServiceName(), tfmContext.DriverConfig.VaultAddress, tfContext.Vault.GetToken(), tfContext.FlowData.(*extract.TemplateResultData), rowDataMap, indexPath, tfContext.FlowSource)
+			seedError := trcvutils.SeedVaultByIdWithContext(ctx, tfmContext.DriverConfig, tfContext.GoMod, tfContext.Flow.ServiceName(), tfmContext.DriverConfig.VaultAddress, tfContext.Vault.GetToken(), tfContext.FlowData.(*extract.TemplateResultData), rowDataMap, indexPath, tfContext.FlowSource)
 			if seedError != nil {
 				eUtils.LogErrorObject(&tfmContext.DriverConfig.CoreConfig, seedError, false)
 				// Re-inject into changes because it might not be here yet...
-				_, _, _, err = trcdb.Query(tfmContext.TierceronEngine, getInsertChangeQuery(tfContext.FlowSourceAlias, tfContext.ChangeFlowName, changedId.(string)), tfContext.FlowLock)
-				if err != nil {
-					eUtils.LogErrorObject(&tfmContext.DriverConfig.CoreConfig, err, false)
-				}
+				tfmContext.requeueChange(tfContext, changedEntry)
 				continue
 			}
 		}
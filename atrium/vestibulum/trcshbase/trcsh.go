@@ -265,7 +265,9 @@ func CommonMain(envPtr *string, addrPtr *string, envCtxPtr *string,
 		}
 		regionPtr = flagset.String("region", "", "Region to be processed")  //If this is blank -> use context otherwise override context.
 		trcPathPtr = flagset.String("c", "", "Optional script to execute.") //If this is blank -> use context otherwise override context.
+		eUtils.InitVaultFlags(flagset)
 		flagset.Parse(argLines[1:])
+		eUtils.CheckVaultFlags()
 
 		if len(*appRoleIDPtr) == 0 {
 			*appRoleIDPtr = os.Getenv("DEPLOY_ROLE")
@@ -298,7 +300,9 @@ func CommonMain(envPtr *string, addrPtr *string, envCtxPtr *string,
 
 		regionPtr = flagset.String("region", "", "Region to be processed")  //If this is blank -> use context otherwise override context.
 		trcPathPtr = flagset.String("c", "", "Optional script to execute.") //If this is blank -> use context otherwise override context.
+		eUtils.InitVaultFlags(flagset)
 		flagset.Parse(argLines[1:])
+		eUtils.CheckVaultFlags()
 
 		if len(deploymentsShard) == 0 {
 			fmt.Println("trcsh on windows requires a DEPLOYMENTS.")
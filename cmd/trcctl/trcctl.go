@@ -17,9 +17,11 @@ import (
 	"github.com/trimble-oss/tierceron/pkg/cli/trcconfigbase"
 	trcinitbase "github.com/trimble-oss/tierceron/pkg/cli/trcinitbase"
 	"github.com/trimble-oss/tierceron/pkg/cli/trcpubbase"
+	"github.com/trimble-oss/tierceron/pkg/cli/trcrollbackbase"
 	"github.com/trimble-oss/tierceron/pkg/cli/trcsubbase"
 	"github.com/trimble-oss/tierceron/pkg/cli/trcxbase"
 	"github.com/trimble-oss/tierceron/pkg/trcx/xutil"
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
 )
 
 const configDir = "/.tierceron/config.yml"
@@ -48,6 +50,7 @@ func main() {
 	appRoleIDPtr := flagset.String("appRoleID", "", "Public app role ID")
 	tokenNamePtr := flagset.String("tokenName", "", "Token name used by this"+coreopts.BuildOptions.GetFolderPrefix(nil)+"config to access the vault")
 	flagset.Bool("diff", false, "Diff files")
+	eUtils.InitVaultFlags(flagset)
 	var envContext string
 
 	var ctl string
@@ -63,11 +66,16 @@ func main() {
 			os.Args = os.Args[1:]
 		}
 	}
+	var rollbackFlags *trcrollbackbase.RollbackFlags
+	if ctl == "rollback" {
+		rollbackFlags = trcrollbackbase.NewRollbackFlags(flagset)
+	}
 	flagset.Parse(os.Args[1:])
 	if flagset.NFlag() == 0 {
 		flagset.Usage()
 		os.Exit(0)
 	}
+	eUtils.CheckVaultFlags()
 
 	if ctl != "" {
 		var err error
@@ -108,9 +116,14 @@ func main() {
 		case "init":
 			trcinitbase.CommonMain(envPtr, &addrPtr, &envContext, flagset, os.Args)
 		case "config":
-			trcconfigbase.CommonMain(envPtr, &addrPtr, tokenPtr, &envContext, secretIDPtr, appRoleIDPtr, tokenNamePtr, nil, nil, os.Args, nil)
+			err = trcconfigbase.CommonMain(envPtr, &addrPtr, tokenPtr, &envContext, secretIDPtr, appRoleIDPtr, tokenNamePtr, nil, nil, os.Args, nil)
 		case "x":
 			trcxbase.CommonMain(nil, xutil.GenerateSeedsFromVault, envPtr, &addrPtr, &envContext, nil, nil, os.Args)
+		case "rollback":
+			err = trcrollbackbase.CommonMain(envPtr, &addrPtr, tokenPtr, &envContext, secretIDPtr, appRoleIDPtr, tokenNamePtr, rollbackFlags)
+		}
+		if err != nil {
+			os.Exit(1)
 		}
 	}
 }
@@ -37,6 +37,7 @@ require (
 	github.com/graphql-go/graphql v0.8.1-0.20220614210743-09272f350067
 	github.com/trimble-oss/tierceron-hat v1.1.1
 	github.com/trimble-oss/tierceron/atrium v0.0.0-20240326213127-e85d6193e1c6
+	software.sslmate.com/src/go-pkcs12 v0.4.0
 )
 
 require (
@@ -2,8 +2,8 @@ package capauth
 
 type TrcShConfig struct {
 	Env          string
-	EnvContext   string // Current env context...
-	VaultAddress *string
+	EnvContext   string  // Current env context...
+	VaultAddress *string // One vault node, or an HA cluster's nodes comma separated.
 	CToken       *string
 	ConfigRole   *string
 	PubRole      *string
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,10 +19,38 @@ import (
 	"github.com/trimble-oss/tierceron/pkg/core"
 	"github.com/trimble-oss/tierceron/pkg/utils"
 	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
 
 	"github.com/google/go-cmp/cmp"
 )
 
+// asOfVersion labels the -asOf side of a -diff.
+const asOfVersion = "asOf"
+
+// rollbackFlag - generations to roll back.  -rollback alone rolls back one generation.
+type rollbackFlag int
+
+func (r *rollbackFlag) String() string {
+	return strconv.Itoa(int(*r))
+}
+
+func (r *rollbackFlag) Set(value string) error {
+	if value == "true" {
+		*r = 1
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return errors.New("rollback expects a positive number of generations")
+	}
+	*r = rollbackFlag(n)
+	return nil
+}
+
+func (r *rollbackFlag) IsBoolFlag() bool {
+	return true
+}
+
 func messenger(configCtx *utils.ConfigContext, inData *string, inPath string) {
 	var data utils.ResultData
 	data.InData = inData
@@ -118,16 +147,35 @@ func CommonMain(envPtr *string,
 	servicesWanted := flagset.String("servicesWanted", "", "Services to pull template values for, in the form 'service1,service2' (defaults to all services)")
 	wantCertsPtr := flagset.Bool("certs", false, "Pull certificates into directory specified by endDirPtr")
 	certDestPathPtr := flagset.String("certDestPath", "", "Override templated cert destination paths")
-	keyStorePtr := flagset.String("keystore", "", "Put certificates into this keystore file.")
+	certReportPtr := flagset.Bool("certReport", false, "With -certs, report subject, SANs, issuer, key match, chain and expiry of each certificate instead of writing them")
+	certExpiryDaysPtr := flagset.Int("certExpiryDays", 30, "With -certReport, fail on certificates expiring within this many days")
+	keyStorePtr := flagset.String("keystore", "", "Put certificates into this keystore file (.jks, or .p12/.pfx for PKCS#12).")
 	logFilePtr := flagset.String("log", "./"+coreopts.BuildOptions.GetFolderPrefix(nil)+"config.log", "Output path for log file")
 	pingPtr := flagset.Bool("ping", false, "Ping vault.")
 	zcPtr := flagset.Bool("zc", false, "Zero config (no configuration option).")
 	diffPtr := flagset.Bool("diff", false, "Diff files")
 	fileFilterPtr := flagset.String("filter", "", "Filter files for diff")
+	diffFormatPtr := flagset.String("diffFormat", eUtils.DiffFormatText, "Diff output format: text or json")
+	diffAllowPtr := flagset.String("diffAllow", "", "With -diffFormat=json, fail when keys other than these differ, in the form 'key1,key2'")
+	semanticDiffPtr := flagset.Bool("semanticDiff", false, "Diff yml, json, properties, ini and xml by key instead of by line, masking super-secret values")
 	templateInfoPtr := flagset.Bool("templateInfo", false, "Version information about templates")
 	versionInfoPtr := flagset.Bool("versions", false, "Version information about values")
 	insecurePtr := flagset.Bool("insecure", false, "By default, every ssl connection this tool makes is verified secure.  This option allows to tool to continue with server connections considered insecure.")
 	noVaultPtr := flagset.Bool("novault", false, "Don't pull configuration data from vault.")
+	strictPtr := flagset.Bool("strict", false, "Fail with a report of every template key that has no value.")
+	formatPtr := flagset.String("format", "", "Emit one manifest per service instead of files: k8s-secret or k8s-configmap")
+	exportPtr := flagset.String("export", "", "Print resolved values instead of configuring templates: env, json or shell")
+	projectServicePtr := flagset.String("projectService", "", "Project/Service to export values for")
+	exportTemplatePtr := flagset.String("exportTemplate", "", "Restrict export to values for a single template file")
+	watchPtr := flagset.Duration("watch", 0, "Keep running, reconfiguring templates when their vault values change.  Polls at this interval (e.g. 30s).")
+	onChangePtr := flagset.String("onChange", "", "Command to run after -watch reconfigures templates")
+	asOfPtr := flagset.String("asOf", "", "Configure from values and templates as of this instant, e.g. 2026-10-01T12:00:00Z.  With -diff compares now against then.")
+	previewPtr := flagset.Bool("preview", false, "Print configured templates with every super-secret value masked, or write them to -endDir when provided")
+	regionsPtr := flagset.String("regions", "", "Configure templates once per region into <endDir>/<region>, in the form 'all' or 'region1,region2'")
+	historyPtr := flagset.Int("history", 0, "Generations of configured output to keep under <endDir>/.trc_history for -rollback.  Copies include configured secrets.  0 keeps none.")
+	var rollback rollbackFlag
+	flagset.Var(&rollback, "rollback", "Restore the configured output from N generations ago (default 1)")
+	eUtils.InitVaultFlags(flagset)
 
 	isShell := false
 
@@ -145,16 +193,28 @@ func CommonMain(envPtr *string,
 			}
 		}
 		flagset.Parse(argLines[1:])
+		eUtils.CheckVaultFlags()
 	} else {
 		// TODO: rework to support standard arg parsing...
 		for _, args := range argLines {
 			if args == "-certs" {
 				driverConfig.CoreConfig.WantCerts = true
+			} else if args == "-certReport" {
+				*certReportPtr = true
 			} else if strings.HasPrefix(args, "-keystore") {
 				storeArgs := strings.Split(args, "=")
 				if len(storeArgs) > 1 {
 					*keyStorePtr = storeArgs[1]
 				}
+			} else if args == "-strict" {
+				*strictPtr = true
+			} else if args == "-preview" {
+				*previewPtr = true
+			} else if strings.HasPrefix(args, "-format") {
+				formatArgs := strings.Split(args, "=")
+				if len(formatArgs) > 1 {
+					*formatPtr = formatArgs[1]
+				}
 			} else if strings.HasPrefix(args, "-endDir") {
 				endDir := strings.Split(args, "=")
 				if len(endDir) > 1 {
@@ -167,7 +227,7 @@ func CommonMain(envPtr *string,
 			*wantCertsPtr = true
 		}
 	}
-	if !isShell {
+	if !isShell && *exportPtr == "" && rollback == 0 {
 		if _, err := os.Stat(*startDirPtr); os.IsNotExist(err) {
 			fmt.Println("Missing required template folder: " + *startDirPtr)
 			return fmt.Errorf("missing required template folder: %s", *startDirPtr)
@@ -178,6 +238,16 @@ func CommonMain(envPtr *string,
 		*wantCertsPtr = false
 	}
 
+	var asOf time.Time
+	if *asOfPtr != "" {
+		var asOfErr error
+		asOf, asOfErr = time.Parse(time.RFC3339, *asOfPtr)
+		if asOfErr != nil {
+			fmt.Println("Incorrect format for asOf: " + *asOfPtr + " - use -asOf=2026-10-01T12:00:00Z")
+			return fmt.Errorf("incorrect format for asOf: %s", *asOfPtr)
+		}
+	}
+
 	if strings.Contains(*envPtr, "*") {
 		fmt.Println("* is not available as an environment suffix.")
 		return errors.New("* is not available as an environment suffix")
@@ -214,6 +284,15 @@ func CommonMain(envPtr *string,
 		eUtils.CheckError(&driverConfigBase.CoreConfig, err, true)
 	}
 
+	if rollback > 0 {
+		rollbackConfig := &eUtils.DriverConfig{
+			CoreConfig:   driverConfigBase.CoreConfig,
+			EndDir:       driverConfigBase.EndDir,
+			HistoryLimit: *historyPtr,
+		}
+		return vcutils.RollbackConfigs(rollbackConfig, int(rollback))
+	}
+
 	//Dont allow these combinations of flags
 	if *templateInfoPtr && *diffPtr {
 		fmt.Println("Cannot use -diff flag and -templateInfo flag together")
@@ -227,10 +306,81 @@ func CommonMain(envPtr *string,
 	} else if *certDestPathPtr != "" && !*wantCertsPtr {
 		fmt.Println("Cannot use -certDestPath flag without including -certs flag")
 		return errors.New("Cannot use -certDestPath flag without including -certs flag")
+	} else if *certReportPtr && !*wantCertsPtr {
+		fmt.Println("Cannot use -certReport flag without including -certs flag")
+		return errors.New("cannot use -certReport flag without including -certs flag")
+	} else if *certReportPtr && (*keyStorePtr != "" || *templateInfoPtr || *versionInfoPtr || *noVaultPtr) {
+		fmt.Println("Cannot use -certReport flag with -keystore, -templateInfo, -versions or -novault")
+		return errors.New("cannot use -certReport flag with -keystore, -templateInfo, -versions or -novault")
 	} else if *versionInfoPtr && *templateInfoPtr {
 		fmt.Println("Cannot use -templateInfo flag and -versionInfo flag together")
 		return errors.New("cannot use -templateInfo flag and -versionInfo flag together")
+	} else if *formatPtr != "" && !vcutils.IsManifestFormat(*formatPtr) {
+		fmt.Println("Unsupported format: " + *formatPtr + " - use k8s-secret or k8s-configmap")
+		return fmt.Errorf("unsupported format: %s", *formatPtr)
+	} else if *formatPtr != "" && (*diffPtr || *wantCertsPtr || *templateInfoPtr || *versionInfoPtr) {
+		fmt.Println("Cannot use -format flag with -diff, -certs, -templateInfo or -versions")
+		return errors.New("cannot use -format flag with -diff, -certs, -templateInfo or -versions")
+	} else if *exportPtr != "" && !vcutils.IsExportFormat(*exportPtr) {
+		fmt.Println("Unsupported export: " + *exportPtr + " - use env, json or shell")
+		return fmt.Errorf("unsupported export: %s", *exportPtr)
+	} else if *exportPtr != "" && (*diffPtr || *wantCertsPtr || *templateInfoPtr || *versionInfoPtr || *formatPtr != "" || *noVaultPtr) {
+		fmt.Println("Cannot use -export flag with -diff, -certs, -templateInfo, -versions, -format or -novault")
+		return errors.New("cannot use -export flag with -diff, -certs, -templateInfo, -versions, -format or -novault")
+	} else if *exportPtr != "" && len(strings.Split(*projectServicePtr, "/")) != 2 {
+		fmt.Println("Export requires -projectService=Project/Service")
+		return errors.New("export requires -projectService=Project/Service")
+	} else if *watchPtr > 0 && (isShell || *diffPtr || *wantCertsPtr || *templateInfoPtr || *versionInfoPtr || *formatPtr != "" || *exportPtr != "" || *noVaultPtr) {
+		fmt.Println("Cannot use -watch flag with -diff, -certs, -templateInfo, -versions, -format, -export or -novault")
+		return errors.New("cannot use -watch flag with -diff, -certs, -templateInfo, -versions, -format, -export or -novault")
+	} else if *watchPtr > 0 && strings.Contains(*envPtr, "_") && !strings.HasSuffix(*envPtr, "_0") {
+		fmt.Println("Cannot use -watch flag with a specific version: -env=env1_ver1")
+		return errors.New("cannot use -watch flag with a specific version")
+	} else if !asOf.IsZero() && (*templateInfoPtr || *versionInfoPtr || *watchPtr > 0) {
+		fmt.Println("Cannot use -asOf flag with -templateInfo, -versions or -watch")
+		return errors.New("cannot use -asOf flag with -templateInfo, -versions or -watch")
+	} else if !asOf.IsZero() && strings.ContainsAny(*envPtr, ",_") {
+		fmt.Println("Cannot use -asOf flag with a version or multiple environments: -env=env1")
+		return errors.New("cannot use -asOf flag with a version or multiple environments")
+	} else if *diffFormatPtr != eUtils.DiffFormatText && *diffFormatPtr != eUtils.DiffFormatJson {
+		fmt.Println("Unsupported diffFormat: " + *diffFormatPtr + " - use text or json")
+		return fmt.Errorf("unsupported diffFormat: %s", *diffFormatPtr)
+	} else if *diffFormatPtr == eUtils.DiffFormatJson && !*diffPtr {
+		fmt.Println("Cannot use -diffFormat flag without -diff")
+		return errors.New("cannot use -diffFormat flag without -diff")
+	} else if *semanticDiffPtr && !*diffPtr {
+		fmt.Println("Cannot use -semanticDiff flag without -diff")
+		return errors.New("cannot use -semanticDiff flag without -diff")
+	} else if *diffAllowPtr != "" && *diffFormatPtr != eUtils.DiffFormatJson {
+		fmt.Println("Cannot use -diffAllow flag without -diffFormat=json")
+		return errors.New("cannot use -diffAllow flag without -diffFormat=json")
+	} else if *regionsPtr != "" && regionPtr != nil && *regionPtr != "" {
+		fmt.Println("Cannot use -regions flag and -region flag together")
+		return errors.New("cannot use -regions flag and -region flag together")
+	} else if *regionsPtr != "" && (*diffPtr || *wantCertsPtr || *templateInfoPtr || *versionInfoPtr || *formatPtr != "" || *exportPtr != "" || *watchPtr > 0) {
+		fmt.Println("Cannot use -regions flag with -diff, -certs, -templateInfo, -versions, -format, -export or -watch")
+		return errors.New("cannot use -regions flag with -diff, -certs, -templateInfo, -versions, -format, -export or -watch")
+	} else if *previewPtr && (*diffPtr || *templateInfoPtr || *versionInfoPtr || *formatPtr != "" || *exportPtr != "" || *watchPtr > 0 || *certReportPtr || *regionsPtr != "") {
+		fmt.Println("Cannot use -preview flag with -diff, -templateInfo, -versions, -format, -export, -watch, -certReport or -regions")
+		return errors.New("cannot use -preview flag with -diff, -templateInfo, -versions, -format, -export, -watch, -certReport or -regions")
+	} else if *onChangePtr != "" && *watchPtr <= 0 {
+		fmt.Println("Cannot use -onChange flag without -watch")
+		return errors.New("cannot use -onChange flag without -watch")
 	} else if *diffPtr {
+		configCtx.DiffFormat = *diffFormatPtr
+		if *semanticDiffPtr {
+			configCtx.SemanticDiff = true
+		}
+		if *semanticDiffPtr || *diffFormatPtr == eUtils.DiffFormatJson {
+			// Both print values rather than lines alone, so super-secrets are masked.
+			configCtx.DiffSecrets = &eUtils.DiffSecrets{}
+		}
+		if *diffAllowPtr != "" {
+			configCtx.DiffAllow = strings.Split(*diffAllowPtr, ",")
+		}
+		if !asOf.IsZero() { //Now vs asOf
+			*envPtr = *envPtr + "," + *envPtr + "_" + asOfVersion
+		}
 		if strings.ContainsAny(*envPtr, ",") { //Multiple environments
 			*envPtr = strings.ReplaceAll(*envPtr, "latest", "0")
 			configCtx.EnvSlice = strings.Split(*envPtr, ",")
@@ -355,6 +505,37 @@ func CommonMain(envPtr *string,
 		}
 	}
 
+	var renderRegions []string
+	var regionVariances *eUtils.RegionVarianceReport
+	if *regionsPtr != "" {
+		if !strings.HasPrefix(*envPtr, "staging") && !strings.HasPrefix(*envPtr, "prod") && !strings.HasPrefix(*envPtr, "dev") {
+			fmt.Println("Cannot use -regions flag with env: " + *envPtr)
+			return fmt.Errorf("cannot use -regions flag with env: %s", *envPtr)
+		}
+		supportedRegions := eUtils.GetSupportedProdRegions()
+		if *regionsPtr == "all" {
+			renderRegions = supportedRegions
+		} else {
+			for _, region := range strings.Split(*regionsPtr, ",") {
+				supported := false
+				for _, supportedRegion := range supportedRegions {
+					if region == supportedRegion {
+						supported = true
+						break
+					}
+				}
+				if !supported {
+					fmt.Println("Unsupported region: " + region)
+					return fmt.Errorf("unsupported region: %s", region)
+				}
+				renderRegions = append(renderRegions, region)
+			}
+		}
+		// Region specific values for every region are loaded together.
+		regions = renderRegions
+		regionVariances = &eUtils.RegionVarianceReport{}
+	}
+
 	fileFilterSlice := make([]string, strings.Count(*fileFilterPtr, ",")+1)
 	if strings.ContainsAny(*fileFilterPtr, ",") {
 		fileFilterSlice = strings.Split(*fileFilterPtr, ",")
@@ -379,6 +560,36 @@ func CommonMain(envPtr *string,
 		}
 	}
 
+	var unresolvedKeys *eUtils.UnresolvedKeyReport
+	if *strictPtr {
+		unresolvedKeys = &eUtils.UnresolvedKeyReport{}
+	}
+
+	var certReport *eUtils.CertReport
+	if *certReportPtr {
+		certReport = &eUtils.CertReport{ExpiryWindow: *certExpiryDaysPtr}
+	}
+
+	if *exportPtr != "" {
+		exportConfig := &eUtils.DriverConfig{
+			CoreConfig: core.CoreConfig{
+				ExitOnFailure: driverConfigBase.CoreConfig.ExitOnFailure,
+				Log:           driverConfigBase.CoreConfig.Log,
+			},
+			Insecure:     *insecurePtr,
+			Token:        *tokenPtr,
+			VaultAddress: *addrPtr,
+			Env:          *envPtr,
+			EnvRaw:       eUtils.GetRawEnv(*envPtr),
+			Regions:      regions,
+			SecretMode:   *secretMode,
+			AsOf:         asOf,
+		}
+		return exportValues(exportConfig, *exportPtr, *projectServicePtr, *exportTemplatePtr)
+	}
+
+	var watchConfig *eUtils.DriverConfig
+
 	//channel receiver
 	go receiver(configCtx)
 	if *diffPtr {
@@ -440,6 +651,15 @@ func CommonMain(envPtr *string,
 				Diff:              *diffPtr,
 				Update:            messenger,
 				FileFilter:        fileFilterSlice,
+				Strict:            *strictPtr,
+				UnresolvedKeys:    unresolvedKeys,
+				DiffSecrets:       configCtx.DiffSecrets,
+			}
+			if envVersion[1] == asOfVersion {
+				// Read as of the instant rather than a version: the label only names the diff.
+				driverConfig.Env = envVersion[0]
+				driverConfig.AsOf = asOf
+				driverConfig.DiffVersion = asOfVersion
 			}
 
 			configSlice = append(configSlice, driverConfig)
@@ -496,11 +716,29 @@ func CommonMain(envPtr *string,
 			Diff:              *diffPtr,
 			FileFilter:        fileFilterSlice,
 			VersionInfo:       eUtils.VersionHelper,
+			HistoryLimit:      *historyPtr,
+			AsOf:              asOf,
+			OutputFormat:      *formatPtr,
+			Strict:            *strictPtr,
+			UnresolvedKeys:    unresolvedKeys,
+			CertReport:        certReport,
+			RenderRegions:     renderRegions,
+			RegionVariances:   regionVariances,
+			Preview:           *previewPtr,
+			PreviewStdout:     *previewPtr && *endDirPtr == ENDDIR_DEFAULT,
+		}
+		if *previewPtr {
+			// Previews aren't configuration to roll back to.
+			dConfig.HistoryLimit = 0
 		}
 
 		if len(driverConfigBase.DeploymentConfig) > 0 {
 			dConfig.DeploymentConfig = driverConfigBase.DeploymentConfig
 		}
+		if *watchPtr > 0 {
+			dConfig.RenderedTemplates = &eUtils.RenderedTemplates{}
+			watchConfig = &dConfig
+		}
 		configCtx.ConfigWg.Add(1)
 		go func(dc *eUtils.DriverConfig) {
 			defer configCtx.ConfigWg.Done()
@@ -536,5 +774,74 @@ func CommonMain(envPtr *string,
 		}()
 	}
 	configCtx.ConfigWg.Wait() //Wait for diff
+
+	// Every report is printed before the run fails on any of them.
+	failures := []error{}
+	if configCtx.DiffDisallowed > 0 {
+		failures = append(failures, fmt.Errorf("diff: %d change(s) outside of -diffAllow", configCtx.DiffDisallowed))
+	}
+
+	if err := configCtx.Err(); err != nil {
+		failures = append(failures, err)
+	}
+
+	if unresolvedKeys.Len() > 0 {
+		report := unresolvedKeys.String()
+		fmt.Println(report)
+		driverConfigBase.CoreConfig.Log.Println(report)
+		failures = append(failures, fmt.Errorf("strict mode: %d unresolved template key(s)", unresolvedKeys.Len()))
+	}
+
+	if certReport != nil {
+		report := certReport.String()
+		fmt.Println(report)
+		driverConfigBase.CoreConfig.Log.Println(report)
+		if failed := certReport.Failed(); failed > 0 {
+			failures = append(failures, fmt.Errorf("cert report: %d certificate(s) failed", failed))
+		}
+	}
+
+	if regionVariances != nil {
+		report := regionVariances.String()
+		fmt.Println(report)
+		driverConfigBase.CoreConfig.Log.Println(report)
+	}
+
+	if len(failures) > 0 {
+		return errors.Join(failures...)
+	}
+
+	if watchConfig != nil {
+		return vcutils.WatchConfigs(watchConfig, *watchPtr, *onChangePtr)
+	}
+	return nil
+}
+
+// exportValues prints the resolved values for a project/service in the requested export format.
+func exportValues(driverConfig *eUtils.DriverConfig, export string, projectService string, exportTemplate string) error {
+	mod, err := helperkv.NewModifier(driverConfig.Insecure, driverConfig.Token, driverConfig.VaultAddress, driverConfig.EnvRaw, driverConfig.Regions, true, driverConfig.CoreConfig.Log)
+	if err != nil {
+		eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+		return err
+	}
+	defer mod.Close()
+	envVersion := eUtils.SplitEnv(driverConfig.Env)
+	mod.Env = envVersion[0]
+	if len(envVersion) > 1 {
+		mod.Version = envVersion[1]
+	}
+	mod.AsOf = driverConfig.AsOf
+
+	projectServiceParts := strings.Split(projectService, "/")
+	values, err := vcutils.ExportValues(driverConfig, mod, projectServiceParts[0], projectServiceParts[1], exportTemplate)
+	if err != nil {
+		eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+		return err
+	}
+	exported, err := vcutils.FormatExport(values, export)
+	if err != nil {
+		return err
+	}
+	fmt.Print(exported)
 	return nil
 }
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -34,6 +35,7 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 		return nil, err
 	}
 	modCheck.VersionFilter = driverConfig.VersionFilter
+	modCheck.AsOf = driverConfig.AsOf
 
 	//Check if templateInfo is selected for template or values
 	templateInfo := false
@@ -50,6 +52,10 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 			templateInfo = true
 		}
 	}
+	diffVersion := version
+	if driverConfig.DiffVersion != "" {
+		diffVersion = driverConfig.DiffVersion
+	}
 	versionData := make(map[string]interface{})
 	if driverConfig.Token != "novault" {
 		if valid, baseDesiredPolicy, errValidateEnvironment := modCheck.ValidateEnvironment(modCheck.RawEnv, false, "", driverConfig.CoreConfig.Log); errValidateEnvironment != nil || !valid {
@@ -108,6 +114,9 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 
 	templatePaths, endPaths = FilterPaths(templatePaths, endPaths, driverConfig.ServicesWanted, false)
 
+	// Common partials are included by other templates, never configured on their own.
+	templatePaths, endPaths = filterPartialPaths(templatePaths, endPaths)
+
 	for _, templatePath := range templatePaths {
 		if !driverConfig.CoreConfig.WantCerts && strings.Contains(templatePath, "Common") {
 			continue
@@ -203,7 +212,7 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 			}
 		*/
 	} else if !templateInfo {
-		if version != "0" { //Check requested version bounds
+		if version != "0" && driverConfig.AsOf.IsZero() { //Check requested version bounds
 			versionMetadataMap := eUtils.GetProjectVersionInfo(driverConfig, modCheck)
 			versionNumbers := eUtils.GetProjectVersions(driverConfig, versionMetadataMap)
 
@@ -211,6 +220,21 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 		}
 	}
 
+	var generation *outputGeneration
+	if !templateInfo && !versionInfo {
+		generation = newOutputGeneration(driverConfig)
+	}
+
+	var manifests *manifestCollector
+	if IsManifestFormat(driverConfig.OutputFormat) {
+		manifests = newManifestCollector(driverConfig.OutputFormat)
+	}
+
+	var previews *previewCollector
+	if driverConfig.PreviewStdout {
+		previews = newPreviewCollector()
+	}
+
 	var wg sync.WaitGroup
 	//configure each template in directory
 	driverConfig.DiffCounter = len(templatePaths)
@@ -222,6 +246,7 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 			mod, _ := helperkv.NewModifier(driverConfig.Insecure, driverConfig.Token, driverConfig.VaultAddress, driverConfig.EnvRaw, driverConfig.Regions, true, driverConfig.CoreConfig.Log)
 			mod.Env = driverConfig.Env
 			mod.Version = version
+			mod.AsOf = driverConfig.AsOf
 			//check for template_files directory here
 			project, service, templatePath := GetProjectService(driverConfig, templatePath)
 
@@ -273,10 +298,17 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 					versionData[endPaths[i]] = data
 					mutex.Unlock()
 					goto wait
+				} else if driverConfig.RenderRegions != nil {
+					configureRegions(driverConfig, mod, generation, project, service, templatePath, endPaths[i])
+					goto wait
 				} else {
 					var ctErr error
 					configuredTemplate, certData, certLoaded, ctErr = ConfigTemplate(driverConfig, mod, templatePath, driverConfig.SecretMode, project, service, driverConfig.CoreConfig.WantCerts, false)
 					if ctErr != nil {
+						if errors.Is(ctErr, eUtils.ErrUnresolvedKeys) {
+							// Strict mode: reported at end of run, nothing written.
+							goto wait
+						}
 						if !strings.Contains(ctErr.Error(), "Missing .certData") {
 							eUtils.CheckError(&driverConfig.CoreConfig, ctErr, true)
 						}
@@ -291,6 +323,14 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 						}
 					}
 				}
+				if driverConfig.CertReport != nil {
+					// Audited only.
+					goto wait
+				}
+				if driverConfig.Preview && driverConfig.CoreConfig.WantCerts {
+					// Certificates aren't previewed.
+					goto wait
+				}
 				//generate template or certificate
 				if driverConfig.CoreConfig.WantCerts && certLoaded {
 					if driverConfig.WantKeystore != "" && len(certData) == 0 {
@@ -303,7 +343,7 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 					}
 					destFile := certData[0]
 					certDestination := driverConfig.EndDir + "/" + destFile
-					writeToFile(driverConfig, certData[1], certDestination)
+					generation.writeToFile(driverConfig, certData[1], certDestination)
 					if driverConfig.OutputMemCache {
 						eUtils.LogInfo(&driverConfig.CoreConfig, "certificate pre-processed for "+certDestination)
 					} else {
@@ -312,13 +352,20 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 					goto wait
 				} else {
 					if driverConfig.Diff {
-						if version != "" {
-							driverConfig.Update(configCtx, &configuredTemplate, driverConfig.Env+"_"+version+"||"+endPaths[i])
+						if diffVersion != "" {
+							driverConfig.Update(configCtx, &configuredTemplate, driverConfig.Env+"_"+diffVersion+"||"+endPaths[i])
 						} else {
 							driverConfig.Update(configCtx, &configuredTemplate, driverConfig.Env+"||"+endPaths[i])
 						}
+					} else if manifests != nil {
+						if manifestErr := manifests.add(driverConfig, project, service, templatePath, endPaths[i], configuredTemplate); manifestErr != nil {
+							eUtils.LogErrorObject(&driverConfig.CoreConfig, manifestErr, false)
+						}
+					} else if previews != nil {
+						previews.add(driverConfig, endPaths[i], configuredTemplate)
 					} else {
-						writeToFile(driverConfig, configuredTemplate, endPaths[i])
+						generation.writeToFile(driverConfig, configuredTemplate, endPaths[i])
+						driverConfig.RenderedTemplates.Add(eUtils.RenderedTemplate{Project: project, Service: service, TemplatePath: templatePath, EndPath: endPaths[i]})
 					}
 				}
 			} else {
@@ -344,22 +391,37 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 					}
 					versionData[endPaths[i]] = data
 					goto wait
+				} else if driverConfig.RenderRegions != nil {
+					configureRegions(driverConfig, mod, generation, project, service, templatePath, endPaths[i])
+					goto wait
 				} else {
 					var ctErr error
 					configuredTemplate, certData, certLoaded, ctErr = ConfigTemplate(driverConfig, mod, templatePath, driverConfig.SecretMode, project, service, driverConfig.CoreConfig.WantCerts, false)
 					if ctErr != nil {
+						if errors.Is(ctErr, eUtils.ErrUnresolvedKeys) {
+							// Strict mode: reported at end of run, nothing written.
+							goto wait
+						}
 						if !strings.Contains(ctErr.Error(), "Missing .certData") {
 							eUtils.CheckError(&driverConfig.CoreConfig, ctErr, true)
 						}
 					}
 				}
+				if driverConfig.CertReport != nil {
+					// Audited only.
+					goto wait
+				}
+				if driverConfig.Preview && driverConfig.CoreConfig.WantCerts {
+					// Certificates aren't previewed.
+					goto wait
+				}
 				if driverConfig.CoreConfig.WantCerts && certLoaded {
 					if driverConfig.WantKeystore != "" {
 						// Keystore is serialized at end.
 						goto wait
 					}
 					certDestination := driverConfig.EndDir + "/" + certData[0]
-					writeToFile(driverConfig, certData[1], certDestination)
+					generation.writeToFile(driverConfig, certData[1], certDestination)
 					if driverConfig.OutputMemCache {
 						eUtils.LogInfo(&driverConfig.CoreConfig, "certificate pre-processed for "+certDestination)
 					} else {
@@ -368,19 +430,26 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 					goto wait
 				} else {
 					if driverConfig.Diff {
-						if version != "" {
-							driverConfig.Update(configCtx, &configuredTemplate, driverConfig.Env+"_"+version+"||"+endPaths[i])
+						if diffVersion != "" {
+							driverConfig.Update(configCtx, &configuredTemplate, driverConfig.Env+"_"+diffVersion+"||"+endPaths[i])
 						} else {
 							driverConfig.Update(configCtx, &configuredTemplate, driverConfig.Env+"||"+endPaths[i])
 						}
+					} else if manifests != nil {
+						if manifestErr := manifests.add(driverConfig, project, service, templatePath, endPaths[i], configuredTemplate); manifestErr != nil {
+							eUtils.LogErrorObject(&driverConfig.CoreConfig, manifestErr, false)
+						}
+					} else if previews != nil {
+						previews.add(driverConfig, endPaths[i], configuredTemplate)
 					} else {
-						writeToFile(driverConfig, configuredTemplate, endPaths[i])
+						generation.writeToFile(driverConfig, configuredTemplate, endPaths[i])
+						driverConfig.RenderedTemplates.Add(eUtils.RenderedTemplate{Project: project, Service: service, TemplatePath: templatePath, EndPath: endPaths[i]})
 					}
 				}
 			}
 
 			//print that we're done
-			if !driverConfig.Diff && !isCert && !templateInfo {
+			if !driverConfig.Diff && !isCert && !templateInfo && manifests == nil && previews == nil {
 				messageBase := "template configured and written to "
 				if driverConfig.OutputMemCache {
 					messageBase = "template configured and pre-processed for "
@@ -399,10 +468,26 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 		}(i, templatePath, version, versionData)
 	}
 	wg.Wait()
+	if manifests != nil {
+		if err := manifests.err(); err != nil {
+			// A manifest missing templates isn't worth deploying.
+			generation.abandon()
+			if configCtx != nil {
+				configCtx.AddError(err)
+			}
+			return nil, err
+		}
+		manifests.write(driverConfig, generation)
+	}
+	if previews != nil {
+		previews.print(driverConfig)
+	}
 	if templateInfo {
 		driverConfig.VersionInfo(versionData, true, "", false)
 	}
-	if driverConfig.WantKeystore != "" {
+	if driverConfig.WantKeystore != "" && driverConfig.Preview {
+		eUtils.LogInfo(&driverConfig.CoreConfig, "Preview: keystore "+driverConfig.WantKeystore+" skipped.  Certificates and keystores are not previewed.")
+	} else if driverConfig.WantKeystore != "" {
 		// Keystore is serialized at end.
 		ks, ksErr := validator.StoreKeystore(driverConfig, driverConfig.KeystorePassword)
 		if ksErr != nil {
@@ -410,7 +495,12 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 		}
 		certDestination := driverConfig.EndDir + "/" + driverConfig.WantKeystore
 		eUtils.LogInfo(&driverConfig.CoreConfig, "certificates written to "+certDestination)
-		writeToFile(driverConfig, string(ks), certDestination)
+		generation.writeToFile(driverConfig, string(ks), certDestination)
+	}
+	if err := generation.commit(driverConfig); err != nil {
+		generation.abandon()
+		eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+		return nil, err
 	}
 
 	return nil, nil
@@ -418,7 +508,30 @@ func GenerateConfigsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.Confi
 
 var memCacheLock sync.Mutex
 
-func writeToFile(driverConfig *eUtils.DriverConfig, data string, path string) {
+// configureRegions configures a template for each of driverConfig.RenderRegions, writing each
+// region's output to the same path under EndDir/<region>.
+func configureRegions(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier, generation *outputGeneration, project string, service string, templatePath string, endPath string) {
+	regionTemplates, err := ConfigTemplateRegions(driverConfig, mod, templatePath, driverConfig.SecretMode, project, service, driverConfig.RenderRegions, false)
+	if err != nil {
+		if errors.Is(err, eUtils.ErrUnresolvedKeys) {
+			// Strict mode: reported at end of run, nothing written.
+			return
+		}
+		eUtils.CheckError(&driverConfig.CoreConfig, err, true)
+	}
+	for _, region := range driverConfig.RenderRegions {
+		regionTemplate, ok := regionTemplates[region]
+		if !ok {
+			continue
+		}
+		regionPath := filepath.Join(driverConfig.EndDir, region, strings.TrimPrefix(endPath, driverConfig.EndDir))
+		generation.writeToFile(driverConfig, regionTemplate, regionPath)
+		eUtils.LogInfo(&driverConfig.CoreConfig, "template configured and written to "+regionPath)
+	}
+}
+
+// replaceTag substitutes ${TAG} with the validated build tag in TRCENV_TAG.
+func replaceTag(driverConfig *eUtils.DriverConfig, data string) string {
 	if strings.Contains(data, "${TAG}") {
 		tag := os.Getenv("TRCENV_TAG")
 		if len(tag) > 0 {
@@ -438,27 +551,55 @@ func writeToFile(driverConfig *eUtils.DriverConfig, data string, path string) {
 		}
 		data = strings.Replace(data, "${TAG}", tag, -1)
 	}
+	return data
+}
+
+func writeToFile(driverConfig *eUtils.DriverConfig, data string, path string) {
+	data = replaceTag(driverConfig, data)
 
 	byteData := []byte(data)
-	//Ensure directory has been created
-	var newFile *os.File
 
 	if driverConfig.OutputMemCache {
 		driverConfig.MemFs.WriteToMemFile(driverConfig, &memCacheLock, &byteData, path)
 	} else {
+		//Ensure directory has been created
 		dirPath := filepath.Dir(path)
 		err := os.MkdirAll(dirPath, os.ModePerm)
 		eUtils.CheckError(&driverConfig.CoreConfig, err, true)
-		//create new file
-		newFile, err = os.Create(path)
-		eUtils.CheckError(&driverConfig.CoreConfig, err, true)
-		defer newFile.Close()
-		//write to file
-		_, err = newFile.Write(byteData)
+		var fileMode os.FileMode = 0644
+		if fileInfo, statErr := os.Stat(path); statErr == nil {
+			fileMode = fileInfo.Mode().Perm()
+		}
+		err = atomicWriteFile(path, byteData, fileMode)
 		eUtils.CheckError(&driverConfig.CoreConfig, err, true)
+	}
+}
+
+// atomicWriteFile writes and syncs a temp file alongside path and renames it into place,
+// so readers never see a partial write.
+func atomicWriteFile(path string, byteData []byte, fileMode os.FileMode) error {
+	newFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(newFile.Name())
+	defer newFile.Close()
+	//write to file
+	_, err = newFile.Write(byteData)
+	if err == nil {
 		err = newFile.Sync()
-		eUtils.CheckError(&driverConfig.CoreConfig, err, true)
 	}
+	if err == nil {
+		err = newFile.Chmod(fileMode)
+	}
+	if err == nil {
+		err = newFile.Close()
+	}
+	if err == nil {
+		//swap into place
+		err = os.Rename(newFile.Name(), path)
+	}
+	return err
 }
 
 func getDirFiles(dir string, endDir string) ([]string, []string) {
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 
 	"github.com/trimble-oss/tierceron/pkg/core"
@@ -11,10 +12,30 @@ import (
 	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
 )
 
+// valueReader reads the data stored at a path: vault through a Modifier, or a SeedStore offline.
+type valueReader interface {
+	ReadData(path string) (map[string]interface{}, error)
+	ReadMapValue(valueMap map[string]interface{}, path string, key string) (string, error)
+}
+
+// versionReader reads as valueReader does, also returning the version read.
+type versionReader interface {
+	ReadDataWithVersion(path string) (map[string]interface{}, int, error)
+}
+
+// secretSource is the super-secrets path and key a value was read from.
+type secretSource struct {
+	bucket  string
+	key     string
+	version int // Version of bucket read, 0 if unknown.
+}
+
 // ConfigDataStore stores the data needed to configure the specified template files
 type ConfigDataStore struct {
-	dataMap map[string]interface{}
-	Regions []string
+	dataMap      map[string]interface{}
+	secretKeys   map[string]secretSource // service/file/key resolved from super-secrets.
+	resolvedKeys map[string]bool         // service/file/key resolved from values or super-secrets.
+	Regions      []string
 }
 
 func (cds *ConfigDataStore) Init(config *core.CoreConfig,
@@ -26,6 +47,8 @@ func (cds *ConfigDataStore) Init(config *core.CoreConfig,
 	servicesWanted ...string) error {
 	cds.Regions = mod.Regions
 	cds.dataMap = make(map[string]interface{})
+	cds.secretKeys = make(map[string]secretSource)
+	cds.resolvedKeys = make(map[string]bool)
 
 	var dataPathsFull []string
 
@@ -65,6 +88,39 @@ func (cds *ConfigDataStore) Init(config *core.CoreConfig,
 		dataPaths = dataPathsFull
 	}
 
+	return cds.populate(config, mod, mod.Regions, secretMode, useDirs, dataPaths, servicesWanted)
+}
+
+// InitFromSeeds initializes the data store from an environment's seed tree rather than vault.
+// Values are resolved exactly as Init resolves them once the seeds have been written by trcinit.
+func (cds *ConfigDataStore) InitFromSeeds(config *core.CoreConfig,
+	seeds *SeedStore,
+	mod *helperkv.Modifier,
+	secretMode bool,
+	useDirs bool,
+	project string,
+	servicesWanted ...string) error {
+	cds.Regions = mod.Regions
+	cds.dataMap = make(map[string]interface{})
+	cds.secretKeys = make(map[string]secretSource)
+	cds.resolvedKeys = make(map[string]bool)
+
+	dataPaths, err := seeds.templatePaths(config, mod.TemplatePath, project)
+	if err != nil {
+		eUtils.LogInfo(config, fmt.Sprintf("Uninitialized environment.  Please initialize environment. %v\n", err))
+		return err
+	}
+	return cds.populate(config, seeds, mod.Regions, secretMode, useDirs, dataPaths, servicesWanted)
+}
+
+// populate resolves the values for each of the template dataPaths into the data store.
+func (cds *ConfigDataStore) populate(config *core.CoreConfig,
+	mod valueReader,
+	regions []string,
+	secretMode bool,
+	useDirs bool,
+	dataPaths []string,
+	servicesWanted []string) error {
 	ogKeys := []string{}
 	valueMaps := [][]string{}
 
@@ -137,6 +193,7 @@ func (cds *ConfigDataStore) Init(config *core.CoreConfig,
 			noValueKeys := []string{}
 
 			secretBuckets := map[string]interface{}{}
+			bucketVersions := map[string]int{}
 
 			// Substitute in values
 			for k, v := range values {
@@ -145,7 +202,11 @@ func (cds *ConfigDataStore) Init(config *core.CoreConfig,
 					var secretBucket map[string]interface{}
 					var ok bool
 					if secretBucket, ok = secretBuckets[bucket].(map[string]interface{}); !ok {
-						secretBucket, err = mod.ReadData(bucket)
+						if versionMod, isVersionReader := mod.(versionReader); isVersionReader {
+							secretBucket, bucketVersions[bucket], err = versionMod.ReadDataWithVersion(bucket)
+						} else {
+							secretBucket, err = mod.ReadData(bucket)
+						}
 						if err != nil {
 							noValueKeys = append(noValueKeys, k)
 						} else {
@@ -153,23 +214,33 @@ func (cds *ConfigDataStore) Init(config *core.CoreConfig,
 						}
 					}
 
+					isSecret := strings.HasPrefix(bucket, "super-secrets/")
 					newVaultValue, readErr := mod.ReadMapValue(secretBucket, bucket, link[1].(string))
 					if link[0].(string) == "super-secrets/Common" {
 						commonValues[k] = newVaultValue
+						cds.secretKeys["Common/"+fileDir+"/"+k] = secretSource{bucket, link[1].(string), bucketVersions[bucket]}
 					} else {
 						if readErr == nil {
 							values[k] = newVaultValue
+							cds.resolvedKeys[serviceDir+"/"+fileDir+"/"+k] = true
+							if isSecret {
+								cds.secretKeys[serviceDir+"/"+fileDir+"/"+k] = secretSource{bucket, link[1].(string), bucketVersions[bucket]}
+							}
 						} else {
 							noValueKeys = append(noValueKeys, k)
 						}
 					}
 
 					// TODO: improve this M*N complexity algorithm.
-					for _, region := range mod.Regions {
+					for _, region := range regions {
 						regionPath := link[1].(string) + "~" + region
 						newVaultValue, readErr := mod.ReadMapValue(secretBucket, bucket, regionPath)
 						if readErr == nil {
 							values[k+"~"+region] = newVaultValue
+							cds.resolvedKeys[serviceDir+"/"+fileDir+"/"+k+"~"+region] = true
+							if isSecret {
+								cds.secretKeys[serviceDir+"/"+fileDir+"/"+k+"~"+region] = secretSource{bucket, regionPath, bucketVersions[bucket]}
+							}
 						}
 					}
 
@@ -390,6 +461,40 @@ func (cds *ConfigDataStore) GetConfigValue(service string, config string, key st
 	return "", false
 }
 
+// GetServiceValue finds a value for a key in any of a service's configurations, preferring overrides
+// for region, or for the data store's region when region is "".
+func (cds *ConfigDataStore) GetServiceValue(service string, key string, region string) (string, bool) {
+	key = strings.Replace(key, ".", "_", -1)
+	serviceValues, okServiceValues := cds.dataMap[service].(map[string]interface{})
+	if !okServiceValues {
+		return "", false
+	}
+	configs := make([]string, 0, len(serviceValues))
+	for config := range serviceValues {
+		configs = append(configs, config)
+	}
+	sort.Strings(configs)
+	if region == "" && len(cds.Regions) > 0 {
+		region = cds.Regions[0]
+	}
+
+	for _, config := range configs {
+		values, okServiceConfig := serviceValues[config].(map[string]interface{})
+		if !okServiceConfig {
+			continue
+		}
+		if region != "" {
+			if v, okType := values[key+"~"+region].(string); okType {
+				return v, true
+			}
+		}
+		if v, okType := values[key].(string); okType {
+			return v, true
+		}
+	}
+	return "", false
+}
+
 func GetPathsFromProject(config *core.CoreConfig, mod *helperkv.Modifier, projects []string, services []string) ([]string, error) {
 	//setup for getPaths
 	if len(config.DynamicPathFilter) > 0 && !config.WantCerts && mod.TemplatePath != "" {
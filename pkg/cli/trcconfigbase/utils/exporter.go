@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+)
+
+// Export output formats.
+const (
+	ExportEnv   = "env"
+	ExportJson  = "json"
+	ExportShell = "shell"
+)
+
+// IsExportFormat - true if format is a supported export format.
+func IsExportFormat(format string) bool {
+	return format == ExportEnv || format == ExportJson || format == ExportShell
+}
+
+var envNameRegex = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// dotenvEscaper escapes a value for double quotes in a .env file.  Everything else, including
+// printable UTF-8, passes through as is.
+var dotenvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`)
+
+// ExportValues gathers the resolved values for a service without rendering any templates.
+// Region overrides replace their base keys and in secretMode only keys resolved from
+// values or super-secrets are exported.
+// templateFile optionally restricts the export to a single template (config.yml or config.yml.tmpl).
+func ExportValues(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier, project string, service string, templateFile string) (map[string]string, error) {
+	cds := new(ConfigDataStore)
+	err := cds.Init(&driverConfig.CoreConfig, mod, driverConfig.SecretMode, true, project, nil, service)
+	if err != nil {
+		return nil, err
+	}
+	serviceValues, ok := cds.dataMap[service].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no values found for %s/%s", project, service)
+	}
+
+	if templateFile != "" {
+		// Same scrubbing as ConfigTemplate: everything after the first '.' goes.
+		templateFile = strings.TrimSuffix(templateFile, ".tmpl")
+		if dotIndex := strings.Index(templateFile, "."); dotIndex > 0 {
+			templateFile = templateFile[:dotIndex]
+		}
+	}
+
+	configs := []string{}
+	for config := range serviceValues {
+		if templateFile == "" || strings.TrimPrefix(config, "/") == templateFile || strings.HasSuffix(config, "/"+templateFile) {
+			configs = append(configs, config)
+		}
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no values found for %s in %s/%s", templateFile, project, service)
+	}
+	sort.Strings(configs)
+
+	exported := map[string]string{}
+	for _, config := range configs {
+		values, ok := serviceValues[config].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key, value := range values {
+			if strings.Contains(key, "~") {
+				continue
+			}
+			resolved := cds.resolvedKeys[service+"/"+config+"/"+key]
+			if len(cds.Regions) > 0 {
+				if regionValue, ok := values[key+"~"+cds.Regions[0]]; ok {
+					value = regionValue
+					resolved = cds.resolvedKeys[service+"/"+config+"/"+key+"~"+cds.Regions[0]]
+				}
+			}
+			if driverConfig.SecretMode && !resolved {
+				continue
+			}
+			if _, exists := exported[key]; exists {
+				eUtils.LogInfo(&driverConfig.CoreConfig, "Duplicate key "+key+" in "+config+" -- keeping first value found.")
+				continue
+			}
+			exported[key] = toString(value)
+		}
+	}
+
+	return exported, nil
+}
+
+// FormatExport renders exported values as a .env file, a JSON object or shell export lines.
+// Keys sanitized to the same variable name for .env or shell are an error.
+func FormatExport(values map[string]string, format string) (string, error) {
+	if format == ExportJson {
+		jsonBytes, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(jsonBytes) + "\n", nil
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var export strings.Builder
+	names := map[string]string{}
+	for _, key := range keys {
+		name := envNameRegex.ReplaceAllString(key, "_")
+		if collision, exists := names[name]; exists {
+			return "", fmt.Errorf("keys %s and %s both export as %s", collision, key, name)
+		}
+		names[name] = key
+		switch format {
+		case ExportEnv:
+			export.WriteString(name + "=\"" + dotenvEscaper.Replace(values[key]) + "\"\n")
+		case ExportShell:
+			export.WriteString("export " + name + "='" + strings.ReplaceAll(values[key], "'", `'\''`) + "'\n")
+		default:
+			return "", errors.New("unsupported export format: " + format)
+		}
+	}
+	return export.String(), nil
+}
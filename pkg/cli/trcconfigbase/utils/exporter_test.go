@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/trimble-oss/tierceron/buildopts/coreopts"
+	"github.com/trimble-oss/tierceron/pkg/core"
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+)
+
+// newMixedModifier - a modifier over memory storage holding a service with both values and super-secrets.
+// The password is written twice so the version read is 2.
+func newMixedModifier(t *testing.T) *helperkv.Modifier {
+	logger := log.New(io.Discard, "", 0)
+	mod := helperkv.NewModifierWithStorage(helperkv.NewMemoryStorage(), "dev", nil)
+	mod.Env = "dev"
+	for _, write := range []struct {
+		path string
+		data map[string]interface{}
+	}{
+		{"templates/Project/Service/config", map[string]interface{}{
+			"host":     []interface{}{"values/Project/Service/config", "host"},
+			"password": []interface{}{"super-secrets/Project/Service/config", "password"},
+		}},
+		{"values/Project/Service/config", map[string]interface{}{"host": "localhost"}},
+		{"super-secrets/Project/Service/config", map[string]interface{}{"password": "hunter2"}},
+		{"super-secrets/Project/Service/config", map[string]interface{}{"password": "hunter22"}},
+	} {
+		if _, err := mod.Write(write.path, write.data, logger); err != nil {
+			t.Fatalf("Write %s failed: %v", write.path, err)
+		}
+	}
+	return mod
+}
+
+func TestExportValuesMixed(t *testing.T) {
+	coreopts.NewOptionsBuilder(coreopts.LoadOptions())
+	for _, secretMode := range []bool{false, true} {
+		driverConfig := &eUtils.DriverConfig{
+			CoreConfig: core.CoreConfig{Log: log.New(io.Discard, "", 0)},
+			SecretMode: secretMode,
+		}
+		values, err := ExportValues(driverConfig, newMixedModifier(t), "Project", "Service", "")
+		if err != nil {
+			t.Fatalf("secretMode %v: ExportValues failed: %v", secretMode, err)
+		}
+		if len(values) != 2 || values["host"] != "localhost" || values["password"] != "hunter22" {
+			t.Errorf("secretMode %v: Expected host and password, got %v", secretMode, values)
+		}
+	}
+}
+
+func TestPreviewMaskVersion(t *testing.T) {
+	coreopts.NewOptionsBuilder(coreopts.LoadOptions())
+	config := &core.CoreConfig{Log: log.New(io.Discard, "", 0)}
+	for _, test := range []struct {
+		version  string
+		expected string
+	}{
+		{"", "«secret:Project/Service/password@v2»"},
+		{"1", "«secret:Project/Service/password@v1»"},
+	} {
+		mod := newMixedModifier(t)
+		mod.Version = test.version
+		cds := new(ConfigDataStore)
+		if err := cds.Init(config, mod, true, true, "Project", nil, "Service"); err != nil {
+			t.Fatalf("Init failed: %v", err)
+		}
+		cds.maskSecrets("Service")
+		if value, ok := cds.GetServiceValue("Service", "password", ""); !ok || value != test.expected {
+			t.Errorf("Version %q: Expected %s, got %s", test.version, test.expected, value)
+		}
+	}
+}
+
+func TestFormatExport(t *testing.T) {
+	values := map[string]string{
+		"db.host":  "localhost",
+		"greeting": "héllo wörld ✓",
+		"tricky":   "a\\b \"c\" $HOME 'd'\nnext",
+	}
+	for _, test := range []struct {
+		format   string
+		expected string
+	}{
+		{ExportEnv, "db_host=\"localhost\"\ngreeting=\"héllo wörld ✓\"\ntricky=\"a\\\\b \\\"c\\\" \\$HOME 'd'\\nnext\"\n"},
+		{ExportShell, "export db_host='localhost'\nexport greeting='héllo wörld ✓'\nexport tricky='a\\b \"c\" $HOME '\\''d'\\''\nnext'\n"},
+	} {
+		export, err := FormatExport(values, test.format)
+		if err != nil {
+			t.Fatalf("%s: FormatExport failed: %v", test.format, err)
+		}
+		if export != test.expected {
+			t.Errorf("%s: Expected\n%s\ngot\n%s", test.format, test.expected, export)
+		}
+	}
+
+	collides := map[string]string{"db.host": "a", "db_host": "b"}
+	for _, format := range []string{ExportEnv, ExportShell} {
+		if _, err := FormatExport(collides, format); err == nil || !strings.Contains(err.Error(), "db_host") {
+			t.Errorf("%s: Expected a collision error, got %v", format, err)
+		}
+	}
+	if export, err := FormatExport(collides, ExportJson); err != nil || !strings.Contains(export, `"db.host": "a"`) {
+		t.Errorf("Expected json to keep both keys, got %s %v", export, err)
+	}
+}
@@ -0,0 +1,324 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+)
+
+// Generations of configured output are staged, and kept when history is enabled, under
+// <endDir>/.trc_history/<generation>
+const historyDir = ".trc_history"
+
+// A commit in progress is journaled under .trc_history so a run interrupted while swapping
+// outputs into place is completed by the next run rather than leaving old and new outputs mixed.
+const commitJournal = "commit.json"
+
+type commitJournalEntry struct {
+	Generation int    `json:"generation"`
+	Staging    string `json:"staging"` // Staging directory, renamed to the generation once complete
+}
+
+type generationFile struct {
+	Path string `json:"path"` // Absolute destination
+	File string `json:"file"` // Staged copy relative to the generation directory
+}
+
+type generationManifest struct {
+	Generation int              `json:"generation"`
+	Time       string           `json:"time"`
+	Env        string           `json:"env"`
+	Files      []generationFile `json:"files"`
+}
+
+// outputGeneration stages every output of a run so nothing is swapped in until the whole run
+// has been configured.  Committed stages become a generation in the history.
+type outputGeneration struct {
+	mutex       sync.Mutex
+	historyPath string
+	stagingPath string
+	files       []generationFile
+}
+
+// newOutputGeneration starts staging a run's outputs.  Returns nil, meaning write directly,
+// when output isn't going to the filesystem.
+func newOutputGeneration(driverConfig *eUtils.DriverConfig) *outputGeneration {
+	if driverConfig.OutputMemCache || driverConfig.Diff {
+		return nil
+	}
+	historyPath := filepath.Join(driverConfig.EndDir, historyDir)
+	if err := os.MkdirAll(historyPath, os.ModePerm); err != nil {
+		eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+		return nil
+	}
+	if err := recoverCommit(historyPath); err != nil {
+		eUtils.LogErrorObject(&driverConfig.CoreConfig, fmt.Errorf("unable to complete interrupted commit in %s: %v", historyPath, err), false)
+	}
+	stagingPath, err := os.MkdirTemp(historyPath, ".staging-")
+	if err != nil {
+		eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+		return nil
+	}
+	if err := os.Mkdir(filepath.Join(stagingPath, "files"), os.ModePerm); err != nil {
+		eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+		os.RemoveAll(stagingPath)
+		return nil
+	}
+	return &outputGeneration{historyPath: historyPath, stagingPath: stagingPath}
+}
+
+// writeToFile stages the output for path, or writes it directly without a generation.
+func (g *outputGeneration) writeToFile(driverConfig *eUtils.DriverConfig, data string, path string) {
+	if g == nil {
+		writeToFile(driverConfig, data, path)
+		return
+	}
+	if err := g.stage([]byte(replaceTag(driverConfig, data)), path); err != nil {
+		g.abandon()
+		eUtils.CheckError(&driverConfig.CoreConfig, err, true)
+	}
+}
+
+func (g *outputGeneration) stage(byteData []byte, path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	stagedFile := filepath.Join("files", strconv.Itoa(len(g.files)))
+	for i, file := range g.files {
+		if file.Path == absPath {
+			// Last write wins.
+			stagedFile = file.File
+			g.files = append(g.files[:i], g.files[i+1:]...)
+			break
+		}
+	}
+	if err := atomicWriteFile(filepath.Join(g.stagingPath, stagedFile), byteData, 0600); err != nil {
+		return err
+	}
+	g.files = append(g.files, generationFile{Path: absPath, File: stagedFile})
+	return nil
+}
+
+// commit records the generation and swaps every staged output into place, pruning
+// generations beyond the history limit.  Without history even the committed generation is pruned.  The swap is journaled: once the generation is
+// recorded, an interrupted swap is completed by recoverCommit.
+func (g *outputGeneration) commit(driverConfig *eUtils.DriverConfig) error {
+	if g == nil {
+		return nil
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if len(g.files) == 0 {
+		g.abandon()
+		return nil
+	}
+
+	generations := listGenerations(g.historyPath)
+	generation := 1
+	if len(generations) > 0 {
+		generation = generations[len(generations)-1] + 1
+	}
+	manifest := generationManifest{
+		Generation: generation,
+		Time:       time.Now().UTC().Format(time.RFC3339),
+		Env:        driverConfig.Env,
+		Files:      g.files,
+	}
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicWriteFile(filepath.Join(g.stagingPath, "manifest.json"), manifestBytes, 0600); err != nil {
+		return err
+	}
+	journalBytes, err := json.Marshal(commitJournalEntry{Generation: generation, Staging: filepath.Base(g.stagingPath)})
+	if err != nil {
+		return err
+	}
+	if err := atomicWriteFile(filepath.Join(g.historyPath, commitJournal), journalBytes, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(g.stagingPath, filepath.Join(g.historyPath, strconv.Itoa(generation))); err != nil {
+		os.Remove(filepath.Join(g.historyPath, commitJournal))
+		return err
+	}
+	if err := applyGeneration(g.historyPath, &manifest); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(g.historyPath, commitJournal)); err != nil {
+		return err
+	}
+
+	generations = append(generations, generation)
+	for len(generations) > 0 && len(generations) > driverConfig.HistoryLimit {
+		os.RemoveAll(filepath.Join(g.historyPath, strconv.Itoa(generations[0])))
+		generations = generations[1:]
+	}
+	if len(generations) == 0 {
+		// Only removed when nothing else is in it.
+		os.Remove(g.historyPath)
+	}
+	return nil
+}
+
+// abandon discards staged outputs without touching any destination.
+func (g *outputGeneration) abandon() {
+	if g == nil {
+		return
+	}
+	os.RemoveAll(g.stagingPath)
+	os.Remove(g.historyPath)
+}
+
+// applyGeneration swaps every file of a recorded generation into place.  Safe to repeat.
+func applyGeneration(historyPath string, manifest *generationManifest) error {
+	for _, file := range manifest.Files {
+		byteData, err := os.ReadFile(filepath.Join(historyPath, strconv.Itoa(manifest.Generation), file.File))
+		if err != nil {
+			return err
+		}
+		var fileMode os.FileMode = 0644
+		if fileInfo, statErr := os.Stat(file.Path); statErr == nil {
+			fileMode = fileInfo.Mode().Perm()
+		}
+		if err := os.MkdirAll(filepath.Dir(file.Path), os.ModePerm); err != nil {
+			return err
+		}
+		if err := atomicWriteFile(file.Path, byteData, fileMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recoverCommit completes a commit interrupted after its generation was recorded, or discards
+// one interrupted before, so outputs are never left partly swapped.
+func recoverCommit(historyPath string) error {
+	journalPath := filepath.Join(historyPath, commitJournal)
+	journalBytes, err := os.ReadFile(journalPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	journal := commitJournalEntry{}
+	if err := json.Unmarshal(journalBytes, &journal); err != nil {
+		return err
+	}
+	if manifest, manifestErr := readGenerationManifest(historyPath, journal.Generation); manifestErr == nil {
+		if err := applyGeneration(historyPath, manifest); err != nil {
+			return err
+		}
+	} else if journal.Staging != "" {
+		os.RemoveAll(filepath.Join(historyPath, journal.Staging))
+	}
+	return os.Remove(journalPath)
+}
+
+// listGenerations returns the committed generations in ascending order.
+func listGenerations(historyPath string) []int {
+	generations := []int{}
+	entries, err := os.ReadDir(historyPath)
+	if err != nil {
+		return generations
+	}
+	for _, entry := range entries {
+		if generation, convErr := strconv.Atoi(entry.Name()); entry.IsDir() && convErr == nil {
+			generations = append(generations, generation)
+		}
+	}
+	sort.Ints(generations)
+	return generations
+}
+
+func readGenerationManifest(historyPath string, generation int) (*generationManifest, error) {
+	manifestBytes, err := os.ReadFile(filepath.Join(historyPath, strconv.Itoa(generation), "manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &generationManifest{}
+	if err := json.Unmarshal(manifestBytes, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// RollbackConfigs restores the generation of configured output n runs before the latest one
+// and prints a manifest of what changed.  The restore is itself recorded as a new generation.
+func RollbackConfigs(driverConfig *eUtils.DriverConfig, n int) error {
+	historyPath := filepath.Join(driverConfig.EndDir, historyDir)
+	if err := recoverCommit(historyPath); err != nil {
+		return err
+	}
+	generations := listGenerations(historyPath)
+	if n < 1 || n >= len(generations) {
+		return fmt.Errorf("cannot roll back %d generation(s): %d generation(s) available in %s", n, len(generations), historyPath)
+	}
+	latest, err := readGenerationManifest(historyPath, generations[len(generations)-1])
+	if err != nil {
+		return err
+	}
+	target, err := readGenerationManifest(historyPath, generations[len(generations)-1-n])
+	if err != nil {
+		return err
+	}
+
+	if driverConfig.HistoryLimit <= 0 {
+		driverConfig.HistoryLimit = len(generations) + 1
+	}
+	generation := newOutputGeneration(driverConfig)
+	if generation == nil {
+		return errors.New("unable to stage rollback in " + historyPath)
+	}
+
+	changes := []string{}
+	restored := map[string]bool{}
+	for _, file := range target.Files {
+		byteData, err := os.ReadFile(filepath.Join(historyPath, strconv.Itoa(target.Generation), file.File))
+		if err != nil {
+			generation.abandon()
+			return err
+		}
+		restored[file.Path] = true
+		if currentData, readErr := os.ReadFile(file.Path); readErr == nil && bytes.Equal(currentData, byteData) {
+			changes = append(changes, "  unchanged  "+file.Path)
+		} else {
+			changes = append(changes, "  restored   "+file.Path)
+		}
+		if err := generation.stage(byteData, file.Path); err != nil {
+			generation.abandon()
+			return err
+		}
+	}
+	for _, file := range latest.Files {
+		if !restored[file.Path] {
+			changes = append(changes, "  kept       "+file.Path+" (not in generation "+strconv.Itoa(target.Generation)+")")
+		}
+	}
+
+	if err := generation.commit(driverConfig); err != nil {
+		generation.abandon()
+		return err
+	}
+	generationInfo := target.Time
+	if target.Env != "" {
+		generationInfo = target.Env + " " + generationInfo
+	}
+	eUtils.LogInfo(&driverConfig.CoreConfig, fmt.Sprintf("Rolled back to generation %d (%s):", target.Generation, generationInfo))
+	for _, change := range changes {
+		eUtils.LogInfo(&driverConfig.CoreConfig, change)
+	}
+	return nil
+}
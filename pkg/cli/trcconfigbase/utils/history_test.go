@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/trimble-oss/tierceron/pkg/core"
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+)
+
+func TestGenerationCommit(t *testing.T) {
+	dir := t.TempDir()
+	driverConfig := &eUtils.DriverConfig{
+		CoreConfig:   core.CoreConfig{Log: log.New(io.Discard, "", 0)},
+		EndDir:       dir,
+		HistoryLimit: 2,
+	}
+	for _, content := range []string{"one", "two", "three"} {
+		generation := newOutputGeneration(driverConfig)
+		if err := generation.stage([]byte(content), filepath.Join(dir, "config.yml")); err != nil {
+			t.Fatal(err)
+		}
+		if err := generation.commit(driverConfig); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "config.yml")); string(data) != "three" {
+		t.Fatalf("Expected three, got %q", data)
+	}
+	historyPath := filepath.Join(dir, historyDir)
+	if generations := listGenerations(historyPath); len(generations) != 2 || generations[1] != 3 {
+		t.Fatalf("Expected generations [2 3], got %v", generations)
+	}
+	if _, err := os.Stat(filepath.Join(historyPath, commitJournal)); !os.IsNotExist(err) {
+		t.Fatalf("Expected no commit journal, got %v", err)
+	}
+}
+
+func TestGenerationCommitWithoutHistory(t *testing.T) {
+	dir := t.TempDir()
+	driverConfig := &eUtils.DriverConfig{
+		CoreConfig: core.CoreConfig{Log: log.New(io.Discard, "", 0)},
+		EndDir:     dir,
+	}
+	generation := newOutputGeneration(driverConfig)
+	if generation == nil {
+		t.Fatal("Expected the run staged without history")
+	}
+	if err := generation.stage([]byte("one"), filepath.Join(dir, "config.yml")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "config.yml")); len(data) != 0 {
+		t.Fatalf("Expected nothing written before commit, got %q", data)
+	}
+	if err := generation.commit(driverConfig); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "config.yml")); string(data) != "one" {
+		t.Fatalf("Expected one, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, historyDir)); !os.IsNotExist(err) {
+		t.Fatalf("Expected no history kept, got %v", err)
+	}
+}
+
+func TestRecoverCommit(t *testing.T) {
+	dir := t.TempDir()
+	historyPath := filepath.Join(dir, historyDir)
+	generationPath := filepath.Join(historyPath, "1")
+	if err := os.MkdirAll(filepath.Join(generationPath, "files"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	manifest := generationManifest{Generation: 1, Files: []generationFile{
+		{Path: filepath.Join(dir, "a.yml"), File: "files/0"},
+		{Path: filepath.Join(dir, "b.yml"), File: "files/1"},
+	}}
+	for i, content := range []string{"new a", "new b"} {
+		if err := os.WriteFile(filepath.Join(generationPath, manifest.Files[i].File), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifestBytes, _ := json.Marshal(manifest)
+	if err := os.WriteFile(filepath.Join(generationPath, "manifest.json"), manifestBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Interrupted after a.yml was swapped into place.
+	if err := os.WriteFile(filepath.Join(dir, "a.yml"), []byte("new a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.yml"), []byte("old b"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	journalBytes, _ := json.Marshal(commitJournalEntry{Generation: 1, Staging: ".staging-1"})
+	if err := os.WriteFile(filepath.Join(historyPath, commitJournal), journalBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recoverCommit(historyPath); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "b.yml")); string(data) != "new b" {
+		t.Fatalf("Expected new b, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(historyPath, commitJournal)); !os.IsNotExist(err) {
+		t.Fatalf("Expected commit journal removed, got %v", err)
+	}
+}
+
+func TestRecoverCommitBeforeRecorded(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), historyDir)
+	stagingPath := filepath.Join(historyPath, ".staging-1")
+	if err := os.MkdirAll(stagingPath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	journalBytes, _ := json.Marshal(commitJournalEntry{Generation: 1, Staging: ".staging-1"})
+	if err := os.WriteFile(filepath.Join(historyPath, commitJournal), journalBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recoverCommit(historyPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stagingPath); !os.IsNotExist(err) {
+		t.Fatalf("Expected staging removed, got %v", err)
+	}
+	if generations := listGenerations(historyPath); len(generations) != 0 {
+		t.Fatalf("Expected no generations, got %v", generations)
+	}
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Manifest output formats.
+const (
+	FormatK8sSecret    = "k8s-secret"
+	FormatK8sConfigMap = "k8s-configmap"
+)
+
+// IsManifestFormat - true if format is a supported manifest output format.
+func IsManifestFormat(format string) bool {
+	return format == FormatK8sSecret || format == FormatK8sConfigMap
+}
+
+type k8sMetadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace"`
+	Labels    map[string]string `yaml:"labels"`
+}
+
+type k8sManifest struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data"`
+}
+
+var k8sNameRegex = regexp.MustCompile(`[^a-z0-9.-]+`)
+var k8sKeyRegex = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// k8sName scrubs a project or service name into a valid kubernetes resource name.
+func k8sName(name string) string {
+	return strings.Trim(k8sNameRegex.ReplaceAllString(strings.ToLower(name), "-"), "-.")
+}
+
+// manifestKey - the data key for a template: its path under its service's template directory with
+// folders joined by "_", so templates in different folders don't collide.  The output file name
+// when the template isn't under a service.
+func manifestKey(templatePath string, endPath string) string {
+	components := strings.Split(strings.ReplaceAll(templatePath, "\\", "/"), "/")
+	for i, component := range components {
+		if strings.HasSuffix(component, "_templates") && len(components) > i+3 {
+			return strings.TrimSuffix(strings.Join(components[i+3:], "_"), ".tmpl")
+		}
+	}
+	return filepath.Base(strings.ReplaceAll(endPath, "\\", "/"))
+}
+
+// manifestCollector gathers rendered templates into one manifest per service.
+type manifestCollector struct {
+	mutex     sync.Mutex
+	format    string
+	manifests map[string]*k8sManifest // project/service -> manifest
+	errs      []error                 // Templates left out of their manifest.
+}
+
+func newManifestCollector(format string) *manifestCollector {
+	return &manifestCollector{format: format, manifests: map[string]*k8sManifest{}}
+}
+
+// add places a rendered template in its service's manifest keyed by manifestKey.  Templates that
+// can't be added fail the run: see err.
+func (mc *manifestCollector) add(driverConfig *eUtils.DriverConfig, project string, service string, templatePath string, endPath string, data string) error {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	err := mc.addLocked(driverConfig, project, service, manifestKey(templatePath, endPath), data)
+	if err != nil {
+		mc.errs = append(mc.errs, err)
+	}
+	return err
+}
+
+func (mc *manifestCollector) addLocked(driverConfig *eUtils.DriverConfig, project string, service string, key string, data string) error {
+	if !k8sKeyRegex.MatchString(key) {
+		return fmt.Errorf("%q is not a valid manifest data key for %s/%s", key, project, service)
+	}
+	data = replaceTag(driverConfig, data)
+
+	manifest, ok := mc.manifests[project+"/"+service]
+	if !ok {
+		manifest = &k8sManifest{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Metadata: k8sMetadata{
+				Name:      k8sName(service),
+				Namespace: k8sName(project),
+				Labels: map[string]string{
+					"app.kubernetes.io/name":       k8sName(service),
+					"app.kubernetes.io/part-of":    k8sName(project),
+					"app.kubernetes.io/managed-by": "tierceron",
+				},
+			},
+			Data: map[string]string{},
+		}
+		if mc.format == FormatK8sSecret {
+			manifest.Kind = "Secret"
+			manifest.Type = "Opaque"
+		}
+		mc.manifests[project+"/"+service] = manifest
+	}
+	if _, exists := manifest.Data[key]; exists {
+		return errors.New("duplicate manifest data key " + key + " for " + project + "/" + service)
+	}
+	if mc.format == FormatK8sSecret {
+		data = base64.StdEncoding.EncodeToString([]byte(data))
+	}
+	manifest.Data[key] = data
+
+	return nil
+}
+
+// err - why templates were left out of their manifests, nil if none were.
+func (mc *manifestCollector) err() error {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	return errors.Join(mc.errs...)
+}
+
+// write emits each collected manifest to <endDir>/<project>.<service>.<format>.yaml
+func (mc *manifestCollector) write(driverConfig *eUtils.DriverConfig, generation *outputGeneration) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	projectServices := []string{}
+	for projectService := range mc.manifests {
+		projectServices = append(projectServices, projectService)
+	}
+	sort.Strings(projectServices)
+
+	for _, projectService := range projectServices {
+		manifest := mc.manifests[projectService]
+		manifestBytes, err := yaml.Marshal(manifest)
+		if err != nil {
+			eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+			continue
+		}
+		manifestDestination := strings.TrimSuffix(driverConfig.EndDir, "/") + "/" + manifest.Metadata.Namespace + "." + manifest.Metadata.Name + "." + mc.format + ".yaml"
+		generation.writeToFile(driverConfig, string(manifestBytes), manifestDestination)
+		eUtils.LogInfo(&driverConfig.CoreConfig, "manifest written to "+manifestDestination)
+	}
+}
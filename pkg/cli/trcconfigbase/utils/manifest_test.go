@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"encoding/base64"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/trimble-oss/tierceron/pkg/core"
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+)
+
+func TestManifestCollector(t *testing.T) {
+	driverConfig := &eUtils.DriverConfig{CoreConfig: core.CoreConfig{Log: log.New(io.Discard, "", 0)}}
+	templates := []struct {
+		templatePath string
+		endPath      string
+		key          string
+	}{
+		{"trc_templates/Project/Service/config.yml.tmpl", "out/config.yml", "config.yml"},
+		{"trc_templates/Project/Service/nested/config.yml.tmpl", "out/nested/config.yml", "nested_config.yml"},
+	}
+
+	for _, test := range []struct {
+		format string
+		kind   string
+		encode func(string) string
+	}{
+		{FormatK8sSecret, "Secret", func(data string) string { return base64.StdEncoding.EncodeToString([]byte(data)) }},
+		{FormatK8sConfigMap, "ConfigMap", func(data string) string { return data }},
+	} {
+		collector := newManifestCollector(test.format)
+		for _, template := range templates {
+			if err := collector.add(driverConfig, "Project", "Service", template.templatePath, template.endPath, "host: "+template.key); err != nil {
+				t.Fatalf("%s: add %s failed: %v", test.format, template.templatePath, err)
+			}
+		}
+		if err := collector.err(); err != nil {
+			t.Fatalf("%s: expected no errors, got %v", test.format, err)
+		}
+		manifest := collector.manifests["Project/Service"]
+		if manifest.Kind != test.kind || manifest.Metadata.Name != "service" || manifest.Metadata.Namespace != "project" {
+			t.Errorf("%s: unexpected manifest %+v", test.format, manifest)
+		}
+		for _, template := range templates {
+			if manifest.Data[template.key] != test.encode("host: "+template.key) {
+				t.Errorf("%s: expected %s encoded, got %q", test.format, template.key, manifest.Data[template.key])
+			}
+		}
+	}
+}
+
+func TestManifestCollectorErrors(t *testing.T) {
+	driverConfig := &eUtils.DriverConfig{CoreConfig: core.CoreConfig{Log: log.New(io.Discard, "", 0)}}
+	collector := newManifestCollector(FormatK8sConfigMap)
+	if err := collector.add(driverConfig, "Project", "Service", "trc_templates/Project/Service/config.yml.tmpl", "out/config.yml", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := collector.add(driverConfig, "Project", "Service", "trc_templates/Project/Service/config.yml.tmpl", "out/config.yml", "b"); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatalf("Expected a duplicate key, got %v", err)
+	}
+	if err := collector.add(driverConfig, "Project", "Service", "trc_templates/Project/Service/bad key.tmpl", "out/bad key", "c"); err == nil {
+		t.Fatal("Expected an invalid key")
+	}
+	if err := collector.err(); err == nil || !strings.Contains(err.Error(), "duplicate") || !strings.Contains(err.Error(), "bad key") {
+		t.Fatalf("Expected both errors to fail the run, got %v", err)
+	}
+}
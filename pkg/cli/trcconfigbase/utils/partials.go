@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/trimble-oss/tierceron/buildopts/coreopts"
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+)
+
+// Partials live under <prefix>_templates/Common/partials and are published to
+// templates/Common/partials/<name>/template-file by trcpub.
+const partialsDir = "Common/partials"
+
+var partialsLock sync.Mutex
+
+// isPartialPath - true if templatePath is a shared partial rather than a renderable template.
+func isPartialPath(templatePath string) bool {
+	return strings.Contains(strings.ReplaceAll(templatePath, "\\", "/"), "/"+partialsDir+"/")
+}
+
+// filterPartialPaths removes shared partials from the templates to render.
+func filterPartialPaths(templatePaths []string, endPaths []string) ([]string, []string) {
+	filteredTemplatePaths := []string{}
+	filteredEndPaths := []string{}
+	for i, templatePath := range templatePaths {
+		if isPartialPath(templatePath) {
+			continue
+		}
+		filteredTemplatePaths = append(filteredTemplatePaths, templatePath)
+		filteredEndPaths = append(filteredEndPaths, endPaths[i])
+	}
+	return filteredTemplatePaths, filteredEndPaths
+}
+
+// loadPartials loads the shared partials once per run, from vault when zc is requested
+// and from the filesystem otherwise.  Partials are named by file name without .tmpl:
+// Common/partials/logging.yml.tmpl is included with {{template "logging.yml" .}}
+func loadPartials(driverConfig *eUtils.DriverConfig, modifier *helperkv.Modifier, emptyFilePath string, zc bool) map[string]string {
+	partialsLock.Lock()
+	defer partialsLock.Unlock()
+	if driverConfig.Partials != nil {
+		return driverConfig.Partials
+	}
+
+	partials := map[string]string{}
+	if zc {
+		if modifier != nil && driverConfig.Token != "novault" {
+			loadVaultPartials(driverConfig, modifier, partials)
+		}
+	} else {
+		templatesDir := coreopts.BuildOptions.GetFolderPrefix(driverConfig.StartDir) + "_templates"
+		templatePathParts := strings.Split(strings.ReplaceAll(emptyFilePath, "\\", "/"), templatesDir)
+		if len(templatePathParts) > 1 {
+			loadFilePartials(driverConfig, templatePathParts[0]+templatesDir+"/"+partialsDir, partials)
+		}
+	}
+	driverConfig.Partials = partials
+
+	return partials
+}
+
+func loadFilePartials(driverConfig *eUtils.DriverConfig, dir string, partials map[string]string) {
+	partialFiles, err := os.ReadDir(dir)
+	if err != nil {
+		// No partials provided.
+		return
+	}
+	for _, partialFile := range partialFiles {
+		if partialFile.IsDir() || filepath.Ext(partialFile.Name()) != ".tmpl" {
+			continue
+		}
+		partialBytes, err := os.ReadFile(dir + "/" + partialFile.Name())
+		if err != nil {
+			eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+			continue
+		}
+		partials[strings.TrimSuffix(partialFile.Name(), ".tmpl")] = string(partialBytes)
+	}
+}
+
+func loadVaultPartials(driverConfig *eUtils.DriverConfig, modifier *helperkv.Modifier, partials map[string]string) {
+	partialList, err := modifier.List("templates/"+partialsDir, driverConfig.CoreConfig.Log)
+	if err != nil || partialList == nil {
+		// No partials provided.
+		return
+	}
+	partialNames, ok := partialList.Data["keys"].([]interface{})
+	if !ok {
+		return
+	}
+	for _, partialName := range partialNames {
+		name := strings.TrimSuffix(partialName.(string), "/")
+		tfMap, err := modifier.ReadData("templates/" + partialsDir + "/" + name + "/template-file")
+		if err != nil || tfMap == nil {
+			eUtils.LogInfo(&driverConfig.CoreConfig, "Skipping partial: "+name)
+			continue
+		}
+		data, dataOk := tfMap["data"].(string)
+		if !dataOk {
+			continue
+		}
+		if ext, extOk := tfMap["ext"].(string); extOk {
+			name = name + ext
+		}
+		partialBytes, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+			continue
+		}
+		partials[name] = string(partialBytes)
+	}
+}
+
+// addPartials associates the shared partials with the template set of t.
+func addPartials(t *template.Template, partials map[string]string) error {
+	for name, partial := range partials {
+		if _, err := t.New(name).Parse(partial); err != nil {
+			return err
+		}
+	}
+	return nil
+}
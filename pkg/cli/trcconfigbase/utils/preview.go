@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+)
+
+// previewMask - the stable stand in for a secret in preview output: «secret:Project/Service/key@v12»
+// The version is the one rendered from, left off when it can't be determined, as with -novault.
+func previewMask(source secretSource) string {
+	bucketParts := strings.Split(strings.TrimPrefix(source.bucket, "super-secrets/"), "/")
+	maskPath := bucketParts[0]
+	if len(bucketParts) > 1 {
+		maskPath = maskPath + "/" + bucketParts[1]
+	}
+	mask := "secret:" + maskPath + "/" + source.key
+	if source.version > 0 {
+		mask = mask + "@v" + strconv.Itoa(source.version)
+	}
+	return "«" + mask + "»"
+}
+
+// maskSecrets replaces each of a service's values read from super-secrets with its preview mask.
+func (cds *ConfigDataStore) maskSecrets(service string) {
+	serviceValues, ok := cds.dataMap[service].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for config, configValues := range serviceValues {
+		values, ok := configValues.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key := range values {
+			if source, isSecret := cds.secretKeys[service+"/"+config+"/"+key]; isSecret {
+				values[key] = previewMask(source)
+			}
+		}
+	}
+}
+
+// previewCollector gathers previewed templates to print once the run completes.
+type previewCollector struct {
+	mutex    sync.Mutex
+	previews map[string]string // endPath -> configured template
+}
+
+func newPreviewCollector() *previewCollector {
+	return &previewCollector{previews: map[string]string{}}
+}
+
+func (pc *previewCollector) add(driverConfig *eUtils.DriverConfig, endPath string, data string) {
+	pc.mutex.Lock()
+	pc.previews[endPath] = replaceTag(driverConfig, data)
+	pc.mutex.Unlock()
+}
+
+// print writes every previewed template to stdout in path order.
+func (pc *previewCollector) print(driverConfig *eUtils.DriverConfig) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	endPaths := []string{}
+	for endPath := range pc.previews {
+		endPaths = append(endPaths, endPath)
+	}
+	sort.Strings(endPaths)
+
+	for _, endPath := range endPaths {
+		fmt.Printf("==> %s <==\n%s\n", strings.TrimPrefix(strings.TrimPrefix(endPath, driverConfig.EndDir), "/"), strings.TrimSuffix(pc.previews[endPath], "\n"))
+	}
+}
@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/trimble-oss/tierceron/buildopts/coreopts"
+	"github.com/trimble-oss/tierceron/pkg/core"
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// SeedStore holds an environment's seed tree as vault would hold it once seeded by trcinit,
+// so -novault configures exactly what a vault backed run configures.  Data is kept by
+// vault path: templates/..., values/... and super-secrets/...
+type SeedStore struct {
+	data map[string]map[string]interface{}
+}
+
+// cachedSeedStore is a loaded seed tree along with the state of the seed files it was loaded from.
+type cachedSeedStore struct {
+	seeds *SeedStore
+	stamp string
+}
+
+var seedStoresLock sync.Mutex
+var seedStores = map[string]*cachedSeedStore{}
+
+// loadSeedStore loads the seed tree for driverConfig's environment, reloading it only when
+// a seed file has been added, removed or modified since it was last loaded.
+func loadSeedStore(driverConfig *eUtils.DriverConfig) (*SeedStore, error) {
+	seedsDir := strings.Split(driverConfig.StartDir[0], coreopts.BuildOptions.GetFolderPrefix(driverConfig.StartDir)+"_")[0] + coreopts.BuildOptions.GetFolderPrefix(driverConfig.StartDir) + "_seeds"
+	env := eUtils.SplitEnv(driverConfig.Env)[0]
+	stamp := seedTreeStamp(filepath.Join(seedsDir, env))
+
+	seedStoresLock.Lock()
+	defer seedStoresLock.Unlock()
+	if cached, ok := seedStores[seedsDir+"/"+env]; ok && cached.stamp == stamp {
+		return cached.seeds, nil
+	}
+	seeds, err := LoadSeedStore(&driverConfig.CoreConfig, seedsDir, env)
+	if err != nil {
+		return nil, err
+	}
+	seedStores[seedsDir+"/"+env] = &cachedSeedStore{seeds: seeds, stamp: stamp}
+	return seeds, nil
+}
+
+// seedTreeStamp summarizes the name, size and modification time of every seed file under envDir.
+func seedTreeStamp(envDir string) string {
+	var stamp strings.Builder
+	filepath.Walk(envDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(path, "_seed.yml") {
+			stamp.WriteString(fmt.Sprintf("%s:%d:%d\n", path, info.Size(), info.ModTime().UnixNano()))
+		}
+		return nil
+	})
+	return stamp.String()
+}
+
+// LoadSeedStore reads every seed file for env under seedsDir: <env>/<env>_seed.yml, the
+// Index, Restricted and Protected section seeds and any nested seeds written by trcinit -nest.
+func LoadSeedStore(config *core.CoreConfig, seedsDir string, env string) (*SeedStore, error) {
+	seeds := &SeedStore{data: map[string]map[string]interface{}{}}
+	envDir := filepath.Join(seedsDir, env)
+	if _, err := os.Stat(envDir); err != nil {
+		return nil, errors.New("unable to open seed directory for -novault: " + err.Error())
+	}
+
+	// The environment's seed is seeded first, then nested seeds over it.
+	seedFile := filepath.Join(envDir, env+"_seed.yml")
+	if _, err := os.Stat(seedFile); err == nil {
+		if err := seeds.addSeedFile(config, seedsDir, env, seedFile, ""); err != nil {
+			return nil, err
+		}
+	}
+	err := filepath.Walk(envDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || path == seedFile || !strings.HasSuffix(path, "_seed.yml") {
+			return nil
+		}
+		relativePath := filepath.ToSlash(strings.TrimPrefix(path, envDir+string(os.PathSeparator)))
+		if !strings.Contains(relativePath, "/") {
+			// Versioned and enterprise seeds alongside the environment's seed aren't part of it.
+			return nil
+		}
+		return seeds.addSeedFile(config, seedsDir, env, path, seedSectionPath(relativePath, env))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(seeds.data) == 0 {
+		return nil, errors.New("no seed data found in " + envDir)
+	}
+	return seeds, nil
+}
+
+// seedSectionPath - the section path trcinit seeds Index, Restricted and Protected seeds under.
+func seedSectionPath(relativePath string, env string) string {
+	switch {
+	case strings.HasPrefix(relativePath, "Index/"):
+	case strings.HasPrefix(relativePath, "Restricted/"), strings.HasPrefix(relativePath, "Protected/"):
+		if i := strings.LastIndex(relativePath, "/"+env); i > 0 {
+			relativePath = relativePath[:i]
+		}
+	default:
+		return ""
+	}
+	return strings.TrimSuffix(relativePath, "_seed.yml")
+}
+
+// addSeedFile adds the data in a seed file, decomposed into vault paths the way trcinit writes it.
+func (seeds *SeedStore) addSeedFile(config *core.CoreConfig, seedsDir string, env string, seedFile string, sectionPath string) error {
+	rawFile, err := os.ReadFile(seedFile)
+	if err != nil {
+		return err
+	}
+	var rawYaml interface{}
+	if err := yaml.Unmarshal(rawFile, &rawYaml); err != nil {
+		return fmt.Errorf("%s: %v", seedFile, err)
+	}
+	seed, ok := rawYaml.(map[interface{}]interface{})
+	if !ok {
+		return errors.New("invalid yaml file: " + seedFile)
+	}
+	eUtils.LogInfo(config, "Seed loaded from "+seedFile)
+
+	type seedCollection struct {
+		path string
+		data map[interface{}]interface{}
+	}
+	mapStack := []seedCollection{{"", seed}}
+	for len(mapStack) > 0 {
+		current := mapStack[0]
+		mapStack = mapStack[1:]
+		leaves := map[string]interface{}{}
+		for k, v := range current.data {
+			key := fmt.Sprint(k)
+			if v == nil || (current.path == "" && key == "verification") {
+				continue
+			} else if newData, ok := v.(map[interface{}]interface{}); ok {
+				path := key
+				if current.path != "" {
+					path = current.path + "/" + key
+				}
+				mapStack = append([]seedCollection{{path, newData}}, mapStack...)
+			} else {
+				leaves[key] = v
+			}
+		}
+		if len(leaves) == 0 {
+			continue
+		}
+		if _, hasCertSource := leaves["certSourcePath"]; hasCertSource {
+			if _, hasCertData := leaves["certData"]; hasCertData && strings.HasPrefix(current.path, "values/") {
+				seeds.loadCert(config, seedsDir, env, leaves)
+			}
+		}
+		data, err := vaultData(leaves)
+		if err != nil {
+			return fmt.Errorf("%s: %v", seedFile, err)
+		}
+		path := current.path
+		if sectionPath != "" && !strings.HasPrefix(path, "templates") {
+			path = sectionSeedPath(sectionPath, path)
+		}
+		if _, ok := seeds.data[path]; ok && strings.HasPrefix(path, "templates") {
+			// Templates are only written once per initialization.
+			continue
+		}
+		seeds.data[path] = data
+	}
+	return nil
+}
+
+// sectionSeedPath - the path a section seed's data is written to, following Modifier.Write.
+func sectionSeedPath(sectionPath string, path string) string {
+	pathBlocks := strings.SplitAfterN(path, "/", 2)
+	if len(pathBlocks) == 1 {
+		pathBlocks[0] += "/"
+	}
+	fullPath := pathBlocks[0] + sectionPath + "/"
+	if len(pathBlocks) > 1 && !strings.Contains(fullPath, "/"+pathBlocks[1]+"/") {
+		fullPath += pathBlocks[1]
+	}
+	return strings.TrimSuffix(strings.ReplaceAll(fullPath, "/super-secrets/", "/"), "/")
+}
+
+// vaultData - seed values as vault returns them: numbers become json.Number.
+func vaultData(leaves map[string]interface{}) (map[string]interface{}, error) {
+	dataBytes, err := json.Marshal(leaves)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(dataBytes))
+	decoder.UseNumber()
+	data := map[string]interface{}{}
+	if err := decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// loadCert fills in certData from the certificate at certSourcePath, as trcinit -certs does.
+func (seeds *SeedStore) loadCert(config *core.CoreConfig, seedsDir string, env string, leaves map[string]interface{}) {
+	certPath := fmt.Sprint(leaves["certSourcePath"])
+	certPath = strings.Replace(certPath, "ENV", env, 1)
+	if strings.Contains(certPath, "..") {
+		eUtils.LogInfo(config, "Invalid cert path: "+certPath+" Certs not allowed to contain complex path navigation.")
+		return
+	}
+	cert, err := os.ReadFile(filepath.Join(seedsDir, certPath))
+	if err != nil {
+		eUtils.LogInfo(config, "Missing expected cert at: "+certPath+".  Cert will not be loaded.")
+		return
+	}
+	leaves["certData"] = base64.StdEncoding.EncodeToString(cert)
+}
+
+// templatePaths - the template paths holding values for a project.  Restricted to templatePath when provided.
+func (seeds *SeedStore) templatePaths(config *core.CoreConfig, templatePath string, project string) ([]string, error) {
+	if !config.WantCerts && templatePath != "" {
+		if _, ok := seeds.data[templatePath]; !ok {
+			return nil, fmt.Errorf("template not found in seeds: %s", templatePath)
+		}
+		return []string{templatePath}, nil
+	}
+	paths := []string{}
+	for path := range seeds.data {
+		if strings.HasPrefix(path, "templates/"+project+"/") {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no available projects found")
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ReadData returns the data seeded at path.
+func (seeds *SeedStore) ReadData(path string) (map[string]interface{}, error) {
+	data, ok := seeds.data[strings.TrimSuffix(path, "/")]
+	if !ok {
+		return nil, nil
+	}
+	dataCopy := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		dataCopy[k] = v
+	}
+	return dataCopy, nil
+}
+
+// ReadMapValue returns the value for key in valueMap, read from path.
+func (seeds *SeedStore) ReadMapValue(valueMap map[string]interface{}, path string, key string) (string, error) {
+	if valueMap[key] != nil {
+		if value, ok := valueMap[key].(string); ok {
+			return value, nil
+		} else if stringer, ok := valueMap[key].(fmt.GoStringer); ok {
+			return stringer.GoString(), nil
+		} else if number, ok := valueMap[key].(json.Number); ok {
+			return number.String(), nil
+		}
+		return "", fmt.Errorf("cannot convert value at %s to string", key)
+	}
+	return "", fmt.Errorf("key '%s' not found in '%s' in seeds", key, path)
+}
+
+// ReadValue returns the value for key seeded at path.
+func (seeds *SeedStore) ReadValue(path string, key string) (string, error) {
+	valueMap, err := seeds.ReadData(path)
+	if err != nil {
+		return "", err
+	}
+	return seeds.ReadMapValue(valueMap, path, key)
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/trimble-oss/tierceron/buildopts/coreopts"
+	"github.com/trimble-oss/tierceron/pkg/core"
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+)
+
+const testSeed = `templates:
+  Project:
+    Service:
+      config:
+        host: ["values/Project/Service/config", "host"]
+        port: ["values/Project/Service/config", "port"]
+        password: ["super-secrets/Project/Service/config", "password"]
+values:
+  Project:
+    Service:
+      config:
+        host: localhost
+        port: 8080
+super-secrets:
+  Project:
+    Service:
+      config:
+        password: hunter22
+`
+
+const testNestedSeed = `values:
+  Project:
+    Service:
+      config:
+        host: nested.local
+        port: 9090
+`
+
+func writeTestSeeds(t *testing.T, nested bool) string {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "trc_seeds", "dev", "Project"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "trc_seeds", "dev", "dev_seed.yml"), []byte(testSeed), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if nested {
+		if err := os.WriteFile(filepath.Join(dir, "trc_seeds", "dev", "Project", "Service_seed.yml"), []byte(testNestedSeed), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPopulateTemplateFromSeeds(t *testing.T) {
+	coreopts.NewOptionsBuilder(coreopts.LoadOptions())
+	for _, test := range []struct {
+		nested   bool
+		expected string
+	}{
+		{false, "localhost:8080 hunter22"},
+		{true, "nested.local:9090 hunter22"},
+	} {
+		dir := writeTestSeeds(t, test.nested)
+		driverConfig := &eUtils.DriverConfig{
+			CoreConfig: core.CoreConfig{Log: log.New(io.Discard, "", 0)},
+			Token:      "novault",
+			Env:        "dev",
+			StartDir:   []string{filepath.Join(dir, "trc_templates")},
+		}
+		mod, err := helperkv.NewModifier(false, "novault", "", "dev", nil, false, driverConfig.CoreConfig.Log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mod.TemplatePath = "templates/Project/Service/config"
+
+		configured, _, err := PopulateTemplate(driverConfig, "{{.host}}:{{.port}} {{.password}}", mod, false, "Project", "Service", "config", false)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if configured != test.expected {
+			t.Fatalf("Expected %q, got %q", test.expected, configured)
+		}
+	}
+}
+
+func TestLoadSeedStoreReloadsEditedSeeds(t *testing.T) {
+	coreopts.NewOptionsBuilder(coreopts.LoadOptions())
+	dir := writeTestSeeds(t, false)
+	driverConfig := &eUtils.DriverConfig{
+		CoreConfig: core.CoreConfig{Log: log.New(io.Discard, "", 0)},
+		Token:      "novault",
+		Env:        "dev",
+		StartDir:   []string{filepath.Join(dir, "trc_templates")},
+	}
+	seeds, err := loadSeedStore(driverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := loadSeedStore(driverConfig); cached != seeds {
+		t.Fatal("Expected unchanged seeds to be cached")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "trc_seeds", "dev", "Project", "Service_seed.yml"), []byte(testNestedSeed), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, _ := loadSeedStore(driverConfig); reloaded == seeds {
+		t.Fatal("Expected edited seeds to be reloaded")
+	}
+}
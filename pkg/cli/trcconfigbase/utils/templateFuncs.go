@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+
+	"gopkg.in/yaml.v2"
+)
+
+// secretLookup resolves "Project/Service/key" references through a ConfigDataStore
+// per project and service.  Stores are loaded once per render.
+type secretLookup struct {
+	driverConfig *eUtils.DriverConfig
+	modifier     *helperkv.Modifier
+	seeds        *SeedStore // Offline lookups with -novault.
+	secretMode   bool
+	region       string // Region being rendered, "" for the modifier's region.
+	stores       map[string]*ConfigDataStore
+}
+
+// TemplateFuncMap returns the function library registered on every template.
+// stores may be seeded with already initialized data stores keyed by "project/service".
+// A nil modifier provides a map suitable only for parsing templates.
+func TemplateFuncMap(driverConfig *eUtils.DriverConfig, modifier *helperkv.Modifier, secretMode bool, stores map[string]*ConfigDataStore) template.FuncMap {
+	return templateFuncMap(driverConfig, modifier, nil, secretMode, stores, "")
+}
+
+func templateFuncMap(driverConfig *eUtils.DriverConfig, modifier *helperkv.Modifier, seeds *SeedStore, secretMode bool, stores map[string]*ConfigDataStore, region string) template.FuncMap {
+	if stores == nil {
+		stores = map[string]*ConfigDataStore{}
+	}
+	lookup := &secretLookup{driverConfig: driverConfig, modifier: modifier, seeds: seeds, secretMode: secretMode, region: region, stores: stores}
+
+	return template.FuncMap{
+		"required":   required,
+		"default":    defaultValue,
+		"b64enc":     b64enc,
+		"b64dec":     b64dec,
+		"toJson":     toJson,
+		"toYaml":     toYaml,
+		"quote":      quote,
+		"indent":     indent,
+		"trimSuffix": trimSuffix,
+		"lower":      lower,
+		"upper":      upper,
+		"secret":     lookup.secret,
+	}
+}
+
+func (sl *secretLookup) secret(secretPath string) (string, error) {
+	parts := strings.Split(secretPath, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("secret lookup expects Project/Service/key: %s", secretPath)
+	}
+	if sl.modifier == nil || sl.driverConfig == nil || (sl.driverConfig.Token == "novault" && sl.seeds == nil) {
+		return "", fmt.Errorf("secret lookup requires vault access: %s", secretPath)
+	}
+	project, service, key := parts[0], parts[1], parts[2]
+
+	cds, ok := sl.stores[project+"/"+service]
+	if !ok {
+		cds = new(ConfigDataStore)
+		// Look up by project and service rather than the template being rendered.
+		templatePath := sl.modifier.TemplatePath
+		sl.modifier.TemplatePath = ""
+		var err error
+		if sl.seeds != nil {
+			err = cds.InitFromSeeds(&sl.driverConfig.CoreConfig, sl.seeds, sl.modifier, sl.secretMode, true, project, service)
+		} else {
+			err = cds.Init(&sl.driverConfig.CoreConfig, sl.modifier, sl.secretMode, true, project, nil, service)
+		}
+		sl.modifier.TemplatePath = templatePath
+		if err != nil {
+			return "", err
+		}
+		if sl.driverConfig.Preview {
+			cds.maskSecrets(service)
+		}
+		sl.stores[project+"/"+service] = cds
+	}
+
+	if value, ok := cds.GetServiceValue(service, key, sl.region); ok {
+		return value, nil
+	}
+	return "", fmt.Errorf("secret not found: %s", secretPath)
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case *string:
+		return v == nil || *v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	case bool:
+		return !v
+	}
+	return false
+}
+
+// required -- {{required "message" .key}} fails rendering when the value is empty.
+func required(message string, value interface{}) (interface{}, error) {
+	if isEmpty(value) {
+		return nil, errors.New(message)
+	}
+	return value, nil
+}
+
+// defaultValue -- {{.key | default "value"}} substitutes the default for empty values.
+func defaultValue(def interface{}, given ...interface{}) interface{} {
+	if len(given) == 0 || isEmpty(given[0]) {
+		return def
+	}
+	return given[0]
+}
+
+func b64enc(value interface{}) string {
+	return base64.StdEncoding.EncodeToString([]byte(toString(value)))
+}
+
+func b64dec(value interface{}) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(toString(value))
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+func toJson(value interface{}) (string, error) {
+	if strValue, ok := value.(*string); ok {
+		value = toString(strValue)
+	}
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+func toYaml(value interface{}) (string, error) {
+	if strValue, ok := value.(*string); ok {
+		value = toString(strValue)
+	}
+	yamlBytes, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(yamlBytes), "\n"), nil
+}
+
+func quote(values ...interface{}) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != nil {
+			quoted = append(quoted, strconv.Quote(toString(value)))
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// indent -- {{.block | indent 4}} pads every line of the value.
+func indent(spaces int, value interface{}) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(toString(value), "\n", "\n"+pad)
+}
+
+func trimSuffix(suffix string, value interface{}) string {
+	return strings.TrimSuffix(toString(value), suffix)
+}
+
+func lower(value interface{}) string {
+	return strings.ToLower(toString(value))
+}
+
+func upper(value interface{}) string {
+	return strings.ToUpper(toString(value))
+}
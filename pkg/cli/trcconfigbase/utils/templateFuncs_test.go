@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	data := map[string]interface{}{
+		"host":    "localhost",
+		"empty":   "",
+		"encoded": "aHVudGVyMjI=",
+		"block":   "a: 1\nb: 2",
+		"list":    []interface{}{"a", "b"},
+		"nested":  map[string]interface{}{"port": 8080},
+		"url":     "https://host/",
+	}
+	tests := []struct {
+		template string
+		rendered string
+	}{
+		{`{{required "host is required" .host}}`, "localhost"},
+		{`{{.empty | default "fallback"}}`, "fallback"},
+		{`{{.missing | default "fallback"}}`, "fallback"},
+		{`{{default "fallback" .host}}`, "localhost"},
+		{`{{b64enc "hunter22"}}`, "aHVudGVyMjI="},
+		{`{{b64dec .encoded}}`, "hunter22"},
+		{`{{toJson .list}}`, `["a","b"]`},
+		{`{{toJson .nested}}`, `{"port":8080}`},
+		{`{{toYaml .nested}}`, "port: 8080"},
+		{`{{quote .host}}`, `"localhost"`},
+		{`{{quote .host .missing "x"}}`, `"localhost" "x"`},
+		{`{{.block | indent 2}}`, "  a: 1\n  b: 2"},
+		{`{{.url | trimSuffix "/"}}`, "https://host"},
+		{`{{lower "LocalHost"}}`, "localhost"},
+		{`{{upper .host}}`, "LOCALHOST"},
+	}
+	for _, test := range tests {
+		tmpl, err := template.New("template").Funcs(TemplateFuncMap(nil, nil, false, nil)).Parse(test.template)
+		if err != nil {
+			t.Fatalf("%s: %v", test.template, err)
+		}
+		var doc bytes.Buffer
+		if err := tmpl.Execute(&doc, data); err != nil {
+			t.Errorf("%s: %v", test.template, err)
+			continue
+		}
+		if doc.String() != test.rendered {
+			t.Errorf("%s: rendered %q, want %q", test.template, doc.String(), test.rendered)
+		}
+	}
+}
+
+func TestTemplateFuncErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		err      string
+	}{
+		{`{{required "host is required" .empty}}`, "host is required"},
+		{`{{required "host is required" .missing}}`, "host is required"},
+		{`{{b64dec "not base64!"}}`, "illegal base64"},
+		{`{{secret "Project/Service"}}`, "expects Project/Service/key"},
+		{`{{secret "Project/Service/key"}}`, "requires vault access"},
+	}
+	for _, test := range tests {
+		tmpl := template.Must(template.New("template").Funcs(TemplateFuncMap(nil, nil, false, nil)).Parse(test.template))
+		err := tmpl.Execute(&bytes.Buffer{}, map[string]interface{}{"empty": ""})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error %v, want %q", test.template, err, test.err)
+		}
+	}
+}
+
+func TestGetServiceValueRegion(t *testing.T) {
+	cds := &ConfigDataStore{
+		dataMap: map[string]interface{}{
+			"Service": map[string]interface{}{
+				"config": map[string]interface{}{"host": "default.local", "host~east": "east.local", "host~west": "west.local"},
+			},
+		},
+		Regions: []string{"west"},
+	}
+	for region, expected := range map[string]string{"": "west.local", "east": "east.local", "west": "west.local", "ca": "default.local"} {
+		if value, ok := cds.GetServiceValue("Service", "host", region); !ok || value != expected {
+			t.Errorf("region %q: expected %s, got %s", region, expected, value)
+		}
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/trimble-oss/tierceron/atrium/vestibulum/trcdb/opts/prod"
 	"github.com/trimble-oss/tierceron/buildopts/coreopts"
@@ -15,8 +17,6 @@ import (
 	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
 	"github.com/trimble-oss/tierceron/pkg/validator"
 	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
-
-	"gopkg.in/yaml.v2"
 )
 
 // GetProjectService - returns project, service, and path to template on filesystem.
@@ -50,6 +50,23 @@ func GetProjectService(driverConfig *eUtils.DriverConfig, templateFile string) (
 // GetTemplate makes a request to the vault for the template found in <project>/<service>/<file>/template-file
 // Returns the template data in base64 and the template's extension. Returns any errors generated by vault
 func GetTemplate(driverConfig *eUtils.DriverConfig, modifier *helperkv.Modifier, templatePath string) (string, error) {
+	project, service, templateFile, path := templateVaultPath(driverConfig, modifier, templatePath)
+
+	data, err := modifier.ReadData(path)
+	if err != nil {
+		return "", err
+	}
+	if data == nil {
+		err := errors.New("Trouble with lookup to: " + templatePath + " No file " + templateFile + " under " + project + "/" + service)
+		return "", err
+	}
+
+	// Return retrieved data in response
+	return data["data"].(string), nil
+}
+
+// templateVaultPath - the project, service and file name of a template and the vault path it is published to.
+func templateVaultPath(driverConfig *eUtils.DriverConfig, modifier *helperkv.Modifier, templatePath string) (string, string, string, string) {
 	// Get template data from information in request.
 	//  ./trc_templates/Project/Service/configfile.yml.tmpl
 	project, service, templateFile := GetProjectService(driverConfig, templatePath)
@@ -87,18 +104,7 @@ func GetTemplate(driverConfig *eUtils.DriverConfig, modifier *helperkv.Modifier,
 			path = "templates/" + project + "/" + service + "/" + templateFile + "/template-file"
 		}
 	}
-
-	data, err := modifier.ReadData(path)
-	if err != nil {
-		return "", err
-	}
-	if data == nil {
-		err := errors.New("Trouble with lookup to: " + templatePath + " No file " + templateFile + " under " + project + "/" + service)
-		return "", err
-	}
-
-	// Return retrieved data in response
-	return data["data"].(string), nil
+	return project, service, templateFile, path
 }
 
 // ConfigTemplateRaw - gets a raw unpopulated template.
@@ -124,6 +130,33 @@ func ConfigTemplate(driverConfig *eUtils.DriverConfig,
 	service string,
 	cert bool,
 	zc bool) (string, map[int]string, bool, error) {
+	template, _, certData, certLoaded, err := configTemplate(driverConfig, modifier, emptyFilePath, secretMode, project, service, cert, zc, nil)
+	return template, certData, certLoaded, err
+}
+
+// ConfigTemplateRegions configures a template once for each region from a single load of its values.
+// Returns the configured template by region.  Keys that vary by region are added to driverConfig.RegionVariances.
+func ConfigTemplateRegions(driverConfig *eUtils.DriverConfig,
+	modifier *helperkv.Modifier,
+	emptyFilePath string,
+	secretMode bool,
+	project string,
+	service string,
+	regions []string,
+	zc bool) (map[string]string, error) {
+	_, regionTemplates, _, _, err := configTemplate(driverConfig, modifier, emptyFilePath, secretMode, project, service, false, zc, regions)
+	return regionTemplates, err
+}
+
+func configTemplate(driverConfig *eUtils.DriverConfig,
+	modifier *helperkv.Modifier,
+	emptyFilePath string,
+	secretMode bool,
+	project string,
+	service string,
+	cert bool,
+	zc bool,
+	regions []string) (string, map[string]string, map[int]string, bool, error) {
 	var template string
 	var err error
 
@@ -140,19 +173,31 @@ func ConfigTemplate(driverConfig *eUtils.DriverConfig,
 		driverConfig.CoreConfig.Log.Println("Configuring cert")
 	}
 
+	if !zc && !cert && !driverConfig.AsOf.IsZero() {
+		// Configure from the template as it was published at that instant when available.
+		if templateEncoded, asOfErr := GetTemplate(driverConfig, modifier, emptyFilePath); asOfErr == nil {
+			if templateBytes, dcErr := base64.StdEncoding.DecodeString(templateEncoded); dcErr == nil {
+				template = string(templateBytes)
+			}
+		}
+		if template == "" {
+			eUtils.LogInfo(&driverConfig.CoreConfig, "No published template as of "+driverConfig.AsOf.Format(time.RFC3339)+" for "+emptyFilePath+".  Using local template.")
+		}
+	}
+
 	if zc {
 		var templateEncoded string
 		templateEncoded, err = GetTemplate(driverConfig, modifier, emptyFilePath)
 		if err != nil {
-			return "", nil, false, err
+			return "", nil, nil, false, err
 		}
 		templateBytes, dcErr := base64.StdEncoding.DecodeString(templateEncoded)
 		if dcErr != nil {
-			return "", nil, false, dcErr
+			return "", nil, nil, false, dcErr
 		}
 
 		template = string(templateBytes)
-	} else {
+	} else if template == "" {
 		emptyTemplate, err := os.ReadFile(emptyFilePath)
 		eUtils.CheckError(&driverConfig.CoreConfig, err, true)
 		template = string(emptyTemplate)
@@ -160,11 +205,25 @@ func ConfigTemplate(driverConfig *eUtils.DriverConfig,
 	// cert map
 	certData := make(map[int]string)
 	if cert && !strings.Contains(template, ".certData") {
-		return "", certData, false, errors.New("missing .certData")
+		return "", nil, certData, false, errors.New("missing .certData")
 	} else if !cert && strings.Contains(template, ".certData") {
-		return "", certData, false, errors.New("template with cert provided, but cert not requested: " + emptyFilePath)
+		return "", nil, certData, false, errors.New("template with cert provided, but cert not requested: " + emptyFilePath)
 	}
 
+	filename := templateValuesName(driverConfig, emptyFilePath, project, service)
+
+	if !cert {
+		loadPartials(driverConfig, modifier, emptyFilePath, zc)
+	}
+	//populate template
+	var regionTemplates map[string]string
+	template, regionTemplates, certData, err = populateTemplate(driverConfig, template, modifier, secretMode, project, service, filename, cert, regions)
+	return template, regionTemplates, certData, true, err
+}
+
+// templateValuesName - name the values for a template are stored under: the file name
+// without extensions, prefixed by any directories nested below the service.
+func templateValuesName(driverConfig *eUtils.DriverConfig, emptyFilePath string, project string, service string) string {
 	// Construct path for vault
 	s := strings.Split(emptyFilePath, "/")
 
@@ -194,9 +253,35 @@ func ConfigTemplate(driverConfig *eUtils.DriverConfig,
 	if extra != "" {
 		filename = extra + "/" + filename
 	}
-	//populate template
-	template, certData, err = PopulateTemplate(driverConfig, template, modifier, secretMode, project, service, filename, cert)
-	return template, certData, true, err
+	return filename
+}
+
+// readCertPassword looks up a cert password.  certPasswordVaultPath names the
+// secret and key holding the password: super-secrets/Project/Service/file/passwordKey
+func readCertPassword(modifier interface {
+	ReadValue(path string, key string) (string, error)
+}, certPasswordVaultPath string) (string, error) {
+	lastSlash := strings.LastIndex(certPasswordVaultPath, "/")
+	if lastSlash <= 0 || lastSlash == len(certPasswordVaultPath)-1 {
+		return "", errors.New("certPasswordVaultPath must be <vault path>/<key>: " + certPasswordVaultPath)
+	}
+	return modifier.ReadValue(certPasswordVaultPath[:lastSlash], certPasswordVaultPath[lastSlash+1:])
+}
+
+// auditCert adds the audit of a decoded certificate to the run's cert report.
+func auditCert(driverConfig *eUtils.DriverConfig, project string, service string, certName string, decoded []byte, certPassword string) {
+	audit, err := validator.AuditCertificateBytes(decoded, certPassword, time.Now())
+	if err != nil {
+		audit = &eUtils.CertAudit{KeyMatch: "n/a", Problems: []string{err.Error()}}
+	} else if audit == nil {
+		eUtils.LogInfo(&driverConfig.CoreConfig, "No certificate in "+certName+" to audit.")
+		return
+	}
+	audit.Project = project
+	audit.Service = service
+	audit.Cert = certName
+	audit.Env = strings.TrimSuffix(driverConfig.Env, "_0")
+	driverConfig.CertReport.Add(*audit)
 }
 
 func getTemplateVersionData(config *core.CoreConfig, modifier *helperkv.Modifier, project string, service string, file string) (map[string]interface{}, error) {
@@ -214,45 +299,36 @@ func PopulateTemplate(driverConfig *eUtils.DriverConfig,
 	service string,
 	filename string,
 	cert bool) (string, map[int]string, error) {
-	values := make(map[string]interface{}, 0)
-	ok := false
+	str, _, certData, err := populateTemplate(driverConfig, emptyTemplate, modifier, secretMode, project, service, filename, cert, nil)
+	return str, certData, err
+}
+
+// populateTemplate populates the template once, or once per region when regions are provided.
+func populateTemplate(driverConfig *eUtils.DriverConfig,
+	emptyTemplate string,
+	modifier *helperkv.Modifier,
+	secretMode bool,
+	project string,
+	service string,
+	filename string,
+	cert bool,
+	regions []string) (string, map[string]string, map[int]string, error) {
 	str := emptyTemplate
 	cds := new(ConfigDataStore)
+	var seeds *SeedStore
 	if driverConfig.Token != "novault" {
 		cds.Init(&driverConfig.CoreConfig, modifier, secretMode, true, project, nil, service)
 	} else {
-		rawFile, err := os.ReadFile(strings.Split(driverConfig.StartDir[0], coreopts.BuildOptions.GetFolderPrefix(driverConfig.StartDir)+"_")[0] + coreopts.BuildOptions.GetFolderPrefix(driverConfig.StartDir) + "_seeds/" + driverConfig.Env + "/" + driverConfig.Env + "_seed.yml")
-		if err != nil {
-			eUtils.LogErrorObject(&driverConfig.CoreConfig, errors.New("unable to open seed file for -novault: " + err.Error()), false)
+		var seedErr error
+		seeds, seedErr = loadSeedStore(driverConfig)
+		if seedErr != nil {
+			eUtils.LogErrorObject(&driverConfig.CoreConfig, seedErr, false)
+			return "", nil, nil, seedErr
 		}
-
-		var rawYaml interface{}
-		err = yaml.Unmarshal(rawFile, &rawYaml)
-		if err != nil {
-			eUtils.LogErrorAndSafeExit(&driverConfig.CoreConfig, err, 1)
+		if seedErr = cds.InitFromSeeds(&driverConfig.CoreConfig, seeds, modifier, secretMode, true, project, service); seedErr != nil {
+			eUtils.LogErrorObject(&driverConfig.CoreConfig, seedErr, false)
+			return "", nil, nil, seedErr
 		}
-
-		seed, seedOk := rawYaml.(map[interface{}]interface{})
-		if !seedOk {
-			eUtils.LogAndSafeExit(&driverConfig.CoreConfig, "Invalid yaml file.  Refusing to continue.", 1)
-		}
-		tempMap := make(map[string]interface{}, 0)
-		for seedSectionKey, seedSection := range seed {
-			if seedSectionKey.(string) == "templates" {
-				continue
-			}
-			for _, seedSubSection := range seedSection.(map[interface{}]interface{}) {
-				for k, v := range seedSubSection.(map[interface{}]interface{}) {
-					values[k.(string)] = v
-				}
-			}
-		}
-		if len(values) == 0 {
-			eUtils.LogAndSafeExit(&driverConfig.CoreConfig, "Invalid yaml file.  Refusing to continue.", 1)
-		}
-		tempMap[filename] = values
-		values = tempMap
-		ok = true
 	}
 	certData := make(map[int]string)
 	serviceLookup := service
@@ -261,18 +337,28 @@ func PopulateTemplate(driverConfig *eUtils.DriverConfig,
 		serviceLookup = service[:i]
 	}
 
-	if len(values) == 0 {
-		values, ok = cds.dataMap[serviceLookup].(map[string]interface{})
+	if driverConfig.Preview {
+		cds.maskSecrets(serviceLookup)
 	}
+	values, ok := cds.dataMap[serviceLookup].(map[string]interface{})
 
 	if ok {
 		//create new template from template string
-		t := template.New("template")
+		stores := map[string]*ConfigDataStore{project + "/" + serviceLookup: cds}
+		t := template.New("template").Funcs(templateFuncMap(driverConfig, modifier, seeds, secretMode, stores, ""))
 		t, err := t.Parse(emptyTemplate)
 		if err != nil {
 			eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
 		}
-		var doc bytes.Buffer
+		partialTemplates := ""
+		if t != nil && len(driverConfig.Partials) > 0 {
+			if partialErr := addPartials(t, driverConfig.Partials); partialErr != nil {
+				eUtils.LogErrorObject(&driverConfig.CoreConfig, partialErr, false)
+			}
+			for _, partial := range driverConfig.Partials {
+				partialTemplates = partialTemplates + partial
+			}
+		}
 		//configure the template
 
 		//Check if filename exists in values map
@@ -280,9 +366,12 @@ func PopulateTemplate(driverConfig *eUtils.DriverConfig,
 		_, hasData := values[filename]
 		if !hasData && !driverConfig.CoreConfig.WantCerts {
 			eUtils.LogInfo(&driverConfig.CoreConfig, filename+" does not exist in values. Please check seed files to verify that folder structures are correct.")
+			if driverConfig.Strict {
+				driverConfig.UnresolvedKeys.Add(eUtils.UnresolvedKey{Project: project, Service: service, Template: filename, Key: "*", Env: driverConfig.Env})
+			}
 		}
 
-		if len(cds.Regions) > 0 {
+		if len(cds.Regions) > 0 && regions == nil {
 			if serviceValues, ok := values[filename]; ok {
 				valueData := serviceValues.(map[string]interface{})
 				for valueKey, valueEntry := range valueData {
@@ -298,6 +387,16 @@ func PopulateTemplate(driverConfig *eUtils.DriverConfig,
 			}
 		}
 
+		if driverConfig.DiffSecrets != nil {
+			if fileValues, ok := values[filename].(map[string]interface{}); ok {
+				for valueKey := range fileValues {
+					if _, isSecret := cds.secretKeys[serviceLookup+"/"+filename+"/"+valueKey]; isSecret {
+						driverConfig.DiffSecrets.AddTemplateKey(emptyTemplate, valueKey)
+					}
+				}
+			}
+		}
+
 		if cert {
 			if serviceValues, ok := values[serviceLookup]; ok {
 				valueData := serviceValues.(map[string]interface{})
@@ -314,14 +413,18 @@ func PopulateTemplate(driverConfig *eUtils.DriverConfig,
 					if !ok {
 						vaultCertErr := errors.New("No certDestPath in config template section of seed for this service. Unable to generate: " + certDestPath.(string))
 						eUtils.LogErrorMessage(&driverConfig.CoreConfig, vaultCertErr.Error(), false)
-						return "", nil, vaultCertErr
+						return "", nil, nil, vaultCertErr
+					}
+					if driverConfig.Preview {
+						eUtils.LogInfo(&driverConfig.CoreConfig, "Preview: certificate "+certDestPath.(string)+" skipped.  Certificates and keystores are not previewed.")
+						return "", nil, nil, nil
 					}
 					certData[0] = certDestPath.(string)
 					data, ok := valueData["certData"]
 					if !ok {
 						vaultCertErr := errors.New("No certData in config template section of seed for this service. Unable to generate: " + certDestPath.(string))
 						eUtils.LogInfo(&driverConfig.CoreConfig, vaultCertErr.Error())
-						return "", nil, vaultCertErr
+						return "", nil, nil, vaultCertErr
 					}
 					encoded := fmt.Sprintf("%s", data)
 					//Decode cert as it was encoded in trcinit
@@ -330,30 +433,45 @@ func PopulateTemplate(driverConfig *eUtils.DriverConfig,
 						eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
 					}
 
-					// Add support for jks encoding...
-					if hasCertBundleJks && driverConfig.WantKeystore != "" {
-						certPassword := ""
-						if hasCertPasswordVaultPath {
-							if certPasswordVaultPath != "" {
-								// TODO: Take path defined here and look up in vault the password to use to decrypt a cert.
-								// certPassword = from vault...
-								certPasswordVaultPath = ""
+					wantKeystore := hasCertBundleJks && driverConfig.WantKeystore != ""
+					certPassword := ""
+					if hasCertPasswordVaultPath && (wantKeystore || driverConfig.CertReport != nil) {
+						if passwordVaultPath, ok := certPasswordVaultPath.(string); ok && passwordVaultPath != "" {
+							var passwordErr error
+							if seeds != nil {
+								certPassword, passwordErr = readCertPassword(seeds, passwordVaultPath)
+							} else {
+								certPassword, passwordErr = readCertPassword(modifier, passwordVaultPath)
+							}
+							if passwordErr != nil {
+								eUtils.LogErrorObject(&driverConfig.CoreConfig, passwordErr, false)
+								return "", nil, nil, passwordErr
 							}
 						}
+					}
+
+					if driverConfig.CertReport != nil {
+						// Audit only.  Nothing is written.
+						auditCert(driverConfig, project, service, certData[0], decoded, certPassword)
+						return "", nil, nil, nil
+					}
+
+					// Add support for jks encoding...
+					if wantKeystore {
 						// This needs to be wrapped in a jks first.
 						ksErr := validator.AddToKeystore(driverConfig, certSourcePath.(string), []byte(certPassword), certBundleJks.(string), decoded)
 						if ksErr != nil {
-							eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
-							return "", nil, ksErr
+							eUtils.LogErrorObject(&driverConfig.CoreConfig, ksErr, false)
+							return "", nil, nil, ksErr
 						} else {
-							return "", nil, nil
+							return "", nil, nil, nil
 						}
 					} else {
 						certData[1] = string(decoded)
 					}
 
 					certData[2] = certSourcePath.(string)
-					return "", certData, nil
+					return "", nil, certData, nil
 				}
 			}
 		}
@@ -367,11 +485,151 @@ func PopulateTemplate(driverConfig *eUtils.DriverConfig,
 			}
 		}
 
-		err = t.Execute(&doc, values[filename])
-		str = doc.String()
-		if err != nil {
-			eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+		render := func(data interface{}, env string) (string, error) {
+			if driverConfig.Strict {
+				rendered, missingKeys, err := executeStrict(t, emptyTemplate+partialTemplates, data)
+				for _, missingKey := range missingKeys {
+					driverConfig.UnresolvedKeys.Add(eUtils.UnresolvedKey{Project: project, Service: service, Template: filename, Key: missingKey, Env: env})
+				}
+				if err != nil {
+					eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+				}
+				if len(missingKeys) > 0 || (!hasData && !driverConfig.CoreConfig.WantCerts) {
+					return rendered, eUtils.ErrUnresolvedKeys
+				}
+				return rendered, nil
+			}
+
+			var doc bytes.Buffer
+			err := t.Execute(&doc, data)
+			if err != nil {
+				eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+			}
+			return doc.String(), nil
+		}
+
+		if regions != nil {
+			fileValues, _ := values[filename].(map[string]interface{})
+			for _, variance := range regionVariances(fileValues, regions) {
+				variance.Project = project
+				variance.Service = service
+				variance.Template = filename
+				driverConfig.RegionVariances.Add(variance)
+			}
+			regionTemplates := map[string]string{}
+			var renderErr error
+			for _, region := range regions {
+				// Looked up values follow the region as well.
+				if t != nil {
+					t.Funcs(templateFuncMap(driverConfig, modifier, seeds, secretMode, stores, region))
+				}
+				regionTemplate, err := render(regionValues(fileValues, region), driverConfig.Env+"~"+region)
+				if err != nil {
+					renderErr = err
+				}
+				regionTemplates[region] = regionTemplate
+			}
+			return "", regionTemplates, certData, renderErr
+		}
+
+		str, err = render(values[filename], driverConfig.Env)
+		return str, nil, certData, err
+	}
+	return str, nil, certData, nil
+}
+
+// regionValues - copy of a template's values with each key replaced by its ~region variant, if any.
+func regionValues(values map[string]interface{}, region string) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	regionSuffix := "~" + region
+	regionValues := make(map[string]interface{}, len(values))
+	for valueKey, valueEntry := range values {
+		regionValues[valueKey] = valueEntry
+	}
+	for valueKey, valueEntry := range values {
+		if strings.HasSuffix(valueKey, regionSuffix) {
+			baseKey := strings.TrimSuffix(valueKey, regionSuffix)
+			if _, ok := values[baseKey]; ok {
+				regionValues[baseKey] = valueEntry
+			}
+		}
+	}
+	return regionValues
+}
+
+// regionVariances finds the keys whose value differs between the regions.
+func regionVariances(values map[string]interface{}, regions []string) []eUtils.RegionVariance {
+	variances := []eUtils.RegionVariance{}
+	for valueKey, baseValue := range values {
+		if strings.Contains(valueKey, "~") || valueKey == "trcEnvParam" {
+			continue
+		}
+		overridden := []string{}
+		distinct := map[string]bool{}
+		for _, region := range regions {
+			regionValue, ok := values[valueKey+"~"+region]
+			if !ok {
+				regionValue = baseValue
+			}
+			distinct[toString(regionValue)] = true
+			if ok && toString(regionValue) != toString(baseValue) {
+				overridden = append(overridden, region)
+			}
+		}
+		if len(distinct) > 1 {
+			variances = append(variances, eUtils.RegionVariance{Key: valueKey, Regions: overridden})
+		}
+	}
+	return variances
+}
+
+var missingKeyRegex = regexp.MustCompile(`no entry for key "([^"]*)"`)
+
+// {{or .<key> "<value>"}}, {{.<key> | default "<value>"}} and {{default "<value>" .<key>}} --
+// keys with defaults are never unresolved.
+var defaultedKeyRegexes = []*regexp.Regexp{
+	regexp.MustCompile(`{{-?\s*or\s+\.([A-Za-z0-9_]+)`),
+	regexp.MustCompile(`{{-?\s*\.([A-Za-z0-9_]+)\s*\|\s*default\s`),
+	regexp.MustCompile(`{{-?\s*default\s+(?:"[^"]*"|\S+)\s+\.([A-Za-z0-9_]+)`),
+}
+
+// executeStrict executes the template treating missing keys as errors.  Each missing key
+// is recorded and stubbed out so a single render reports every unresolved key in the template.
+func executeStrict(t *template.Template, emptyTemplate string, data interface{}) (string, []string, error) {
+	t.Option("missingkey=error")
+	stubbedData := map[string]interface{}{}
+	if dataMap, ok := data.(map[string]interface{}); ok {
+		for k, v := range dataMap {
+			stubbedData[k] = v
+		}
+	}
+	for _, defaultedKeyRegex := range defaultedKeyRegexes {
+		for _, defaulted := range defaultedKeyRegex.FindAllStringSubmatch(emptyTemplate, -1) {
+			if _, ok := stubbedData[defaulted[1]]; !ok {
+				stubbedData[defaulted[1]] = nil
+			}
+		}
+	}
+	missingKeys := []string{}
+
+	for {
+		var doc bytes.Buffer
+		err := t.Execute(&doc, stubbedData)
+		if err == nil {
+			return doc.String(), missingKeys, nil
+		}
+		match := missingKeyRegex.FindStringSubmatch(err.Error())
+		if match == nil {
+			return doc.String(), missingKeys, err
+		}
+		if _, stubbed := stubbedData[match[1]]; stubbed {
+			// Nested lookup that stubbing can't satisfy.
+			missingKeys = append(missingKeys, match[1])
+			return doc.String(), missingKeys, err
 		}
+		missingKeys = append(missingKeys, match[1])
+		stubbedData[match[1]] = ""
 	}
-	return str, certData, nil
 }
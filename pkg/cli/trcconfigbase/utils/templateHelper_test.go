@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"text/template"
+)
+
+func TestExecuteStrict(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		data        map[string]interface{}
+		rendered    string
+		missingKeys []string
+	}{
+		{"resolved", `{{.host}}:{{.port}}`, map[string]interface{}{"host": "localhost", "port": "8080"}, "localhost:8080", []string{}},
+		{"missing", `{{.host}}:{{.port}}/{{.path}}`, map[string]interface{}{"host": "localhost"}, "localhost:/", []string{"port", "path"}},
+		{"or default", `{{or .host "localhost"}}`, map[string]interface{}{}, "localhost", []string{}},
+		{"piped default", `{{.host | default "localhost"}}`, map[string]interface{}{}, "localhost", []string{}},
+		{"default", `{{default "localhost" .host}}`, map[string]interface{}{}, "localhost", []string{}},
+		{"trimmed default", `{{- or .host "localhost" -}}`, map[string]interface{}{}, "localhost", []string{}},
+		{"default elsewhere", `{{or .host "localhost"}}:{{.port}}`, map[string]interface{}{}, "localhost:", []string{"port"}},
+	}
+	for _, test := range tests {
+		tmpl := template.Must(template.New("template").Funcs(TemplateFuncMap(nil, nil, false, nil)).Parse(test.template))
+		rendered, missingKeys, err := executeStrict(tmpl, test.template, test.data)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if rendered != test.rendered {
+			t.Errorf("%s: rendered %q, want %q", test.name, rendered, test.rendered)
+		}
+		if !reflect.DeepEqual(missingKeys, test.missingKeys) {
+			t.Errorf("%s: missing keys %v, want %v", test.name, missingKeys, test.missingKeys)
+		}
+	}
+}
+
+func TestDefaultedKeyRegexes(t *testing.T) {
+	tests := map[string]string{
+		`{{or .host "localhost"}}`:     "host",
+		`{{- or .host_name "x"}}`:      "host_name",
+		`{{.port | default "8080"}}`:   "port",
+		`{{ .port|default 8080 }}`:     "port",
+		`{{default "8080" .port}}`:     "port",
+		`{{default .fallback .port}}`:  "port",
+		`{{- default "a b" .port2 -}}`: "port2",
+	}
+	for template, key := range tests {
+		found := []string{}
+		for _, defaultedKeyRegex := range defaultedKeyRegexes {
+			for _, match := range defaultedKeyRegex.FindAllStringSubmatch(template, -1) {
+				found = append(found, match[1])
+			}
+		}
+		if len(found) != 1 || found[0] != key {
+			t.Errorf("%s: defaulted keys %v, want [%s]", template, found, key)
+		}
+	}
+
+	for _, template := range []string{`{{.host}}`, `{{required "host" .host}}`, `{{.host | quote}}`} {
+		for _, defaultedKeyRegex := range defaultedKeyRegexes {
+			if defaultedKeyRegex.MatchString(template) {
+				t.Errorf("%s: unexpectedly defaulted by %s", template, defaultedKeyRegex)
+			}
+		}
+	}
+}
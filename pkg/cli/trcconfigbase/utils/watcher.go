@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+)
+
+// watchTarget is a configured template along with the vault paths and local template file state it depends on.
+type watchTarget struct {
+	eUtils.RenderedTemplate
+	paths       []string
+	templateMod time.Time
+}
+
+// WatchConfigs polls the version metadata of every template, value and secret path the configured
+// templates depend on.  When a version number changes only the affected templates are reconfigured,
+// after which onChange, if provided, is run.  Does not return unless the watch can't start.
+func WatchConfigs(driverConfig *eUtils.DriverConfig, interval time.Duration, onChange string) error {
+	renderedTemplates := driverConfig.RenderedTemplates.List()
+	if len(renderedTemplates) == 0 {
+		return errors.New("no configured templates to watch")
+	}
+
+	mod, err := helperkv.NewModifier(driverConfig.Insecure, driverConfig.Token, driverConfig.VaultAddress, driverConfig.EnvRaw, driverConfig.Regions, true, driverConfig.CoreConfig.Log)
+	if err != nil {
+		eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+		return err
+	}
+	defer mod.Close()
+	mod.Env = eUtils.SplitEnv(driverConfig.Env)[0]
+	mod.Version = "0"
+
+	targets := []*watchTarget{}
+	for _, renderedTemplate := range renderedTemplates {
+		target := &watchTarget{RenderedTemplate: renderedTemplate}
+		target.refresh(driverConfig, mod)
+		targets = append(targets, target)
+	}
+	versions := pathVersions(driverConfig, mod, targets, nil)
+	eUtils.LogInfo(&driverConfig.CoreConfig, fmt.Sprintf("Watching %d templates every %s", len(targets), interval))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		currentVersions := pathVersions(driverConfig, mod, targets, versions)
+		generation := newOutputGeneration(driverConfig)
+		reconfigured := 0
+		for _, target := range targets {
+			if !target.changed(versions, currentVersions) {
+				continue
+			}
+			if reconfigured == 0 {
+				// Pick up any partial changes as well.
+				driverConfig.Partials = nil
+			}
+			if target.reconfigure(driverConfig, mod, generation) {
+				reconfigured++
+			}
+			target.refresh(driverConfig, mod)
+		}
+		if err := generation.commit(driverConfig); err != nil {
+			generation.abandon()
+			eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+			reconfigured = 0
+		}
+		if reconfigured > 0 {
+			// Reconfigured templates may link to new secrets.
+			versions = pathVersions(driverConfig, mod, targets, currentVersions)
+		} else {
+			versions = currentVersions
+		}
+
+		if reconfigured > 0 && onChange != "" {
+			runOnChange(driverConfig, onChange)
+		}
+	}
+	return nil
+}
+
+// refresh determines the vault paths a template depends on: its published template, its values
+// and every secret those values link to.
+func (target *watchTarget) refresh(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier) {
+	templatePath := mod.TemplatePath
+	mod.TemplatePath = ""
+	_, _, _, publishedPath := templateVaultPath(driverConfig, mod, target.TemplatePath)
+	mod.TemplatePath = templatePath
+
+	valuePath := "values/" + target.Project + "/" + target.Service + "/" + strings.TrimPrefix(templateValuesName(driverConfig, target.TemplatePath, target.Project, target.Service), "/")
+	target.paths = []string{publishedPath, valuePath}
+
+	values, err := mod.ReadData(valuePath)
+	if err == nil {
+		buckets := map[string]bool{}
+		for _, value := range values {
+			if link, ok := value.([]interface{}); ok && len(link) > 0 {
+				if bucket, ok := link[0].(string); ok && !buckets[bucket] {
+					buckets[bucket] = true
+					target.paths = append(target.paths, bucket)
+				}
+			}
+		}
+	}
+	if fileInfo, statErr := os.Stat(target.TemplatePath); statErr == nil {
+		target.templateMod = fileInfo.ModTime()
+	}
+}
+
+// changed - true if a dependent path, the published template included, has a new version or the
+// local template file was modified.
+func (target *watchTarget) changed(versions map[string]int, currentVersions map[string]int) bool {
+	for _, path := range target.paths {
+		if versions[path] != currentVersions[path] {
+			return true
+		}
+	}
+	if fileInfo, statErr := os.Stat(target.TemplatePath); statErr == nil && !fileInfo.ModTime().Equal(target.templateMod) {
+		return true
+	}
+	return false
+}
+
+// reconfigure configures the template again and writes it over the prior output.
+func (target *watchTarget) reconfigure(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier, generation *outputGeneration) bool {
+	configuredTemplate, _, _, err := ConfigTemplate(driverConfig, mod, target.TemplatePath, driverConfig.SecretMode, target.Project, target.Service, false, driverConfig.ZeroConfig)
+	if err != nil {
+		eUtils.LogErrorObject(&driverConfig.CoreConfig, fmt.Errorf("unable to reconfigure %s: %v", target.EndPath, err), false)
+		return false
+	}
+	generation.writeToFile(driverConfig, configuredTemplate, target.EndPath)
+	eUtils.LogInfo(&driverConfig.CoreConfig, "template reconfigured and written to "+target.EndPath)
+	return true
+}
+
+// pathVersions reads the current version of every path the targets depend on.
+// Paths that can't be read keep their prior version.
+func pathVersions(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier, targets []*watchTarget, priorVersions map[string]int) map[string]int {
+	versions := map[string]int{}
+	for _, target := range targets {
+		for _, path := range target.paths {
+			if _, ok := versions[path]; ok {
+				continue
+			}
+			versionMetadata, err := mod.ReadVersionMetadata(path, driverConfig.CoreConfig.Log)
+			if err != nil {
+				versions[path] = priorVersions[path]
+				continue
+			}
+			latest := 0
+			for versionKey := range versionMetadata {
+				if version, convErr := strconv.Atoi(versionKey); convErr == nil && version > latest {
+					latest = version
+				}
+			}
+			versions[path] = latest
+		}
+	}
+	return versions
+}
+
+func runOnChange(driverConfig *eUtils.DriverConfig, onChange string) {
+	var cmd *exec.Cmd
+	if eUtils.IsWindows() {
+		cmd = exec.Command("cmd", "/C", onChange)
+	} else {
+		cmd = exec.Command("sh", "-c", onChange)
+	}
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		driverConfig.CoreConfig.Log.Println(string(output))
+	}
+	if err != nil {
+		eUtils.LogErrorObject(&driverConfig.CoreConfig, fmt.Errorf("onChange failed: %v", err), false)
+		return
+	}
+	eUtils.LogInfo(&driverConfig.CoreConfig, "onChange completed: "+onChange)
+}
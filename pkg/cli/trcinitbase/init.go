@@ -69,6 +69,8 @@ func CommonMain(envPtr *string,
 	roleFileFilterPtr := flagset.String("approle", "", "Filter files for approle rotation.")
 	dynamicPathPtr := flagset.String("dynamicPath", "", "Seed a specific directory in vault.")
 	nestPtr := flagset.Bool("nest", false, "Seed a specific directory in vault.")
+	casPtr := flagset.Bool("cas", false, "Fail seeding when a path was changed by someone else after seeding began.")
+	eUtils.InitVaultFlags(flagset)
 
 	// indexServiceExtFilterPtr := flag.String("serviceExtFilter", "", "Specifies which nested services (or tables) to filter") //offset or database
 	// indexServiceFilterPtr := flag.String("serviceFilter", "", "Specifies which services (or tables) to filter")              // Table names
@@ -87,6 +89,7 @@ func CommonMain(envPtr *string,
 	}
 	eUtils.CheckInitFlags(flagset)
 	flagset.Parse(argLines[1:])
+	eUtils.CheckVaultFlags()
 	if memonly.IsMemonly() {
 		memprotectopts.MemUnprotectAll(nil)
 		memprotectopts.MemProtect(nil, tokenPtr)
@@ -580,6 +583,11 @@ func CommonMain(envPtr *string,
 
 	//TODO: Figure out raft storage initialization for -new flag
 	if *newPtr {
+		if namespace := helperkv.GetVaultNamespace(); namespace != "" {
+			err = v.CreateNamespace(namespace)
+			eUtils.LogErrorObject(&driverConfig.CoreConfig, err, true)
+			logger.Printf("Vault namespace %s ready\n", namespace)
+		}
 		mod, err := helperkv.NewModifier(*insecurePtr, v.GetToken(), *addrPtr, "nonprod", nil, true, logger) // Connect to vault
 		if mod != nil {
 			defer mod.Release()
@@ -802,6 +810,7 @@ func CommonMain(envPtr *string,
 			StartDir:        append([]string{}, *seedPtr),
 			EndDir:          "",
 			GenAuth:         false,
+			CheckAndSet:     *casPtr,
 		}
 
 		il.SeedVault(dConfig)
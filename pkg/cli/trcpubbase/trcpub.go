@@ -54,9 +54,11 @@ func CommonMain(envPtr *string,
 	logFilePtr := flagset.String("log", "./"+coreopts.BuildOptions.GetFolderPrefix(nil)+"pub.log", "Output path for log files")
 	appRolePtr := flagset.String("approle", "configpub.yml", "Name of auth config file - example.yml (optional)")
 	filterTemplatePtr := flagset.String("templateFilter", "", "Specifies which templates to filter")
+	eUtils.InitVaultFlags(flagset)
 
 	if driverConfig == nil || !driverConfig.IsShellSubProcess {
 		flagset.Parse(argLines[1:])
+		eUtils.CheckVaultFlags()
 	} else {
 		flagset.Parse(nil)
 	}
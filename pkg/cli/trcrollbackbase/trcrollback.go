@@ -0,0 +1,191 @@
+package trcrollbackbase
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/trimble-oss/tierceron/buildopts/coreopts"
+	"github.com/trimble-oss/tierceron/buildopts/memonly"
+	"github.com/trimble-oss/tierceron/buildopts/memprotectopts"
+	"github.com/trimble-oss/tierceron/pkg/core"
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+)
+
+// RollbackFlags are the flags specific to rollback.
+type RollbackFlags struct {
+	PathPtr     *string
+	VersionPtr  *int
+	AsOfPtr     *string
+	YesPtr      *bool
+	PingPtr     *bool
+	InsecurePtr *bool
+	LogFilePtr  *string
+}
+
+// NewRollbackFlags defines rollback's flags on flagset.  They are defined before flagset is parsed.
+func NewRollbackFlags(flagset *flag.FlagSet) *RollbackFlags {
+	return &RollbackFlags{
+		PathPtr:     flagset.String("path", "", "Path to roll back: super-secrets/Project/Service or values/..."),
+		VersionPtr:  flagset.Int("version", 0, "Version to roll back to"),
+		AsOfPtr:     flagset.String("asOf", "", "Roll back to the version current at this time, RFC3339: 2026-10-01T12:00:00Z"),
+		YesPtr:      flagset.Bool("yes", false, "Roll back without asking for confirmation"),
+		PingPtr:     flagset.Bool("ping", false, "Ping vault."),
+		InsecurePtr: flagset.Bool("insecure", false, "By default, every ssl connection this tool makes is verified secure.  This option allows to tool to continue with server connections considered insecure."),
+		LogFilePtr:  flagset.String("log", "./"+coreopts.BuildOptions.GetFolderPrefix(nil)+"rollback.log", "Output path for log files"),
+	}
+}
+
+// Rolls a values or super-secrets path back to an earlier version.  The data of that version is
+// written as a new version after showing how it differs from the current data.  Super-secrets
+// are masked in the diff.
+func CommonMain(envPtr *string, addrPtr *string, tokenPtr *string, envCtxPtr *string,
+	secretIDPtr *string,
+	appRoleIDPtr *string,
+	tokenNamePtr *string,
+	flags *RollbackFlags) error {
+	if memonly.IsMemonly() {
+		memprotectopts.MemProtectInit(nil)
+	}
+
+	path := strings.Trim(*flags.PathPtr, "/")
+	if !strings.HasPrefix(path, "super-secrets/") && !strings.HasPrefix(path, "values/") {
+		fmt.Println("Must specify a -path under super-secrets/ or values/")
+		return errors.New("must specify a -path under super-secrets/ or values/")
+	}
+	if (*flags.VersionPtr > 0) == (*flags.AsOfPtr != "") {
+		fmt.Println("Must specify either -version or -asOf")
+		return errors.New("must specify either -version or -asOf")
+	}
+	var asOf time.Time
+	if *flags.AsOfPtr != "" {
+		var err error
+		asOf, err = time.Parse(time.RFC3339, *flags.AsOfPtr)
+		if err != nil {
+			fmt.Println("Incorrect format for asOf: " + *flags.AsOfPtr + " - use -asOf=2026-10-01T12:00:00Z")
+			return err
+		}
+	}
+
+	// If logging production directory does not exist and is selected log to local directory
+	if _, err := os.Stat("/var/log/"); os.IsNotExist(err) && *flags.LogFilePtr == "/var/log/"+coreopts.BuildOptions.GetFolderPrefix(nil)+"rollback.log" {
+		*flags.LogFilePtr = "./" + coreopts.BuildOptions.GetFolderPrefix(nil) + "rollback.log"
+	}
+	f, err := os.OpenFile(*flags.LogFilePtr, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Println("Log init failure")
+		return err
+	}
+	logger := log.New(f, "[ROLLBACK]", log.LstdFlags)
+	driverConfig := &eUtils.DriverConfig{
+		CoreConfig: core.CoreConfig{
+			ExitOnFailure: true,
+			Log:           logger,
+		},
+		Insecure: *flags.InsecurePtr,
+	}
+
+	fmt.Printf("Connecting to vault @ %s\n", *addrPtr)
+
+	autoErr := eUtils.AutoAuth(driverConfig, secretIDPtr, appRoleIDPtr, tokenPtr, tokenNamePtr, envPtr, addrPtr, envCtxPtr, "", *flags.PingPtr)
+	if autoErr != nil {
+		fmt.Println("Missing auth components.")
+		return autoErr
+	}
+	if *flags.PingPtr {
+		return nil
+	}
+	if memonly.IsMemonly() {
+		memprotectopts.MemUnprotectAll(nil)
+		memprotectopts.MemProtect(nil, tokenPtr)
+	}
+
+	mod, err := helperkv.NewModifier(*flags.InsecurePtr, *tokenPtr, *addrPtr, *envPtr, nil, true, logger)
+	if mod != nil {
+		defer mod.Release()
+	}
+	if err != nil {
+		fmt.Println("Failure to init to vault")
+		logger.Println("Failure to init to vault")
+		return err
+	}
+	mod.Env = *envPtr
+
+	version := *flags.VersionPtr
+	if !asOf.IsZero() {
+		version, err = mod.VersionAsOf(path, asOf)
+		if err != nil {
+			fmt.Println("Failure to read versions of " + path)
+			logger.Printf("Failure to read versions of %s: %v\n", path, err)
+			return err
+		}
+		if version == 0 {
+			fmt.Printf("Nothing was at %s as of %s\n", path, *flags.AsOfPtr)
+			return fmt.Errorf("nothing was at %s as of %s", path, *flags.AsOfPtr)
+		}
+	}
+
+	// The diff is reviewed against currentVersion, so the rollback only goes ahead if path is still there.
+	current, currentVersion, err := mod.ReadDataVersion(path)
+	if err != nil {
+		fmt.Println("Failure to read " + path)
+		logger.Printf("Failure to read %s: %v\n", path, err)
+		return err
+	}
+	target, err := mod.ReadDataAtVersion(path, version)
+	if err != nil {
+		fmt.Printf("Failure to read version %d of %s\n", version, path)
+		logger.Printf("Failure to read version %d of %s: %v\n", version, path, err)
+		return err
+	}
+	if target == nil {
+		fmt.Printf("Version %d of %s does not exist or was deleted\n", version, path)
+		return fmt.Errorf("version %d of %s does not exist or was deleted", version, path)
+	}
+
+	var secrets *eUtils.DiffSecrets
+	if strings.HasPrefix(path, "super-secrets/") {
+		secrets = &eUtils.DiffSecrets{}
+		for _, data := range []map[string]interface{}{current, target} {
+			for key := range data {
+				secrets.AddKey(key)
+			}
+		}
+	}
+	keyDiffs := eUtils.DataDiff(current, target, secrets)
+	fmt.Printf("Rolling back %s to version %d:\n", path, version)
+	fmt.Println(eUtils.FormatKeyDiffs(keyDiffs))
+	if len(keyDiffs) == 0 {
+		return nil
+	}
+
+	if !*flags.YesPtr {
+		var input string
+
+		fmt.Printf("Are you sure you want to roll back %s? [y|n]: ", path)
+		_, err := fmt.Scanln(&input)
+		input = strings.ToLower(input)
+		if err != nil || (input != "y" && input != "yes") {
+			fmt.Println("Rollback cancelled.")
+			return nil
+		}
+	}
+
+	if _, err := mod.RollbackTo(path, version, currentVersion, logger); err != nil {
+		if helperkv.IsCASConflict(err) {
+			fmt.Println(path + " changed while rolling back - review the diff again.")
+		} else {
+			fmt.Println("Failure to roll back " + path)
+		}
+		logger.Printf("Failure to roll back %s: %v\n", path, err)
+		return err
+	}
+	fmt.Printf("Rolled back %s to version %d\n", path, version)
+	logger.Printf("Rolled back %s to version %d\n", path, version)
+	return nil
+}
@@ -56,8 +56,10 @@ func CommonMain(envPtr *string, addrPtr *string, envCtxPtr *string,
 	projectInfoPtr := flagset.Bool("projectInfo", false, "Lists all project info")
 	filterTemplatePtr := flagset.String("templateFilter", "", "Specifies which templates to filter")
 	templatePathsPtr := flagset.String("templatePaths", "", "Specifies which specific templates to download.")
+	eUtils.InitVaultFlags(flagset)
 
 	flagset.Parse(argLines[1:])
+	eUtils.CheckVaultFlags()
 
 	if len(*filterTemplatePtr) == 0 && !*projectInfoPtr && *templatePathsPtr == "" {
 		fmt.Printf("Must specify either -projectInfo or -templateFilter flag \n")
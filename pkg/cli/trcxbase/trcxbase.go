@@ -92,11 +92,16 @@ func CommonMain(ctx eUtils.ProcessContext,
 	}
 
 	diffPtr := flagset.Bool("diff", false, "Diff files")
+	diffFormatPtr := flagset.String("diffFormat", eUtils.DiffFormatText, "Diff output format: text or json")
+	diffAllowPtr := flagset.String("diffAllow", "", "With -diffFormat=json, fail when keys other than these differ, in the form 'key1,key2'")
 	versionPtr := flagset.Bool("versions", false, "Gets version metadata information")
 	wantCertsPtr := flagset.Bool("certs", false, "Pull certificates into directory specified by endDirPtr")
+	certReportPtr := flagset.Bool("certReport", false, "With -certs, report subject, SANs, issuer, key match, chain and expiry of each certificate instead of writing them")
+	certExpiryDaysPtr := flagset.Int("certExpiryDays", 30, "With -certReport, fail on certificates expiring within this many days")
 	filterTemplatePtr := flagset.String("templateFilter", "", "Specifies which templates to filter") // -templateFilter=config.yml
 
 	eUtils.CheckInitFlags(flagset)
+	eUtils.InitVaultFlags(flagset)
 
 	// Checks for proper flag input
 	args := argLines[1:]
@@ -109,6 +114,7 @@ func CommonMain(ctx eUtils.ProcessContext,
 	}
 
 	flagset.Parse(argLines[1:])
+	eUtils.CheckVaultFlags()
 	configCtx := &eUtils.ConfigContext{
 		ResultMap:            make(map[string]*string),
 		EnvSlice:             make([]string, 0),
@@ -117,6 +123,10 @@ func CommonMain(ctx eUtils.ProcessContext,
 		FileSysIndex:         -1,
 		ConfigWg:             sync.WaitGroup{},
 		Mutex:                &sync.Mutex{},
+		DiffFormat:           *diffFormatPtr,
+	}
+	if *diffAllowPtr != "" {
+		configCtx.DiffAllow = strings.Split(*diffAllowPtr, ",")
 	}
 
 	driverConfig := &eUtils.DriverConfig{
@@ -180,6 +190,15 @@ func CommonMain(ctx eUtils.ProcessContext,
 	} else if *readOnlyPtr && (len(*encryptedPtr) == 0 || len(*fileAddrPtr) == 0) {
 		fmt.Println("The -encrypted flag must be used with -seedPath flag if -readonly is used")
 		os.Exit(1)
+	} else if (*diffFormatPtr != eUtils.DiffFormatText && *diffFormatPtr != eUtils.DiffFormatJson) || (*diffFormatPtr == eUtils.DiffFormatJson && !*diffPtr) {
+		fmt.Println("The -diffFormat flag must be text or json and json requires the -diff flag")
+		os.Exit(1)
+	} else if *diffAllowPtr != "" && *diffFormatPtr != eUtils.DiffFormatJson {
+		fmt.Println("The -diffAllow flag must be used with -diffFormat=json")
+		os.Exit(1)
+	} else if *certReportPtr && (!*wantCertsPtr || *diffPtr || *versionPtr || *noVaultPtr) {
+		fmt.Println("The -certReport flag must be used with -certs flag and cannot be used with -diff, -versions or -novault")
+		os.Exit(1)
 	} else {
 		if len(*dynamicPathPtr) == 0 {
 			if (len(*eUtils.ServiceFilterPtr) == 0 || len(*eUtils.IndexNameFilterPtr) == 0) && len(*eUtils.IndexedPtr) != 0 {
@@ -401,6 +420,10 @@ skipDiff:
 	} else {
 		subSectionName = ""
 	}
+	var certReport *eUtils.CertReport
+	if *certReportPtr {
+		certReport = &eUtils.CertReport{ExpiryWindow: *certExpiryDaysPtr}
+	}
 	var waitg sync.WaitGroup
 	sectionKey := ""
 	var serviceFilterSlice []string
@@ -501,6 +524,7 @@ skipDiff:
 						SubPathFilter: strings.Split(pGen, ","),
 						FileFilter:    fileFilter,
 						Trcxr:         *readOnlyPtr,
+						CertReport:    certReport,
 					}
 					waitg.Add(1)
 					go func(dc *eUtils.DriverConfig) {
@@ -785,6 +809,7 @@ skipDiff:
 					ServiceFilter:   serviceFilterSlice,
 					Trcxe:           trcxeList,
 					Trcxr:           *readOnlyPtr,
+					CertReport:      certReport,
 				}
 				waitg.Add(1)
 				go func(dc *eUtils.DriverConfig) {
@@ -819,8 +844,17 @@ skipDiff:
 	}
 	waitg.Wait() //Wait for diff
 
+	if certReport != nil {
+		report := certReport.String()
+		fmt.Println(report)
+		logger.Println(report)
+	}
+
 	logger.SetPrefix("[" + coreopts.BuildOptions.GetFolderPrefix(nil) + "x]")
 	logger.Println("=============== Terminating Seed Generator ===============")
 	logger.SetPrefix("[END]")
 	logger.Println()
+	if certReport.Failed() > 0 || configCtx.DiffDisallowed > 0 {
+		os.Exit(1)
+	}
 }
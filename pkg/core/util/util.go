@@ -2,6 +2,7 @@ package util
 
 import (
 	"archive/zip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -162,7 +163,14 @@ func LoadBaseTemplate(driverConfig *eUtils.DriverConfig, templateResult *extract
 	return errSeed
 }
 
+// SeedVaultById seeds the row in tableData at indexPath, merging it into what is there so fields
+// another writer changed in the meantime aren't lost.
 func SeedVaultById(driverConfig *eUtils.DriverConfig, goMod *helperkv.Modifier, service string, address string, token string, baseTemplate *extract.TemplateResultData, tableData map[string]interface{}, indexPath string, project string) error {
+	return SeedVaultByIdWithContext(context.Background(), driverConfig, goMod, service, address, token, baseTemplate, tableData, indexPath, project)
+}
+
+// SeedVaultByIdWithContext seeds as SeedVaultById does, giving up on writes when ctx is done.
+func SeedVaultByIdWithContext(ctx context.Context, driverConfig *eUtils.DriverConfig, goMod *helperkv.Modifier, service string, address string, token string, baseTemplate *extract.TemplateResultData, tableData map[string]interface{}, indexPath string, project string) error {
 	// Copy the base template
 	templateResult := *baseTemplate
 	valueCombinedSection := map[string]map[string]map[string]string{}
@@ -249,13 +257,11 @@ func SeedVaultById(driverConfig *eUtils.DriverConfig, goMod *helperkv.Modifier,
 	if strings.Contains(indexPath, "/PublicIndex/") {
 		driverConfig.ServicesWanted = []string{""}
 		driverConfig.CoreConfig.WantCerts = false
-		il.SeedVaultFromData(driverConfig, indexPath, []byte(seedData))
-	} else {
-		driverConfig.ServicesWanted = []string{service}
-		driverConfig.CoreConfig.WantCerts = false
-		il.SeedVaultFromData(driverConfig, "Index/"+project+indexPath, []byte(seedData))
+		return il.MergeSeedVaultFromDataWithContext(ctx, driverConfig, indexPath, []byte(seedData))
 	}
-	return nil
+	driverConfig.ServicesWanted = []string{service}
+	driverConfig.CoreConfig.WantCerts = false
+	return il.MergeSeedVaultFromDataWithContext(ctx, driverConfig, "Index/"+project+indexPath, []byte(seedData))
 }
 
 func GetPluginToolConfig(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier, pluginConfig map[string]interface{}, defineService bool) (map[string]interface{}, error) {
@@ -2,6 +2,7 @@ package initlib
 
 import (
 	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
@@ -40,6 +41,14 @@ type writeCollection struct {
 
 var templateWritten map[string]bool
 
+// seedWrites - how the writes of one seed invocation are made.
+type seedWrites struct {
+	// Versions of the paths a seed file writes, read before any are written.  Used by check-and-set
+	// writes to catch paths someone else changed while seeding.
+	casVersions map[string]int
+	merge       bool // Merge into the data at each path rather than replacing it.
+}
+
 func GetTemplateParam(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier, filePath string, paramWanted string) (string, error) {
 
 	templateEncoded, err := vcutils.GetTemplate(driverConfig, mod, filePath)
@@ -53,7 +62,7 @@ func GetTemplateParam(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier,
 
 	templateStr := string(templateBytes)
 
-	t := template.New("template")
+	t := template.New("template").Funcs(vcutils.TemplateFuncMap(driverConfig, nil, false, nil))
 	t, err = t.Parse(templateStr)
 	if err != nil {
 		return "", err
@@ -454,7 +463,7 @@ func SeedVaultFromFile(driverConfig *eUtils.DriverConfig, filepath string) {
 }
 
 // seedVaultWithCertsFromEntry takes entry from writestack and if it contains a cert, writes it to vault.
-func seedVaultWithCertsFromEntry(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier, writeStack *[]writeCollection, entry *writeCollection) {
+func seedVaultWithCertsFromEntry(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier, writeStack *[]writeCollection, entry *writeCollection, writes *seedWrites) {
 	certPathData, certPathOk := entry.data["certSourcePath"]
 	if !certPathOk {
 		eUtils.LogErrorMessage(&driverConfig.CoreConfig, "Missing cert path.", false)
@@ -563,7 +572,7 @@ func seedVaultWithCertsFromEntry(driverConfig *eUtils.DriverConfig, mod *helperk
 				// insecure value entry.
 				entry.data["certData"] = certBase64
 				eUtils.LogInfo(&driverConfig.CoreConfig, "Writing certificate to vault at: "+entry.path+".")
-				mod2 := WriteData(driverConfig, entry.path, entry.data, mod)
+				mod2, _ := writeSeedData(context.Background(), driverConfig, entry.path, entry.data, mod, writes)
 				if mod != mod2 {
 					mod.Stale = true
 					mod.Release()
@@ -576,7 +585,7 @@ func seedVaultWithCertsFromEntry(driverConfig *eUtils.DriverConfig, mod *helperk
 					if strings.Contains(path, certPathSplit[len(certPathSplit)-1]) {
 						commonPath := strings.Replace(strings.TrimSuffix(path, ".mf.tmpl"), coreopts.BuildOptions.GetFolderPrefix(nil)+"_templates", "values", -1)
 						entry.data["certData"] = "data"
-						mod2 := WriteData(driverConfig, commonPath, entry.data, mod)
+						mod2, _ := writeSeedData(context.Background(), driverConfig, commonPath, entry.data, mod, writes)
 						if mod != mod2 {
 							mod.Stale = true
 							mod.Release()
@@ -598,7 +607,7 @@ func seedVaultWithCertsFromEntry(driverConfig *eUtils.DriverConfig, mod *helperk
 							if _, ok := secretEntry.data["certData"]; ok {
 								secretEntry.data["certData"] = certBase64
 								eUtils.LogInfo(&driverConfig.CoreConfig, "Writing certificate to vault at: "+secretEntry.path+".")
-								mod2 := WriteData(driverConfig, secretEntry.path, secretEntry.data, mod)
+								mod2, _ := writeSeedData(context.Background(), driverConfig, secretEntry.path, secretEntry.data, mod, writes)
 								if mod != mod2 {
 									mod.Stale = true
 									mod.Release()
@@ -606,7 +615,7 @@ func seedVaultWithCertsFromEntry(driverConfig *eUtils.DriverConfig, mod *helperk
 									mod = mod2
 								}
 
-								mod2 = WriteData(driverConfig, entry.path, entry.data, mod)
+								mod2, _ = writeSeedData(context.Background(), driverConfig, entry.path, entry.data, mod, writes)
 								if mod != mod2 {
 									mod.Stale = true
 									mod.Release()
@@ -646,6 +655,23 @@ func seedVaultWithCertsFromEntry(driverConfig *eUtils.DriverConfig, mod *helperk
 
 // SeedVaultFromData takes file bytes and seeds the vault with contained data
 func SeedVaultFromData(driverConfig *eUtils.DriverConfig, filepath string, fData []byte) error {
+	return SeedVaultFromDataWithContext(context.Background(), driverConfig, filepath, fData)
+}
+
+// SeedVaultFromDataWithContext seeds as SeedVaultFromData does, giving up on writes when ctx is done.
+func SeedVaultFromDataWithContext(ctx context.Context, driverConfig *eUtils.DriverConfig, filepath string, fData []byte) error {
+	return seedVaultFromData(ctx, driverConfig, filepath, fData, &seedWrites{})
+}
+
+// MergeSeedVaultFromDataWithContext seeds as SeedVaultFromDataWithContext does, merging the data
+// into what is at each path with check-and-set rather than replacing it.  Fields seeded replace
+// their current values.  Other fields, including ones changed by another writer in the meantime,
+// are kept.
+func MergeSeedVaultFromDataWithContext(ctx context.Context, driverConfig *eUtils.DriverConfig, filepath string, fData []byte) error {
+	return seedVaultFromData(ctx, driverConfig, filepath, fData, &seedWrites{merge: true})
+}
+
+func seedVaultFromData(ctx context.Context, driverConfig *eUtils.DriverConfig, filepath string, fData []byte, writes *seedWrites) error {
 	driverConfig.CoreConfig.Log.SetPrefix("[SEED]")
 	driverConfig.CoreConfig.Log.Println("=========New File==========")
 	var verificationData map[interface{}]interface{} // Create a reference for verification. Can't run until other secrets written
@@ -756,6 +782,17 @@ func SeedVaultFromData(driverConfig *eUtils.DriverConfig, filepath string, fData
 	} else {
 		driverConfig.CoreConfig.Log.Println("Seeding configuration data for the following templates:" + filepath)
 	}
+	if driverConfig.CheckAndSet {
+		writes.casVersions = make(map[string]int)
+		for _, entry := range writeStack {
+			_, version, err := mod.ReadDataVersionWithContext(ctx, entry.path)
+			if err != nil {
+				return eUtils.LogErrorAndSafeExit(&driverConfig.CoreConfig, err, 1)
+			}
+			writes.casVersions[entry.path] = version
+		}
+	}
+
 	// Write values to vault
 	driverConfig.CoreConfig.Log.Println("Please verify that these templates exist in each service")
 
@@ -779,20 +816,23 @@ func SeedVaultFromData(driverConfig *eUtils.DriverConfig, filepath string, fData
 		if seedCert {
 			sectionPathTemp := mod.SectionPath
 			mod.SectionPath = ""
-			seedVaultWithCertsFromEntry(driverConfig, mod, &writeStack, &entry)
+			seedVaultWithCertsFromEntry(driverConfig, mod, &writeStack, &entry, writes)
 			mod.SectionPath = sectionPathTemp
 		} else if seedData {
 			// TODO: Support all services, so range over ServicesWanted....
 			// Populate as a slice...
 			if driverConfig.ServicesWanted[0] != "" {
 				if strings.HasSuffix(entry.path, driverConfig.ServicesWanted[0]) || strings.Contains(entry.path, "Common") {
-					mod2 := WriteData(driverConfig, entry.path, entry.data, mod)
+					mod2, err := WriteDataWithContext(ctx, driverConfig, entry.path, entry.data, mod)
 					if mod != mod2 {
 						mod.Stale = true
 						mod.Release()
 						defer mod2.Release()
 						mod = mod2
 					}
+					if err != nil {
+						return err
+					}
 				}
 			} else if strings.Contains(filepath, "/PublicIndex/") {
 				if !strings.Contains(entry.path, "templates") {
@@ -803,22 +843,28 @@ func SeedVaultFromData(driverConfig *eUtils.DriverConfig, filepath string, fData
 						filepath = "super-secrets" + filepath
 					}
 
-					mod2 := WriteData(driverConfig, filepath, entry.data, mod)
+					mod2, err := WriteDataWithContext(ctx, driverConfig, filepath, entry.data, mod)
 					if mod != mod2 {
 						mod.Stale = true
 						mod.Release()
 						defer mod2.Release()
 						mod = mod2
 					}
+					if err != nil {
+						return err
+					}
 				}
 			} else {
-				mod2 := WriteData(driverConfig, entry.path, entry.data, mod)
+				mod2, err := WriteDataWithContext(ctx, driverConfig, entry.path, entry.data, mod)
 				if mod != mod2 {
 					mod.Stale = true
 					mod.Release()
 					defer mod2.Release()
 					mod = mod2
 				}
+				if err != nil {
+					return err
+				}
 			}
 		} else {
 			driverConfig.CoreConfig.Log.Printf("\nSkipping non-matching seed data: " + entry.path)
@@ -835,6 +881,18 @@ func SeedVaultFromData(driverConfig *eUtils.DriverConfig, filepath string, fData
 
 // WriteData takes entry path and date from each iteration of writeStack in SeedVaultFromData and writes to vault
 func WriteData(driverConfig *eUtils.DriverConfig, path string, data map[string]interface{}, mod *helperkv.Modifier) *helperkv.Modifier {
+	mod, _ = WriteDataWithContext(context.Background(), driverConfig, path, data, mod)
+	return mod
+}
+
+// WriteDataWithContext writes as WriteData does, giving up when ctx is done.  Returns the error
+// when the write fails, a *helperkv.CASConflictError when path changed after seeding began.
+func WriteDataWithContext(ctx context.Context, driverConfig *eUtils.DriverConfig, path string, data map[string]interface{}, mod *helperkv.Modifier) (*helperkv.Modifier, error) {
+	return writeSeedData(ctx, driverConfig, path, data, mod, nil)
+}
+
+// writeSeedData writes as WriteDataWithContext does, the way writes asks.
+func writeSeedData(ctx context.Context, driverConfig *eUtils.DriverConfig, path string, data map[string]interface{}, mod *helperkv.Modifier, writes *seedWrites) (*helperkv.Modifier, error) {
 	root := strings.Split(path, "/")[0]
 	if templateWritten == nil {
 		templateWritten = make(map[string]bool)
@@ -844,23 +902,37 @@ func WriteData(driverConfig *eUtils.DriverConfig, path string, data map[string]i
 		if !ok {
 			templateWritten[path] = true
 		} else {
-			return mod
+			return mod, nil
 		}
 	}
-	warn, err := mod.Write(path, data, driverConfig.CoreConfig.Log)
-	if err != nil {
-		mod, err = helperkv.NewModifier(driverConfig.Insecure, driverConfig.Token, driverConfig.VaultAddress, driverConfig.Env, nil, true, driverConfig.CoreConfig.Log) // Connect to vault
-		if err != nil {
-			mod, err = helperkv.NewModifier(driverConfig.Insecure, driverConfig.Token, driverConfig.VaultAddress, driverConfig.Env, nil, false, driverConfig.CoreConfig.Log) // Connect to vault
-			if err != nil {
+	warn, err := writeData(ctx, driverConfig, path, data, mod, writes)
+	if helperkv.IsCASConflict(err) {
+		// Someone else changed path while seeding.  Stop rather than overwrite their changes.
+		return mod, eUtils.LogErrorAndSafeExit(&driverConfig.CoreConfig, err, 1)
+	} else if err != nil {
+		if ctx.Err() != nil {
+			// Given up on rather than failed.  The caller puts the change back.
+			return mod, err
+		}
+		// Reconnect and try once more.
+		retryMod, retryErr := helperkv.NewModifier(driverConfig.Insecure, driverConfig.Token, driverConfig.VaultAddress, driverConfig.Env, nil, true, driverConfig.CoreConfig.Log) // Connect to vault
+		if retryErr != nil {
+			retryMod, retryErr = helperkv.NewModifier(driverConfig.Insecure, driverConfig.Token, driverConfig.VaultAddress, driverConfig.Env, nil, false, driverConfig.CoreConfig.Log) // Connect to vault
+			if retryErr != nil {
 				// Panic scenario...  Can't reach secrets engine
-				eUtils.LogErrorAndSafeExit(&driverConfig.CoreConfig, err, 1)
+				return mod, eUtils.LogErrorAndSafeExit(&driverConfig.CoreConfig, retryErr, 1)
 			}
-			warn, err = mod.Write(path, data, driverConfig.CoreConfig.Log)
-			if err != nil {
-				// Panic scenario...  Can't reach secrets engine
-				eUtils.LogErrorAndSafeExit(&driverConfig.CoreConfig, err, 1)
+		}
+		retryMod.Env = mod.Env
+		retryMod.SectionPath = mod.SectionPath
+		mod = retryMod
+		warn, err = writeData(ctx, driverConfig, path, data, mod, writes)
+		if err != nil {
+			if ctx.Err() != nil {
+				return mod, err
 			}
+			// Panic scenario...  Can't reach secrets engine
+			return mod, eUtils.LogErrorAndSafeExit(&driverConfig.CoreConfig, err, 1)
 		}
 	}
 
@@ -872,5 +944,36 @@ func WriteData(driverConfig *eUtils.DriverConfig, path string, data map[string]i
 		driverConfig.CoreConfig.Log.Println(coreopts.BuildOptions.GetFolderPrefix(nil) + "_" + path + ".*.tmpl")
 		mod.AdjustValue("value-metrics/credentials", data, 1, driverConfig.CoreConfig.Log)
 	}
-	return mod
+	return mod, nil
+}
+
+// writeData writes data to path, with check-and-set against the version path had when seeding
+// began if requested, or merged into what is there if writes asks.
+func writeData(ctx context.Context, driverConfig *eUtils.DriverConfig, path string, data map[string]interface{}, mod *helperkv.Modifier, writes *seedWrites) ([]string, error) {
+	if driverConfig.CheckAndSet {
+		version, ok := 0, false
+		if writes != nil {
+			version, ok = writes.casVersions[path]
+		}
+		if !ok {
+			var err error
+			if _, version, err = mod.ReadDataVersionWithContext(ctx, path); err != nil {
+				return nil, err
+			}
+		}
+		warn, err := mod.WriteCASWithContext(ctx, path, data, version, driverConfig.CoreConfig.Log)
+		if err == nil && writes != nil && writes.casVersions != nil {
+			writes.casVersions[path] = version + 1
+		}
+		return warn, err
+	}
+	if writes != nil && writes.merge {
+		return mod.UpdateWithContext(ctx, path, func(current map[string]interface{}) (map[string]interface{}, error) {
+			for key, value := range data {
+				current[key] = value
+			}
+			return current, nil
+		}, driverConfig.CoreConfig.Log)
+	}
+	return mod.WriteWithContext(ctx, path, data, driverConfig.CoreConfig.Log)
 }
@@ -0,0 +1,41 @@
+package initlib
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/trimble-oss/tierceron/pkg/core"
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+)
+
+func TestWriteDataSeedWrites(t *testing.T) {
+	driverConfig := &eUtils.DriverConfig{CoreConfig: core.CoreConfig{Log: log.New(io.Discard, "", 0)}}
+	mod := helperkv.NewModifierWithStorage(helperkv.NewMemoryStorage(), "dev", nil)
+	mod.Env = "dev"
+	path := "super-secrets/Index/Project/Service"
+	ctx := context.Background()
+	if _, err := writeData(ctx, driverConfig, path, map[string]interface{}{"a": "1", "other": "1"}, mod, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Merging keeps fields the seed doesn't have.
+	if _, err := writeData(ctx, driverConfig, path, map[string]interface{}{"a": "2"}, mod, &seedWrites{merge: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := mod.ReadData(path); data["a"] != "2" || data["other"] != "1" {
+		t.Fatalf("Expected a merged with other, got %v", data)
+	}
+
+	// Each seed checks against the versions it read.
+	driverConfig.CheckAndSet = true
+	first, second := &seedWrites{casVersions: map[string]int{path: 2}}, &seedWrites{casVersions: map[string]int{path: 2}}
+	if _, err := writeData(ctx, driverConfig, path, map[string]interface{}{"a": "3"}, mod, first); err != nil || first.casVersions[path] != 3 {
+		t.Fatalf("Expected version 3, got %v %v", first.casVersions, err)
+	}
+	if _, err := writeData(ctx, driverConfig, path, map[string]interface{}{"a": "4"}, mod, second); !helperkv.IsCASConflict(err) {
+		t.Fatalf("Expected conflict, got %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+// Package trcsdk loads configuration from vault in process: rendered templates and typed values
+// for a project's service, cached until their version changes.
+package trcsdk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trimble-oss/tierceron/buildopts/coreopts"
+	"github.com/trimble-oss/tierceron/buildopts/memprotectopts"
+	vcutils "github.com/trimble-oss/tierceron/pkg/cli/trcconfigbase/utils"
+	"github.com/trimble-oss/tierceron/pkg/core"
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+	"github.com/trimble-oss/tierceron/pkg/vaulthelper/system"
+)
+
+// Config describes how a Client authenticates and how often it checks for new versions.
+type Config struct {
+	Address       string
+	Env           string // dev, QA, staging, ... optionally pinned to a version: dev_3
+	Token         string // Optional if AppRoleID and SecretID provided.
+	AppRoleID     string // Optional if Token provided.
+	SecretID      string // Optional if Token provided.
+	AppRoleConfig string // Approle config under ~/.tierceron, config.yml by default.
+	Insecure      bool
+
+	// VaultNamespace is the Vault Enterprise namespace to work in.  Sets the namespace of every vault
+	// client in the process, as -vaultNamespace does.
+	VaultNamespace string
+
+	// MemProtect locks the process's memory and each loaded secret, as memonly builds do.
+	MemProtect bool
+
+	// VersionCheckInterval is how long cached results are used before versions are checked again.
+	// Default 1 minute.
+	VersionCheckInterval time.Duration
+
+	// PollInterval is how often subscriptions check for new versions.  Default 1 minute.
+	PollInterval time.Duration
+
+	// RenewFraction is the fraction of the token's ttl that passes before it is renewed.  Default 2/3.
+	// Clients authenticated with an approle obtain a new token once renewal is no longer possible.
+	RenewFraction float64
+	// OnTokenState is called as the token is renewed or replaced.  Optional.
+	OnTokenState func(system.TokenEvent)
+
+	Log *log.Logger // Discarded when nil.
+}
+
+// Client loads configuration for an environment.  Safe for concurrent use.
+type Client struct {
+	config       Config
+	driverConfig *eUtils.DriverConfig
+
+	mutex  sync.Mutex
+	mod    *helperkv.Modifier
+	tokens *system.TokenManager
+	cache  map[string]*serviceCache // project/service -> cached results
+
+	subscriptions subscriptions
+}
+
+// serviceCache holds a service's results as of the versions they were loaded at.
+type serviceCache struct {
+	versions  map[string]int
+	checked   time.Time
+	values    Values
+	templates map[string]string // template -> rendered template
+}
+
+// NewClient authenticates with config and connects to vault.  Tokens are obtained from the
+// approle as trcconfig does when config.Token isn't provided.
+func NewClient(config Config) (*Client, error) {
+	if config.Address == "" || config.Env == "" {
+		return nil, errors.New("address and env are required")
+	}
+	if config.Log == nil {
+		config.Log = log.New(io.Discard, "", 0)
+	}
+	if config.VersionCheckInterval <= 0 {
+		config.VersionCheckInterval = time.Minute
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Minute
+	}
+	if coreopts.BuildOptions == nil {
+		coreopts.NewOptionsBuilder(coreopts.LoadOptions())
+	}
+	if config.MemProtect {
+		memprotectopts.MemProtectInit(config.Log)
+	}
+	if config.VaultNamespace != "" {
+		helperkv.SetVaultNamespace(config.VaultNamespace)
+	}
+
+	envVersion := eUtils.SplitEnv(config.Env)
+	driverConfig := &eUtils.DriverConfig{
+		CoreConfig: core.CoreConfig{
+			ExitOnFailure: false,
+			Log:           config.Log,
+		},
+		Insecure:   config.Insecure,
+		Env:        config.Env,
+		EnvRaw:     envVersion[0],
+		ZeroConfig: true,
+		StartDir:   []string{"trc_templates"},
+	}
+
+	env := envVersion[0]
+	token := config.Token
+	address := config.Address
+	tokenName := ""
+	if token == "" && (config.AppRoleID == "" || config.SecretID == "") {
+		return nil, errors.New("token or approle and secret are required")
+	}
+	if err := eUtils.AutoAuth(driverConfig, &config.SecretID, &config.AppRoleID, &token, &tokenName, &env, &address, nil, config.AppRoleConfig, false); err != nil {
+		return nil, fmt.Errorf("unable to authenticate: %v", err)
+	}
+	if token == "" || address == "" {
+		return nil, errors.New("unable to authenticate: no token obtained")
+	}
+	if config.MemProtect {
+		memprotectopts.MemProtect(config.Log, &token)
+	}
+	driverConfig.Token = token
+	driverConfig.VaultAddress = address
+
+	mod, err := helperkv.NewModifier(config.Insecure, token, address, envVersion[0], nil, true, config.Log)
+	if err != nil {
+		return nil, err
+	}
+	mod.Env = envVersion[0]
+	if len(envVersion) > 1 {
+		mod.Version = envVersion[1]
+	}
+
+	tokenConfig := system.TokenManagerConfig{
+		Insecure:      config.Insecure,
+		Address:       address,
+		Env:           envVersion[0],
+		Token:         token,
+		RenewFraction: config.RenewFraction,
+		OnState:       config.OnTokenState,
+		Log:           config.Log,
+	}
+	if config.AppRoleID != "" && config.SecretID != "" {
+		tokenConfig.Reauth = func() (string, error) {
+			secretID, appRoleID := config.SecretID, config.AppRoleID
+			token, tokenName, env, address := "", "", envVersion[0], address
+			if err := eUtils.AutoAuth(driverConfig, &secretID, &appRoleID, &token, &tokenName, &env, &address, nil, config.AppRoleConfig, false); err != nil {
+				return "", err
+			}
+			if config.MemProtect {
+				memprotectopts.MemProtect(config.Log, &token)
+			}
+			return token, nil
+		}
+	}
+	tokens, err := system.NewTokenManager(tokenConfig)
+	if err != nil {
+		mod.Close()
+		return nil, err
+	}
+	tokens.AddModifier(mod)
+	tokens.Start()
+
+	return &Client{
+		config:       config,
+		driverConfig: driverConfig,
+		mod:          mod,
+		tokens:       tokens,
+		cache:        map[string]*serviceCache{},
+	}, nil
+}
+
+// Close stops any subscriptions and releases the client's connection to vault.
+func (c *Client) Close() {
+	c.subscriptions.stop()
+	c.tokens.Stop()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.mod != nil {
+		c.mod.Close()
+		c.mod = nil
+	}
+	c.cache = map[string]*serviceCache{}
+}
+
+// Template returns template rendered for project/service: Template("Project", "Service", "config.yml")
+func (c *Client) Template(project string, service string, template string) (string, error) {
+	if project == "" || service == "" || template == "" {
+		return "", errors.New("project, service and template are required")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cache, err := c.serviceCache(project, service)
+	if err != nil {
+		return "", err
+	}
+	if configured, ok := cache.templates[template]; ok {
+		return configured, nil
+	}
+
+	c.mod.TemplatePath = ""
+	c.driverConfig.CoreConfig.WantCerts = false
+	templatePath := "trc_templates/" + project + "/" + service + "/" + strings.TrimSuffix(template, ".tmpl") + ".tmpl"
+	configured, _, _, err := vcutils.ConfigTemplate(c.driverConfig, c.mod, templatePath, true, project, service, false, true)
+	if err != nil {
+		return "", fmt.Errorf("unable to render %s: %v", templatePath, err)
+	}
+	c.protect(&configured)
+	cache.templates[template] = configured
+	return configured, nil
+}
+
+// Values returns every value, secrets included, resolved for project/service.  The values
+// are the caller's own: changing them leaves the cached values as they are.
+func (c *Client) Values(project string, service string) (Values, error) {
+	if project == "" || service == "" {
+		return nil, errors.New("project and service are required")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cache, err := c.serviceCache(project, service)
+	if err != nil {
+		return nil, err
+	}
+	if cache.values != nil {
+		return cache.values.copy(), nil
+	}
+
+	c.driverConfig.CoreConfig.WantCerts = false
+	values, err := vcutils.ExportValues(c.driverConfig, c.mod, project, service, "")
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range values {
+		c.protect(&value)
+		values[key] = value
+	}
+	cache.values = values
+	return cache.values.copy(), nil
+}
+
+// serviceCache returns the service's cache, emptied if its versions have changed since loaded.
+// Called with the client locked.
+func (c *Client) serviceCache(project string, service string) (*serviceCache, error) {
+	if c.mod == nil {
+		return nil, errors.New("client is closed")
+	}
+	c.driverConfig.Token = c.tokens.Token()
+	key := project + "/" + service
+	cache, ok := c.cache[key]
+	if ok && time.Since(cache.checked) < c.config.VersionCheckInterval {
+		return cache, nil
+	}
+	versions, err := c.serviceVersions(project, service)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || !sameVersions(cache.versions, versions) {
+		cache = &serviceCache{versions: versions, templates: map[string]string{}}
+		c.cache[key] = cache
+	}
+	cache.checked = time.Now()
+	return cache, nil
+}
+
+// serviceVersions reads the latest version of every template, value and secret path the service's
+// templates depend on.  Called with the client locked.
+func (c *Client) serviceVersions(project string, service string) (map[string]int, error) {
+	templatePaths, err := c.mod.GetTemplateFilePaths("templates/"+project+"/"+service+"/", c.config.Log)
+	if err != nil {
+		return nil, err
+	}
+	versions := map[string]int{}
+	for _, templatePath := range templatePaths {
+		templatePath = strings.TrimSuffix(templatePath, "/")
+		versions[templatePath] = latestVersion(c.mod, templatePath+"/template-file", c.config.Log)
+		links, err := c.mod.ReadData(templatePath)
+		if err != nil {
+			continue
+		}
+		for _, link := range links {
+			if valueLink, ok := link.([]interface{}); ok && len(valueLink) > 0 {
+				if bucket, ok := valueLink[0].(string); ok {
+					if _, read := versions[bucket]; !read {
+						versions[bucket] = latestVersion(c.mod, bucket, c.config.Log)
+					}
+				}
+			}
+		}
+	}
+	return versions, nil
+}
+
+// latestVersion - the latest version of path, or 0 if it has none.
+func latestVersion(mod *helperkv.Modifier, path string, logger *log.Logger) int {
+	versionMetadata, err := mod.ReadVersionMetadata(path, logger)
+	if err != nil {
+		return 0
+	}
+	latest := 0
+	for versionKey := range versionMetadata {
+		if version, convErr := strconv.Atoi(versionKey); convErr == nil && version > latest {
+			latest = version
+		}
+	}
+	return latest
+}
+
+func sameVersions(versions map[string]int, currentVersions map[string]int) bool {
+	if len(versions) != len(currentVersions) {
+		return false
+	}
+	for path, version := range versions {
+		if currentVersion, ok := currentVersions[path]; !ok || currentVersion != version {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Client) protect(sensitive *string) {
+	if c.config.MemProtect {
+		memprotectopts.MemProtect(c.config.Log, sensitive)
+	}
+}
@@ -0,0 +1,112 @@
+package trcsdk
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/trimble-oss/tierceron/buildopts/coreopts"
+	"github.com/trimble-oss/tierceron/pkg/core"
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+	"github.com/trimble-oss/tierceron/pkg/vaulthelper/system"
+)
+
+// newTestClient - a client over memory storage holding Project/Service with a value and a secret.
+func newTestClient(t *testing.T, config Config) *Client {
+	if coreopts.BuildOptions == nil {
+		coreopts.NewOptionsBuilder(coreopts.LoadOptions())
+	}
+	config.Log = log.New(io.Discard, "", 0)
+	mod := helperkv.NewModifierWithStorage(helperkv.NewMemoryStorage(), "dev", nil)
+	mod.Env = "dev"
+	writeTestData(t, mod, "templates/Project/Service/config", map[string]interface{}{
+		"host":     []interface{}{"values/Project/Service/config", "host"},
+		"password": []interface{}{"super-secrets/Project/Service/config", "password"},
+	})
+	writeTestData(t, mod, "values/Project/Service/config", map[string]interface{}{"host": "localhost"})
+	writeTestData(t, mod, "super-secrets/Project/Service/config", map[string]interface{}{"password": "hunter2"})
+
+	client := &Client{
+		config: config,
+		driverConfig: &eUtils.DriverConfig{
+			CoreConfig: core.CoreConfig{Log: config.Log},
+			Env:        "dev",
+			ZeroConfig: true,
+		},
+		mod:    mod,
+		tokens: &system.TokenManager{},
+		cache:  map[string]*serviceCache{},
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func writeTestData(t *testing.T, mod *helperkv.Modifier, path string, data map[string]interface{}) {
+	if _, err := mod.Write(path, data, nil); err != nil {
+		t.Fatalf("Write %s failed: %v", path, err)
+	}
+}
+
+func TestValuesCache(t *testing.T) {
+	client := newTestClient(t, Config{VersionCheckInterval: time.Hour})
+	values, err := client.Values("Project", "Service")
+	if err != nil || values["host"] != "localhost" || values["password"] != "hunter2" {
+		t.Fatalf("Expected host and password, got %v %v", values, err)
+	}
+
+	// Changing the returned values leaves the cache as is.
+	values["host"] = "changed"
+	if values, _ := client.Values("Project", "Service"); values["host"] != "localhost" {
+		t.Fatalf("Expected cached localhost, got %s", values["host"])
+	}
+
+	// New versions aren't checked for until VersionCheckInterval passes.
+	writeTestData(t, client.mod, "values/Project/Service/config", map[string]interface{}{"host": "remote"})
+	if values, _ := client.Values("Project", "Service"); values["host"] != "localhost" {
+		t.Fatalf("Expected cached localhost, got %s", values["host"])
+	}
+	client.config.VersionCheckInterval = 0
+	if values, _ := client.Values("Project", "Service"); values["host"] != "remote" {
+		t.Fatalf("Expected remote after the version changed, got %s", values["host"])
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	client := newTestClient(t, Config{VersionCheckInterval: time.Hour, PollInterval: 10 * time.Millisecond})
+	if _, err := client.Values("Project", "Service"); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan Values, 1)
+	cancel, err := client.Subscribe("Project", "Service", func(change Change) {
+		values, _ := client.Values(change.Project, change.Service)
+		select {
+		case changed <- values:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestData(t, client.mod, "super-secrets/Project/Service/config", map[string]interface{}{"password": "hunter22"})
+	select {
+	case values := <-changed:
+		if values["password"] != "hunter22" {
+			t.Fatalf("Expected hunter22 from onChange, got %v", values)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected onChange after the secret changed")
+	}
+
+	// No calls once cancelled.
+	cancel()
+	writeTestData(t, client.mod, "super-secrets/Project/Service/config", map[string]interface{}{"password": "hunter222"})
+	select {
+	case values := <-changed:
+		t.Fatalf("Expected no onChange after cancel, got %v", values)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
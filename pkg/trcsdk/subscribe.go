@@ -0,0 +1,138 @@
+package trcsdk
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Change is passed to subscribers when any path a service's templates depend on has a new version.
+type Change struct {
+	Project  string
+	Service  string
+	Versions map[string]int // path -> latest version
+}
+
+type subscriber struct {
+	id       int
+	project  string
+	service  string
+	onChange func(Change)
+}
+
+// subscriptions polls for new versions of the services subscribed to.
+type subscriptions struct {
+	mutex       sync.Mutex
+	subscribers []*subscriber
+	nextID      int
+	done        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// Subscribe calls onChange whenever the versions project/service's templates depend on change.
+// Versions are polled every PollInterval.  onChange is called from the polling goroutine with
+// cached results already discarded, so calls to Template and Values from it load the new versions.
+// The returned function cancels the subscription.  onChange must not Close the client.
+func (c *Client) Subscribe(project string, service string, onChange func(Change)) (func(), error) {
+	if project == "" || service == "" || onChange == nil {
+		return nil, errors.New("project, service and onChange are required")
+	}
+	// Capture the versions to compare against.
+	c.mutex.Lock()
+	_, err := c.serviceCache(project, service)
+	c.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	c.subscriptions.mutex.Lock()
+	defer c.subscriptions.mutex.Unlock()
+	c.subscriptions.nextID++
+	id := c.subscriptions.nextID
+	c.subscriptions.subscribers = append(c.subscriptions.subscribers, &subscriber{id: id, project: project, service: service, onChange: onChange})
+	if c.subscriptions.done == nil {
+		c.subscriptions.done = make(chan struct{})
+		c.subscriptions.wg.Add(1)
+		go c.poll(c.subscriptions.done)
+	}
+	return func() { c.subscriptions.remove(id) }, nil
+}
+
+func (s *subscriptions) remove(id int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, sub := range s.subscribers {
+		if sub.id == id {
+			s.subscribers = append(s.subscribers[:i], s.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// stop ends polling and waits for any onChange in progress.
+func (s *subscriptions) stop() {
+	s.mutex.Lock()
+	done := s.done
+	s.done = nil
+	s.subscribers = nil
+	s.mutex.Unlock()
+	if done != nil {
+		close(done)
+		s.wg.Wait()
+	}
+}
+
+func (s *subscriptions) list() []*subscriber {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*subscriber{}, s.subscribers...)
+}
+
+func (c *Client) poll(done chan struct{}) {
+	defer c.subscriptions.wg.Done()
+	ticker := time.NewTicker(c.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		changes := c.changedServices(c.subscriptions.list())
+		for _, sub := range c.subscriptions.list() {
+			if change, ok := changes[sub.project+"/"+sub.service]; ok {
+				sub.onChange(change)
+			}
+		}
+	}
+}
+
+// changedServices checks each subscribed service's versions, discarding cached results for any that changed.
+func (c *Client) changedServices(subscribers []*subscriber) map[string]Change {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	changes := map[string]Change{}
+	checked := map[string]bool{}
+	for _, sub := range subscribers {
+		key := sub.project + "/" + sub.service
+		if checked[key] || c.mod == nil {
+			continue
+		}
+		checked[key] = true
+		versions, err := c.serviceVersions(sub.project, sub.service)
+		if err != nil {
+			c.config.Log.Printf("Unable to check versions of %s: %v\n", key, err)
+			continue
+		}
+		cache, ok := c.cache[key]
+		if ok && sameVersions(cache.versions, versions) {
+			cache.checked = time.Now()
+			continue
+		}
+		c.cache[key] = &serviceCache{versions: versions, checked: time.Now(), templates: map[string]string{}}
+		if ok {
+			changes[key] = Change{Project: sub.project, Service: sub.service, Versions: versions}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,87 @@
+package trcsdk
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Values are a service's resolved values by key.
+type Values map[string]string
+
+// String returns the value for key.
+func (values Values) String(key string) (string, error) {
+	value, ok := values[key]
+	if !ok {
+		return "", fmt.Errorf("key not found: %s", key)
+	}
+	return value, nil
+}
+
+// StringOr returns the value for key, or fallback if there isn't one.
+func (values Values) StringOr(key string, fallback string) string {
+	if value, ok := values[key]; ok {
+		return value
+	}
+	return fallback
+}
+
+// Int returns the value for key as an int.
+func (values Values) Int(key string) (int, error) {
+	value, err := values.String(key)
+	if err != nil {
+		return 0, err
+	}
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not an int: %v", key, err)
+	}
+	return intValue, nil
+}
+
+// Float returns the value for key as a float64.
+func (values Values) Float(key string) (float64, error) {
+	value, err := values.String(key)
+	if err != nil {
+		return 0, err
+	}
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a number: %v", key, err)
+	}
+	return floatValue, nil
+}
+
+// Bool returns the value for key as a bool: true, false, 1, 0, ...
+func (values Values) Bool(key string) (bool, error) {
+	value, err := values.String(key)
+	if err != nil {
+		return false, err
+	}
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s is not a bool: %v", key, err)
+	}
+	return boolValue, nil
+}
+
+// Duration returns the value for key as a time.Duration: 30s, 5m, ...
+func (values Values) Duration(key string) (time.Duration, error) {
+	value, err := values.String(key)
+	if err != nil {
+		return 0, err
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a duration: %v", key, err)
+	}
+	return duration, nil
+}
+
+func (values Values) copy() Values {
+	copied := make(Values, len(values))
+	for key, value := range values {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,54 @@
+package trcsdk
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValues(t *testing.T) {
+	values := Values{"port": "8080", "ratio": "0.5", "enabled": "true", "timeout": "30s", "host": "localhost"}
+
+	if port, err := values.Int("port"); err != nil || port != 8080 {
+		t.Fatalf("Expected 8080, got %d %v", port, err)
+	}
+	if ratio, err := values.Float("ratio"); err != nil || ratio != 0.5 {
+		t.Fatalf("Expected 0.5, got %f %v", ratio, err)
+	}
+	if enabled, err := values.Bool("enabled"); err != nil || !enabled {
+		t.Fatalf("Expected true, got %t %v", enabled, err)
+	}
+	if timeout, err := values.Duration("timeout"); err != nil || timeout != 30*time.Second {
+		t.Fatalf("Expected 30s, got %s %v", timeout, err)
+	}
+	if _, err := values.Int("host"); err == nil {
+		t.Fatal("Expected error converting host to int")
+	}
+	if _, err := values.String("missing"); err == nil {
+		t.Fatal("Expected error for missing key")
+	}
+	if host := values.StringOr("missing", "fallback"); host != "fallback" {
+		t.Fatalf("Expected fallback, got %s", host)
+	}
+}
+
+func TestSameVersions(t *testing.T) {
+	versions := map[string]int{"values/Project/Service/config": 3, "super-secrets/Project/Service/config": 1}
+	if !sameVersions(versions, map[string]int{"values/Project/Service/config": 3, "super-secrets/Project/Service/config": 1}) {
+		t.Fatal("Expected same versions")
+	}
+	if sameVersions(versions, map[string]int{"values/Project/Service/config": 4, "super-secrets/Project/Service/config": 1}) {
+		t.Fatal("Expected changed version")
+	}
+	if sameVersions(versions, map[string]int{"values/Project/Service/config": 3}) {
+		t.Fatal("Expected changed paths")
+	}
+}
+
+func TestNewClientRequiresAuth(t *testing.T) {
+	if _, err := NewClient(Config{Address: "https://127.0.0.1:8200"}); err == nil {
+		t.Fatal("Expected error without env")
+	}
+	if _, err := NewClient(Config{Address: "https://127.0.0.1:8200", Env: "dev"}); err == nil {
+		t.Fatal("Expected error without token or approle")
+	}
+}
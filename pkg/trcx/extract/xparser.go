@@ -78,7 +78,7 @@ func ToSeed(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier,
 	}
 
 	// Parse template
-	t := template.New("template")
+	t := template.New("template").Funcs(vcutils.TemplateFuncMap(driverConfig, nil, false, nil))
 	theTemplate, err := t.Parse(newTemplate)
 	if err != nil {
 		return nil, nil, nil, 0, eUtils.LogAndSafeExit(&driverConfig.CoreConfig, err.Error(), -1)
@@ -89,6 +89,11 @@ func ToSeed(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier,
 		if node.Type() == parse.NodeAction {
 			var args []string
 			fields := node.(*parse.ActionNode).Pipe
+			libArgs, isLibrary := libraryArgs(fields)
+			if isLibrary && len(libArgs) == 0 {
+				// Nothing this template owns, e.g. a secret from another service.
+				continue
+			}
 			for _, arg := range fields.Cmds[0].Args {
 				templateParameter := strings.ReplaceAll(arg.String(), "\\\"", "\"")
 				if strings.Contains(templateParameter, "~") {
@@ -97,21 +102,27 @@ func ToSeed(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier,
 				}
 				args = append(args, templateParameter)
 			}
+			argsList := [][]string{args}
+			if isLibrary {
+				argsList = libArgs
+			}
 
-			// Gets the parsed file line
-			errParse := Parse(&driverConfig.CoreConfig, cds,
-				args,
-				pathSlice[len(pathSlice)-2],
-				templatePathSlice,
-				templateDir,
-				templateDepth,
-				service,
-				interfaceTemplateSection,
-				valueSection,
-				secretSection,
-			)
-			if errParse != nil {
-				return nil, nil, nil, 0, errParse
+			for _, args := range argsList {
+				// Gets the parsed file line
+				errParse := Parse(&driverConfig.CoreConfig, cds,
+					args,
+					pathSlice[len(pathSlice)-2],
+					templatePathSlice,
+					templateDir,
+					templateDepth,
+					service,
+					interfaceTemplateSection,
+					valueSection,
+					secretSection,
+				)
+				if errParse != nil {
+					return nil, nil, nil, 0, errParse
+				}
 			}
 		}
 	}
@@ -119,6 +130,91 @@ func ToSeed(driverConfig *eUtils.DriverConfig, mod *helperkv.Modifier,
 	return interfaceTemplateSection, valueSection, secretSection, templateDepth, nil
 }
 
+// libraryArgs reduces an action using the template function library to the
+// {{.key}} or {{or .key "default"}} forms understood by Parse, one for each key the action
+// uses, including keys in nested pipelines.
+// Returns false if the action does not use the function library.
+func libraryArgs(pipe *parse.PipeNode) ([][]string, bool) {
+	if pipe == nil || len(pipe.Cmds) == 0 || len(pipe.Cmds[0].Args) == 0 {
+		return nil, false
+	}
+	libraryFuncs := vcutils.TemplateFuncMap(nil, nil, false, nil)
+
+	if ident, isIdent := pipe.Cmds[0].Args[0].(*parse.IdentifierNode); isIdent {
+		if _, ok := libraryFuncs[ident.Ident]; !ok {
+			return nil, false
+		}
+	} else if _, isDefault := pipeDefault(pipe); !isDefault {
+		return nil, false
+	}
+
+	fieldArgs := [][]string{}
+	collectFieldArgs(pipe, map[string]bool{}, &fieldArgs)
+	return fieldArgs, true
+}
+
+// pipeDefault - the or form of {{.key | default "value"}}.
+func pipeDefault(pipe *parse.PipeNode) ([]string, bool) {
+	first := pipe.Cmds[0].Args
+	if field, isField := first[0].(*parse.FieldNode); isField && len(first) == 1 {
+		for _, cmd := range pipe.Cmds[1:] {
+			if pipeIdent, ok := cmd.Args[0].(*parse.IdentifierNode); ok && pipeIdent.Ident == "default" && len(cmd.Args) == 2 {
+				return []string{"or", field.String(), cmd.Args[1].String()}, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// collectFieldArgs adds the Parse form of each key used under node not already seen.
+func collectFieldArgs(node parse.Node, seen map[string]bool, fieldArgs *[][]string) {
+	add := func(args []string) {
+		key := args[0]
+		if key == "or" {
+			key = args[1]
+		}
+		if !seen[key] {
+			seen[key] = true
+			*fieldArgs = append(*fieldArgs, args)
+		}
+	}
+	switch node := node.(type) {
+	case *parse.FieldNode:
+		add([]string{node.String()})
+	case *parse.ChainNode:
+		collectFieldArgs(node.Node, seen, fieldArgs)
+	case *parse.PipeNode:
+		if node == nil || len(node.Cmds) == 0 || len(node.Cmds[0].Args) == 0 {
+			return
+		}
+		if args, isDefault := pipeDefault(node); isDefault {
+			add(args)
+			return
+		}
+		for _, cmd := range node.Cmds {
+			collectFieldArgs(cmd, seen, fieldArgs)
+		}
+	case *parse.CommandNode:
+		if ident, isIdent := node.Args[0].(*parse.IdentifierNode); isIdent {
+			switch ident.Ident {
+			case "secret":
+				// Another service's secret.
+				return
+			case "default":
+				if len(node.Args) == 3 {
+					if field, isField := node.Args[2].(*parse.FieldNode); isField {
+						add([]string{"or", field.String(), node.Args[1].String()})
+						return
+					}
+				}
+			}
+		}
+		for _, arg := range node.Args {
+			collectFieldArgs(arg, seen, fieldArgs)
+		}
+	}
+}
+
 // GetInitialTemplateStructure Initializes the structure of the template section using the template directory path
 // Input:
 //   - A slice of the template file path delimited by "/"
@@ -0,0 +1,43 @@
+package extract
+
+import (
+	"reflect"
+	"testing"
+	"text/template"
+	"text/template/parse"
+
+	vcutils "github.com/trimble-oss/tierceron/pkg/cli/trcconfigbase/utils"
+)
+
+func TestLibraryArgs(t *testing.T) {
+	tests := []struct {
+		template  string
+		args      [][]string
+		isLibrary bool
+	}{
+		{`{{.host}}`, nil, false},
+		{`{{or .host "localhost"}}`, nil, false},
+		{`{{.host | default "localhost"}}`, [][]string{{"or", ".host", `"localhost"`}}, true},
+		{`{{default "localhost" .host}}`, [][]string{{"or", ".host", `"localhost"`}}, true},
+		{`{{required "host is required" .host}}`, [][]string{{".host"}}, true},
+		{`{{b64enc .password}}`, [][]string{{".password"}}, true},
+		{`{{quote .host .port}}`, [][]string{{".host"}, {".port"}}, true},
+		{`{{quote .host .host}}`, [][]string{{".host"}}, true},
+		{`{{indent 4 (toYaml .block)}}`, [][]string{{".block"}}, true},
+		{`{{quote (default "localhost" .host) (.port | default "8080")}}`, [][]string{{"or", ".host", `"localhost"`}, {"or", ".port", `"8080"`}}, true},
+		{`{{upper "literal"}}`, [][]string{}, true},
+		{`{{secret "Project/Service/key"}}`, [][]string{}, true},
+		{`{{quote (secret "Project/Service/key") .host}}`, [][]string{{".host"}}, true},
+		{`{{.host | quote}}`, nil, false},
+	}
+	for _, test := range tests {
+		tmpl, err := template.New("template").Funcs(vcutils.TemplateFuncMap(nil, nil, false, nil)).Parse(test.template)
+		if err != nil {
+			t.Fatalf("%s: %v", test.template, err)
+		}
+		args, isLibrary := libraryArgs(tmpl.Tree.Root.Nodes[0].(*parse.ActionNode).Pipe)
+		if isLibrary != test.isLibrary || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%s: libraryArgs %v %v, want %v %v", test.template, args, isLibrary, test.args, test.isLibrary)
+		}
+	}
+}
@@ -768,6 +768,14 @@ func GenerateSeedsFromVault(ctx eUtils.ProcessContext, configCtx *eUtils.ConfigC
 				}
 			}
 
+			if driverConfig.CertReport != nil {
+				// Audited only.
+				if certMod != nil {
+					certMod.Release()
+				}
+				continue
+			}
+
 			if driverConfig.Token == "novault" {
 				extractedValues, parseErr := eUtils.Parse(templatePath, project, service)
 				if parseErr != nil {
@@ -3,6 +3,7 @@ package utils
 import (
 	"bufio"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -20,6 +21,17 @@ type cert struct {
 	ApproleID string `yaml:"approleID"`
 	SecretID  string `yaml:"secretID"`
 	EnvCtx    string `yaml:"envCtx"`
+
+	VaultNamespace string `yaml:"vaultNamespace"`
+
+	// Login through an auth method other than approle.  See sys.AuthConfig.
+	AuthMethod string `yaml:"authMethod"`
+	AuthRole   string `yaml:"authRole"`
+	AuthMount  string `yaml:"authMount"`
+	JWTFile    string `yaml:"jwtFile"`
+	JWTEnv     string `yaml:"jwtEnv"`
+	ClientCert string `yaml:"clientCert"`
+	ClientKey  string `yaml:"clientKey"`
 }
 
 var prodRegions = []string{"west", "east", "ca"}
@@ -47,6 +59,122 @@ func (c *cert) getConfig(logger *log.Logger, file string) (*cert, error) {
 	return c, err
 }
 
+// Flags of the running tool shared by everything connecting to vault.
+var VaultNamespacePtr *string
+var AuthMethodPtr *string
+var AuthRolePtr *string
+
+// InitVaultFlags defines the flags shared by everything connecting to vault on flagset unless
+// they already are: -vaultNamespace, -authMethod and -authRole.
+func InitVaultFlags(flagset *flag.FlagSet) {
+	if flagset.Lookup("vaultNamespace") == nil {
+		VaultNamespacePtr = flagset.String("vaultNamespace", "", "Vault Enterprise namespace to work in, vaultNamespace in ~/.tierceron/config.yml by default")
+	}
+	if flagset.Lookup("authMethod") == nil {
+		AuthMethodPtr = flagset.String("authMethod", "", "Log in with kubernetes, jwt or cert instead of an approle, authMethod in ~/.tierceron/config.yml by default when no approle is given")
+	}
+	if flagset.Lookup("authRole") == nil {
+		AuthRolePtr = flagset.String("authRole", "", "Role to log in as with -authMethod, authRole in ~/.tierceron/config.yml by default")
+	}
+}
+
+// CheckVaultFlags points vault clients at the -vaultNamespace namespace once flags are parsed.
+func CheckVaultFlags() {
+	if VaultNamespacePtr != nil && *VaultNamespacePtr != "" {
+		helperkv.SetVaultNamespace(*VaultNamespacePtr)
+	}
+}
+
+// loadConfig - ~/.tierceron/config.yml if there is one.  Its vaultNamespace is used when no
+// namespace was given.
+func loadConfig(logger *log.Logger) *cert {
+	c := &cert{}
+	userHome, err := userHome(logger)
+	if err != nil {
+		return c
+	}
+	if _, err := os.Stat(userHome + "/.tierceron/config.yml"); err != nil {
+		return c
+	}
+	if _, err := c.getConfig(logger, "config.yml"); err != nil {
+		return &cert{}
+	}
+	if helperkv.GetVaultNamespace() == "" && c.VaultNamespace != "" {
+		helperkv.SetVaultNamespace(c.VaultNamespace)
+	}
+	return c
+}
+
+// authConfig - how to log in when -authMethod, or authMethod in config.yml when no approle
+// credentials were given, asks for an auth method other than approle.  nil otherwise.
+func (c *cert) authConfig(approleGiven bool) *sys.AuthConfig {
+	method, role := c.AuthMethod, c.AuthRole
+	if approleGiven {
+		method = ""
+	}
+	if AuthMethodPtr != nil && *AuthMethodPtr != "" {
+		method = *AuthMethodPtr
+	}
+	if AuthRolePtr != nil && *AuthRolePtr != "" {
+		role = *AuthRolePtr
+	}
+	if method == "" || method == "approle" {
+		return nil
+	}
+	return &sys.AuthConfig{
+		Method:     method,
+		Role:       role,
+		Mount:      c.AuthMount,
+		JWTFile:    c.JWTFile,
+		JWTEnv:     c.JWTEnv,
+		ClientCert: c.ClientCert,
+		ClientKey:  c.ClientKey,
+	}
+}
+
+// authMethodLogin obtains a token through an auth method, reusing a cached one while it lasts.
+func authMethodLogin(driverConfig *DriverConfig, authConfig *sys.AuthConfig, tokenPtr *string, envPtr *string, addrPtr *string, vaultHost string, ping bool) error {
+	if !sys.IsAuthMethod(authConfig.Method) {
+		return fmt.Errorf("unsupported auth method: %s", authConfig.Method)
+	}
+	if tokenPtr == nil || addrPtr == nil {
+		return errors.New("token and address are required for " + authConfig.Method + " login")
+	}
+	if *addrPtr == "" {
+		*addrPtr = vaultHost
+	}
+	if *addrPtr == "" {
+		return errors.New("vault address is required for " + authConfig.Method + " login")
+	}
+	env := ""
+	if envPtr != nil {
+		env = *envPtr
+	}
+
+	v, err := sys.NewVault(driverConfig.Insecure, *addrPtr, env, false, ping, false, driverConfig.CoreConfig.Log)
+	if v != nil {
+		defer v.Close()
+	} else if ping {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cacheDir := ""
+	if !driverConfig.IsShellSubProcess {
+		if userHome, err := userHome(driverConfig.CoreConfig.Log); err == nil {
+			cacheDir = userHome + "/.tierceron/token_cache"
+		}
+	}
+	*tokenPtr, err = v.LoginWithCache(*authConfig, cacheDir)
+	if err != nil {
+		return err
+	}
+	LogInfo(&driverConfig.CoreConfig, "Auth credentials obtained with "+authConfig.Method+" login.")
+	return nil
+}
+
 func userHome(logger *log.Logger) (string, error) {
 	userHome, err := os.UserHomeDir()
 	if err != nil {
@@ -73,10 +201,22 @@ func AutoAuth(driverConfig *DriverConfig,
 	var c cert
 	var v *sys.Vault
 
+	if addrPtr != nil && helperkv.IsLocalStorage(*addrPtr) {
+		// Memory and file storage have nothing to authenticate against.
+		return nil
+	}
+	fileConfig := &cert{}
+	if !driverConfig.IsShellSubProcess {
+		fileConfig = loadConfig(driverConfig.CoreConfig.Log)
+	}
 	if tokenPtr != nil && *tokenPtr != "" && addrPtr != nil && *addrPtr != "" && appRoleConfig != "deployauth" {
 		// For token based auth, auto auth not
 		return nil
 	}
+	approleGiven := secretIDPtr != nil && *secretIDPtr != "" && appRoleIDPtr != nil && *appRoleIDPtr != ""
+	if authConfig := fileConfig.authConfig(approleGiven); authConfig != nil {
+		return authMethodLogin(driverConfig, authConfig, tokenPtr, envPtr, addrPtr, fileConfig.VaultHost, ping)
+	}
 	var err error
 	// Get current user's home directory
 	userHome, err := userHome(driverConfig.CoreConfig.Log)
@@ -224,6 +364,9 @@ func AutoAuth(driverConfig *DriverConfig,
 			if appRoleIDPtr != nil && secretIDPtr != nil {
 				certConfigData = certConfigData + "approleID: " + *appRoleIDPtr + "\nsecretID: " + *secretIDPtr
 			}
+			if namespace := helperkv.GetVaultNamespace(); namespace != "" {
+				certConfigData = certConfigData + "\nvaultNamespace: " + namespace
+			}
 
 			dump = []byte(certConfigData)
 		} else if (override && !exists) || appRoleConfig == "deployauth" {
@@ -240,6 +383,9 @@ func AutoAuth(driverConfig *DriverConfig,
 			if envCtxPtr != nil {
 				certConfigData = certConfigData + "\nenvCtx: " + *envCtxPtr
 			}
+			if namespace := helperkv.GetVaultNamespace(); namespace != "" {
+				certConfigData = certConfigData + "\nvaultNamespace: " + namespace
+			}
 			dump = []byte(certConfigData)
 		}
 
@@ -0,0 +1,24 @@
+package utils
+
+import "testing"
+
+func TestAuthConfig(t *testing.T) {
+	fileConfig := &cert{AuthMethod: "kubernetes", AuthRole: "reader"}
+	defer func(method *string) { AuthMethodPtr = method }(AuthMethodPtr)
+
+	AuthMethodPtr = nil
+	if authConfig := fileConfig.authConfig(false); authConfig == nil || authConfig.Method != "kubernetes" || authConfig.Role != "reader" {
+		t.Fatalf("Expected kubernetes from config.yml, got %+v", authConfig)
+	}
+	// Approle credentials given explicitly win over config.yml.
+	if authConfig := fileConfig.authConfig(true); authConfig != nil {
+		t.Fatalf("Expected approle, got %+v", authConfig)
+	}
+
+	// Only -authMethod overrides them.
+	method := "jwt"
+	AuthMethodPtr = &method
+	if authConfig := fileConfig.authConfig(true); authConfig == nil || authConfig.Method != "jwt" {
+		t.Fatalf("Expected jwt from -authMethod, got %+v", authConfig)
+	}
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CertAudit describes a single certificate found in a cert template.
+type CertAudit struct {
+	Project       string
+	Service       string
+	Cert          string // certDestPath of the cert template.
+	Env           string
+	Subject       string
+	SANs          []string
+	Issuer        string
+	NotAfter      time.Time
+	DaysToExpiry  int
+	KeyMatch      string // yes, no or n/a when the source holds no private key.
+	ChainComplete bool
+	Problems      []string
+}
+
+// CertReport collects certificate audits across every cert template in a run.
+type CertReport struct {
+	ExpiryWindow int // Certificates expiring within this many days fail the report.
+	mutex        sync.Mutex
+	audits       []CertAudit
+}
+
+// Add records a certificate audit, flagging it if it expires within the window.  Safe for concurrent use.
+func (r *CertReport) Add(audit CertAudit) {
+	if r == nil {
+		return
+	}
+	switch {
+	case audit.NotAfter.IsZero():
+		// Couldn't be decoded.
+	case audit.DaysToExpiry < 0:
+		audit.Problems = append(audit.Problems, "expired "+strconv.Itoa(-audit.DaysToExpiry)+" day(s) ago")
+	case audit.DaysToExpiry <= r.ExpiryWindow:
+		audit.Problems = append(audit.Problems, "expires within "+strconv.Itoa(r.ExpiryWindow)+" day(s)")
+	}
+	r.mutex.Lock()
+	r.audits = append(r.audits, audit)
+	r.mutex.Unlock()
+}
+
+// Audits returns a sorted copy of the certificate audits recorded.
+func (r *CertReport) Audits() []CertAudit {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	audits := append([]CertAudit{}, r.audits...)
+	r.mutex.Unlock()
+
+	sort.Slice(audits, func(i, j int) bool {
+		a, b := audits[i], audits[j]
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		if a.Cert != b.Cert {
+			return a.Cert < b.Cert
+		}
+		return a.Env < b.Env
+	})
+	return audits
+}
+
+// Failed returns the number of certificates with problems.
+func (r *CertReport) Failed() int {
+	failed := 0
+	for _, audit := range r.Audits() {
+		if len(audit.Problems) > 0 {
+			failed++
+		}
+	}
+	return failed
+}
+
+// String renders the consolidated report, one certificate per block.
+func (r *CertReport) String() string {
+	audits := r.Audits()
+	var report strings.Builder
+	report.WriteString(fmt.Sprintf("Certificate report: %d certificate(s), %d failed\n", len(audits), r.Failed()))
+	for _, audit := range audits {
+		status := "OK"
+		if len(audit.Problems) > 0 {
+			status = "FAIL: " + strings.Join(audit.Problems, "; ")
+		}
+		report.WriteString(fmt.Sprintf("%s/%s %s (%s) %s\n", audit.Project, audit.Service, audit.Cert, audit.Env, status))
+		if audit.NotAfter.IsZero() {
+			continue
+		}
+		report.WriteString(fmt.Sprintf("    subject:  %s\n", audit.Subject))
+		report.WriteString(fmt.Sprintf("    sans:     %s\n", strings.Join(audit.SANs, ", ")))
+		report.WriteString(fmt.Sprintf("    issuer:   %s\n", audit.Issuer))
+		report.WriteString(fmt.Sprintf("    expires:  %s (%d day(s))\n", audit.NotAfter.UTC().Format(time.RFC3339), audit.DaysToExpiry))
+		report.WriteString(fmt.Sprintf("    key:      %s\n", audit.KeyMatch))
+		report.WriteString(fmt.Sprintf("    chain:    %t\n", audit.ChainComplete))
+	}
+	return strings.TrimSuffix(report.String(), "\n")
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCertReportAdd(t *testing.T) {
+	notAfter := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		audit    CertAudit
+		problems []string
+	}{
+		{name: "outside window", audit: CertAudit{NotAfter: notAfter, DaysToExpiry: 31}},
+		{name: "inside window", audit: CertAudit{NotAfter: notAfter, DaysToExpiry: 30}, problems: []string{"expires within 30 day(s)"}},
+		{name: "expired", audit: CertAudit{NotAfter: notAfter, DaysToExpiry: -2}, problems: []string{"expired 2 day(s) ago"}},
+		{name: "undecoded", audit: CertAudit{Problems: []string{"failed to parse certificate"}}, problems: []string{"failed to parse certificate"}},
+		{
+			name:     "keeps audit problems",
+			audit:    CertAudit{NotAfter: notAfter, DaysToExpiry: 5, Problems: []string{"private key does not match certificate"}},
+			problems: []string{"private key does not match certificate", "expires within 30 day(s)"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := &CertReport{ExpiryWindow: 30}
+			report.Add(test.audit)
+			audits := report.Audits()
+			if len(audits) != 1 {
+				t.Fatalf("Expected 1 audit, got %d", len(audits))
+			}
+			if strings.Join(audits[0].Problems, "; ") != strings.Join(test.problems, "; ") {
+				t.Fatalf("Expected problems %v, got %v", test.problems, audits[0].Problems)
+			}
+			if failed := report.Failed(); (failed == 1) != (len(test.problems) > 0) {
+				t.Fatalf("Unexpected failed count %d", failed)
+			}
+		})
+	}
+
+	var report *CertReport
+	report.Add(CertAudit{})
+	if report.Audits() != nil || report.Failed() != 0 {
+		t.Fatal("Expected a nil report to record nothing")
+	}
+}
+
+func TestCertReportString(t *testing.T) {
+	report := &CertReport{ExpiryWindow: 30}
+	report.Add(CertAudit{
+		Project:       "Proj",
+		Service:       "Svc",
+		Cert:          "./certs/z.pem",
+		Env:           "dev",
+		Subject:       "CN=svc",
+		SANs:          []string{"svc.example.com", "10.0.0.1"},
+		Issuer:        "CN=ca",
+		NotAfter:      time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		DaysToExpiry:  400,
+		KeyMatch:      "yes",
+		ChainComplete: true,
+	})
+	report.Add(CertAudit{Project: "Proj", Service: "Svc", Cert: "./certs/a.pem", Env: "dev", Problems: []string{"failed to parse certificate"}})
+
+	expected := `Certificate report: 2 certificate(s), 1 failed
+Proj/Svc ./certs/a.pem (dev) FAIL: failed to parse certificate
+Proj/Svc ./certs/z.pem (dev) OK
+    subject:  CN=svc
+    sans:     svc.example.com, 10.0.0.1
+    issuer:   CN=ca
+    expires:  2030-01-02T03:04:05Z (400 day(s))
+    key:      yes
+    chain:    true`
+	if got := report.String(); got != expected {
+		t.Fatalf("Unexpected report:\n%s", got)
+	}
+}
@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Diff output formats.
+const (
+	DiffFormatText = "text"
+	DiffFormatJson = "json"
+)
+
+// DiffLine is a single removed (-) or added (+) line.  Line is the line number in the
+// from env's output for removed lines and in the to env's output for added lines.
+type DiffLine struct {
+	Op   string `json:"op"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+	Key  string `json:"key,omitempty"` // Key assigned on the line, if recognizable.
+}
+
+// DiffHunk is a run of consecutive changed lines.
+type DiffHunk struct {
+	FromStart int        `json:"fromStart"`
+	FromLines int        `json:"fromLines"`
+	ToStart   int        `json:"toStart"`
+	ToLines   int        `json:"toLines"`
+	Lines     []DiffLine `json:"lines"`
+}
+
+// EnvDiff is the difference in a file between two environments.
+type EnvDiff struct {
+	From       string     `json:"from"`
+	To         string     `json:"to"`
+	Added      int        `json:"added"`
+	Removed    int        `json:"removed"`
+	Changed    int        `json:"changed,omitempty"`
+	Disallowed int        `json:"disallowed,omitempty"` // Changes outside the allow-list.
+	Hunks      []DiffHunk `json:"hunks,omitempty"`
+	Keys       []KeyDiff  `json:"keys,omitempty"` // With -semanticDiff, in place of hunks.
+}
+
+// FileDiff holds every env pair's differences for a file.
+type FileDiff struct {
+	File    string    `json:"file"`
+	Missing []string  `json:"missing,omitempty"` // Environments with no output for the file.
+	Diffs   []EnvDiff `json:"diffs"`
+}
+
+// DiffSummary counts differences across the whole report.
+type DiffSummary struct {
+	Files        int `json:"files"`
+	ChangedFiles int `json:"changedFiles"`
+	Pairs        int `json:"pairs"`
+	ChangedPairs int `json:"changedPairs"`
+	Added        int `json:"added"`
+	Removed      int `json:"removed"`
+	Changed      int `json:"changed"`
+	Disallowed   int `json:"disallowed"`
+}
+
+// DiffReport is the machine readable form of a -diff run.
+type DiffReport struct {
+	Files   []FileDiff  `json:"files"`
+	Summary DiffSummary `json:"summary"`
+}
+
+// Matches key: value, key=value and "key": value
+var diffKeyRegex = regexp.MustCompile(`^\s*["']?([A-Za-z0-9_.\-]+)["']?\s*[:=]`)
+
+func diffLineKey(text string) string {
+	match := diffKeyRegex.FindStringSubmatch(text)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// Line indexes encoded as runes skip the surrogate range, which doesn't survive the diff's
+// conversion to string.
+const (
+	surrogateMin = 0xD800
+	surrogateLen = 0x800
+)
+
+func lineRune(index int) rune {
+	if index >= surrogateMin {
+		index += surrogateLen
+	}
+	return rune(index)
+}
+
+func runeLine(r rune) int {
+	index := int(r)
+	if index >= surrogateMin+surrogateLen {
+		index -= surrogateLen
+	}
+	return index
+}
+
+// LineDiffHunks compares two outputs line by line, returning the changed lines grouped into hunks.
+// Values assigned to super-secret keys are masked.
+func LineDiffHunks(stringA *string, stringB *string, secrets *DiffSecrets) []DiffHunk {
+	// Each distinct line becomes a rune so the diff runs line by line.
+	// DiffLinesToChars in this version of go-diff doesn't round trip.
+	lines := []string{}
+	lineRunes := map[string]rune{}
+	toRunes := func(text string) []rune {
+		runes := []rune{}
+		for _, line := range strings.SplitAfter(text, "\n") {
+			if line == "" {
+				continue
+			}
+			line = strings.TrimRight(line, "\r\n")
+			r, ok := lineRunes[line]
+			if !ok {
+				r = lineRune(len(lines))
+				lineRunes[line] = r
+				lines = append(lines, line)
+			}
+			runes = append(runes, r)
+		}
+		return runes
+	}
+	runesA := toRunes(*stringA)
+	runesB := toRunes(*stringB)
+	diffs := diffmatchpatch.New().DiffMainRunes(runesA, runesB, false)
+
+	hunks := []DiffHunk{}
+	var hunk *DiffHunk
+	lineA, lineB := 1, 1
+	for _, diff := range diffs {
+		diffLines := []string{}
+		for _, r := range diff.Text {
+			if index := runeLine(r); index < len(lines) {
+				diffLines = append(diffLines, lines[index])
+			}
+		}
+		if diff.Type == diffmatchpatch.DiffEqual {
+			if hunk != nil {
+				hunks = append(hunks, *hunk)
+				hunk = nil
+			}
+			lineA += len(diffLines)
+			lineB += len(diffLines)
+			continue
+		}
+		if hunk == nil {
+			hunk = &DiffHunk{FromStart: lineA, ToStart: lineB, Lines: []DiffLine{}}
+		}
+		for _, text := range diffLines {
+			if diff.Type == diffmatchpatch.DiffDelete {
+				hunk.Lines = append(hunk.Lines, DiffLine{Op: "-", Line: lineA, Text: secrets.MaskLine(text), Key: diffLineKey(text)})
+				hunk.FromLines++
+				lineA++
+			} else {
+				hunk.Lines = append(hunk.Lines, DiffLine{Op: "+", Line: lineB, Text: secrets.MaskLine(text), Key: diffLineKey(text)})
+				hunk.ToLines++
+				lineB++
+			}
+		}
+	}
+	if hunk != nil {
+		hunks = append(hunks, *hunk)
+	}
+	return hunks
+}
+
+// diffEnvName - env as displayed in diff output: dev_0 -> dev_latest
+func diffEnvName(env string) string {
+	envVersion := SplitEnv(env)
+	if len(envVersion) > 1 && envVersion[1] == "0" {
+		return envVersion[0] + "_latest"
+	}
+	return env
+}
+
+// addFile diffs a file across every pair of environments in keys (env||file).
+func (report *DiffReport) addFile(configCtx *ConfigContext, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	fileName := strings.Split(keys[0], "||")[1]
+	if fileName == "" {
+		return
+	}
+	if strings.Count(fileName, "_") == 2 {
+		fileSplit := strings.Split(fileName, "_")
+		fileName = fileSplit[0] + "_" + fileSplit[len(fileSplit)-1]
+	}
+	fileDiff := FileDiff{File: fileName, Diffs: []EnvDiff{}}
+
+	envs := []string{}
+	contents := []*string{}
+	configCtx.Mutex.Lock()
+	for _, key := range keys {
+		keySplit := strings.Split(key, "||")
+		content, ok := configCtx.ResultMap[key]
+		if !ok {
+			content, ok = configCtx.ResultMap["||"+keySplit[1]]
+		}
+		if !ok || content == nil {
+			fileDiff.Missing = append(fileDiff.Missing, diffEnvName(keySplit[0]))
+			continue
+		}
+		envs = append(envs, diffEnvName(keySplit[0]))
+		contents = append(contents, content)
+	}
+	configCtx.Mutex.Unlock()
+
+	allowed := map[string]bool{}
+	for _, key := range configCtx.DiffAllow {
+		allowed[key] = true
+	}
+
+	changed := len(fileDiff.Missing) > 0
+	if changed && len(configCtx.DiffAllow) > 0 {
+		report.Summary.Disallowed += len(fileDiff.Missing)
+	}
+	for i := 0; i < len(envs); i++ {
+		for j := i + 1; j < len(envs); j++ {
+			envDiff := EnvDiff{From: envs[i], To: envs[j]}
+			keyDiffs, semantic := []KeyDiff(nil), false
+			if configCtx.SemanticDiff {
+				keyDiffs, semantic = SemanticDiff(fileName, contents[i], contents[j], configCtx.DiffSecrets)
+			}
+			if semantic {
+				envDiff.Keys = keyDiffs
+				for _, keyDiff := range keyDiffs {
+					switch keyDiff.Op {
+					case "added":
+						envDiff.Added++
+					case "removed":
+						envDiff.Removed++
+					default:
+						envDiff.Changed++
+					}
+					if len(configCtx.DiffAllow) > 0 && !allowed[keyDiff.Path] && !allowed[keyDiff.Path[strings.LastIndex(keyDiff.Path, ".")+1:]] {
+						envDiff.Disallowed++
+					}
+				}
+			} else {
+				envDiff.Hunks = LineDiffHunks(contents[i], contents[j], configCtx.DiffSecrets)
+				for _, hunk := range envDiff.Hunks {
+					envDiff.Added += hunk.ToLines
+					envDiff.Removed += hunk.FromLines
+					for _, line := range hunk.Lines {
+						if len(configCtx.DiffAllow) > 0 && !allowed[line.Key] {
+							envDiff.Disallowed++
+						}
+					}
+				}
+			}
+			report.Summary.Pairs++
+			if len(envDiff.Hunks) > 0 || len(envDiff.Keys) > 0 {
+				changed = true
+				report.Summary.ChangedPairs++
+			}
+			report.Summary.Added += envDiff.Added
+			report.Summary.Removed += envDiff.Removed
+			report.Summary.Changed += envDiff.Changed
+			report.Summary.Disallowed += envDiff.Disallowed
+			fileDiff.Diffs = append(fileDiff.Diffs, envDiff)
+		}
+	}
+	report.Summary.Files++
+	if changed {
+		report.Summary.ChangedFiles++
+	}
+	report.Files = append(report.Files, fileDiff)
+}
+
+// String renders the report as indented JSON.
+func (report *DiffReport) String() string {
+	reportBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "{}"
+	}
+	return string(reportBytes)
+}
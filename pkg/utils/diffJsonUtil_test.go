@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLineDiffHunks(t *testing.T) {
+	from := "host: localhost\nport: 8080\nname: service\n"
+	to := "host: localhost\nport: 9090\nname: service\nlevel: debug\n"
+	hunks := LineDiffHunks(&from, &to, nil)
+	if len(hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %v", hunks)
+	}
+	if hunk := hunks[0]; hunk.FromStart != 2 || hunk.FromLines != 1 || hunk.ToStart != 2 || hunk.ToLines != 1 ||
+		hunk.Lines[0] != (DiffLine{Op: "-", Line: 2, Text: "port: 8080", Key: "port"}) ||
+		hunk.Lines[1] != (DiffLine{Op: "+", Line: 2, Text: "port: 9090", Key: "port"}) {
+		t.Fatalf("Unexpected first hunk %v", hunk)
+	}
+	if hunk := hunks[1]; hunk.FromStart != 4 || hunk.ToStart != 4 || hunk.ToLines != 1 || hunk.Lines[0].Text != "level: debug" {
+		t.Fatalf("Unexpected second hunk %v", hunk)
+	}
+
+	if hunks := LineDiffHunks(&from, &from, nil); len(hunks) != 0 {
+		t.Fatalf("Expected no hunks, got %v", hunks)
+	}
+}
+
+func TestLineDiffHunksSurrogateLines(t *testing.T) {
+	// Enough distinct lines that their indexes pass through the surrogate range.
+	var lines strings.Builder
+	for i := 0; i < surrogateMin+surrogateLen+100; i++ {
+		lines.WriteString(fmt.Sprintf("key%d: %d\n", i, i))
+	}
+	from := lines.String() + "last: 1\n"
+	to := lines.String() + "last: 2\n"
+	hunks := LineDiffHunks(&from, &to, nil)
+	if len(hunks) != 1 || len(hunks[0].Lines) != 2 || hunks[0].Lines[0].Text != "last: 1" || hunks[0].Lines[1].Text != "last: 2" {
+		t.Fatalf("Unexpected hunks %v", hunks)
+	}
+
+	for _, index := range []int{0, surrogateMin - 1, surrogateMin, surrogateMin + surrogateLen} {
+		if r := lineRune(index); r >= surrogateMin && r < surrogateMin+surrogateLen || runeLine(r) != index {
+			t.Errorf("Line %d encoded as %U", index, r)
+		}
+	}
+}
+
+func TestLineDiffHunksMasksSecrets(t *testing.T) {
+	secrets := &DiffSecrets{}
+	secrets.AddKey("password")
+	from := "user: admin\npassword: hunter22\n"
+	to := "user: root\npassword: hunter23\n"
+	expected := []string{"user: admin", "password: ********", "user: root", "password: ********"}
+	texts := []string{}
+	for _, hunk := range LineDiffHunks(&from, &to, secrets) {
+		for _, line := range hunk.Lines {
+			texts = append(texts, line.Text)
+		}
+	}
+	if strings.Join(texts, "|") != strings.Join(expected, "|") {
+		t.Fatalf("Expected %v, got %v", expected, texts)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"math"
 	"net/url"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -363,6 +364,17 @@ func RemoveDuplicateValues(intSlice []string) []string {
 	return list
 }
 
+// fileDiff compares the file between two environments, by key with -semanticDiff when the
+// format is understood and line by line otherwise.  Lines only in stringA are +, only in stringB are -.
+func (cfgContext *ConfigContext) fileDiff(fileName string, stringA *string, stringB *string) string {
+	if cfgContext.SemanticDiff {
+		if keyDiffs, ok := SemanticDiff(fileName, stringB, stringA, cfgContext.DiffSecrets); ok {
+			return FormatKeyDiffs(keyDiffs)
+		}
+	}
+	return LineByLineDiff(stringA, stringB, true, false)
+}
+
 func DiffHelper(configCtx *ConfigContext, config bool) {
 	fileIndex := 0
 	keys := []string{}
@@ -412,7 +424,11 @@ func DiffHelper(configCtx *ConfigContext, config bool) {
 			time.Sleep(time.Second)
 			sleepCount++
 			if sleepCount >= 5 {
-				fmt.Println("Timeout: Attempted to wait for remaining configs to come in. Attempting incomplete diff.")
+				if configCtx.DiffFormat == DiffFormatJson {
+					fmt.Fprintln(os.Stderr, "Timeout: Attempted to wait for remaining configs to come in. Attempting incomplete diff.")
+				} else {
+					fmt.Println("Timeout: Attempted to wait for remaining configs to come in. Attempting incomplete diff.")
+				}
 				break
 			} else if len(configCtx.ResultMap) == int(configCtx.DiffFileCount)*configCtx.EnvLength {
 				break
@@ -448,6 +464,11 @@ func DiffHelper(configCtx *ConfigContext, config bool) {
 		}
 	}
 
+	var diffReport *DiffReport
+	if configCtx.DiffFormat == DiffFormatJson {
+		diffReport = &DiffReport{Files: []FileDiff{}}
+	}
+
 	//Diff resultMap using fileList
 	for _, fileName := range fileList {
 		if config {
@@ -460,6 +481,12 @@ func DiffHelper(configCtx *ConfigContext, config bool) {
 			}
 		}
 
+		if diffReport != nil {
+			diffReport.addFile(configCtx, keys)
+			keys = keys[:0]
+			continue
+		}
+
 		Reset := "\033[0m"
 		Red := "\033[31m"
 		Green := "\033[32m"
@@ -540,17 +567,17 @@ func DiffHelper(configCtx *ConfigContext, config bool) {
 			}
 
 			fmt.Print("\n" + Yellow + keySplitA[1] + " (" + Reset + Red + "-Env-" + keySplitA[0] + Reset + Green + " +Env-" + keySplitB[0] + Reset + Yellow + ")" + Reset + "\n")
-			fmt.Println(LineByLineDiff(envFileKeyB, envFileKeyA, true, false))
+			fmt.Println(configCtx.fileDiff(keySplitA[1], envFileKeyB, envFileKeyA))
 			fmt.Print("\n" + Yellow + keySplitA[1] + " (" + Reset + Red + "-Env-" + keySplitA[0] + Reset + Green + " +Env-" + keySplitC[0] + Reset + Yellow + ")" + Reset + "\n")
-			fmt.Println(LineByLineDiff(envFileKeyC, envFileKeyA, true, false))
+			fmt.Println(configCtx.fileDiff(keySplitA[1], envFileKeyC, envFileKeyA))
 			fmt.Print("\n" + Yellow + keySplitA[1] + " (" + Reset + Red + "-Env-" + keySplitA[0] + Reset + Green + " +Env-" + keySplitD[0] + Reset + Yellow + ")" + Reset + "\n")
-			fmt.Println(LineByLineDiff(envFileKeyD, envFileKeyA, true, false))
+			fmt.Println(configCtx.fileDiff(keySplitA[1], envFileKeyD, envFileKeyA))
 			fmt.Print("\n" + Yellow + keySplitA[1] + " (" + Reset + Red + "-Env-" + keySplitB[0] + Reset + Green + " +Env-" + keySplitC[0] + Reset + Yellow + ")" + Reset + "\n")
-			fmt.Println(LineByLineDiff(envFileKeyC, envFileKeyB, true, false))
+			fmt.Println(configCtx.fileDiff(keySplitA[1], envFileKeyC, envFileKeyB))
 			fmt.Print("\n" + Yellow + keySplitA[1] + " (" + Reset + Red + "-Env-" + keySplitB[0] + Reset + Green + " +Env-" + keySplitD[0] + Reset + Yellow + ")" + Reset + "\n")
-			fmt.Println(LineByLineDiff(envFileKeyD, envFileKeyB, true, false))
+			fmt.Println(configCtx.fileDiff(keySplitA[1], envFileKeyD, envFileKeyB))
 			fmt.Print("\n" + Yellow + keySplitA[1] + " (" + Reset + Red + "-Env-" + keySplitC[0] + Reset + Green + " +Env-" + keySplitD[0] + Reset + Yellow + ")" + Reset + "\n")
-			fmt.Println(LineByLineDiff(envFileKeyD, envFileKeyC, true, false))
+			fmt.Println(configCtx.fileDiff(keySplitA[1], envFileKeyD, envFileKeyC))
 		case 3:
 			keyC := keys[2]
 			keySplitC := strings.Split(keyC, "||")
@@ -569,14 +596,14 @@ func DiffHelper(configCtx *ConfigContext, config bool) {
 			}
 
 			fmt.Print("\n" + Yellow + keySplitA[1] + " (" + Reset + Red + "-Env-" + keySplitA[0] + Reset + Green + " +Env-" + keySplitB[0] + Reset + Yellow + ")" + Reset + "\n")
-			fmt.Println(LineByLineDiff(envFileKeyB, envFileKeyA, true, false))
+			fmt.Println(configCtx.fileDiff(keySplitA[1], envFileKeyB, envFileKeyA))
 			fmt.Print("\n" + Yellow + keySplitA[1] + " (" + Reset + Red + "-Env-" + keySplitA[0] + Reset + Green + " +Env-" + keySplitC[0] + Reset + Yellow + ")" + Reset + "\n")
-			fmt.Println(LineByLineDiff(envFileKeyC, envFileKeyA, true, false))
+			fmt.Println(configCtx.fileDiff(keySplitA[1], envFileKeyC, envFileKeyA))
 			fmt.Print("\n" + Yellow + keySplitA[1] + " (" + Reset + Red + "-Env-" + keySplitB[0] + Reset + Green + " +Env-" + keySplitC[0] + Reset + Yellow + ")" + Reset + "\n")
-			fmt.Println(LineByLineDiff(envFileKeyC, envFileKeyB, true, false))
+			fmt.Println(configCtx.fileDiff(keySplitA[1], envFileKeyC, envFileKeyB))
 		default:
 			fmt.Print("\n" + Yellow + keySplitA[1] + " (" + Reset + Red + "-Env-" + keySplitA[0] + Reset + Green + " +Env-" + keySplitB[0] + Reset + Yellow + ")" + Reset + "\n")
-			fmt.Println(LineByLineDiff(envFileKeyB, envFileKeyA, true, false))
+			fmt.Println(configCtx.fileDiff(keySplitA[1], envFileKeyB, envFileKeyA))
 		}
 
 		//Seperator
@@ -587,4 +614,9 @@ func DiffHelper(configCtx *ConfigContext, config bool) {
 		}
 		keys = keys[:0] //Cleans keys for next file
 	}
+
+	if diffReport != nil {
+		configCtx.DiffDisallowed = diffReport.Summary.Disallowed
+		fmt.Println(diffReport.String())
+	}
 }
@@ -1,11 +1,13 @@
 package utils
 
 import (
+	"errors"
 	"math"
 	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/pavlo-v-chernykh/keystore-go/v4"
 	"github.com/trimble-oss/tierceron/buildopts/coreopts"
@@ -32,6 +34,28 @@ type ConfigContext struct {
 	EnvLength            int
 	ConfigWg             sync.WaitGroup
 	Mutex                *sync.Mutex
+	DiffFormat           string   // text or json
+	DiffAllow            []string // Keys allowed to differ between environments.
+	DiffDisallowed       int      // Changes found outside DiffAllow.
+	SemanticDiff         bool     // Diff known formats by key rather than by line.
+	DiffSecrets          *DiffSecrets
+
+	errorsLock sync.Mutex
+	errors     []error // Errors that fail the run once every configuration is generated.
+}
+
+// AddError records an error that fails the run once every configuration is generated.
+func (cfgContext *ConfigContext) AddError(err error) {
+	cfgContext.errorsLock.Lock()
+	defer cfgContext.errorsLock.Unlock()
+	cfgContext.errors = append(cfgContext.errors, err)
+}
+
+// Err - the errors recorded by AddError, nil if none.
+func (cfgContext *ConfigContext) Err() error {
+	cfgContext.errorsLock.Lock()
+	defer cfgContext.errorsLock.Unlock()
+	return errors.Join(cfgContext.errors...)
 }
 
 func (cfgContext *ConfigContext) SetDiffFileCount(cnt int) {
@@ -70,6 +94,7 @@ type DriverConfig struct {
 	FileFilter    []string // Which systems to operate on.
 	SubPathFilter []string // Which subpaths to operate on.
 	PathParam     string   // Path parameter for dynamic pathing...
+	CheckAndSet   bool     // Writes fail when a path changed after seeding began.
 
 	SecretMode bool
 	// Tierceron source and destination I/O
@@ -78,6 +103,8 @@ type DriverConfig struct {
 	OutputMemCache    bool
 	MemFs             MemoryFileSystem
 	CertPathOverrides map[string]string // certFileName -> certDest
+	OutputFormat      string            // k8s-secret or k8s-configmap: one manifest per service instead of files.
+	HistoryLimit      int               // Generations of output kept under .trc_history.  0 keeps none.
 
 	// Config modes....
 	ZeroConfig  bool
@@ -89,16 +116,40 @@ type DriverConfig struct {
 	Clean  bool
 	Update func(*ConfigContext, *string, string)
 
+	// Strict rendering
+	Strict         bool                 // Treat template keys without values as errors.
+	UnresolvedKeys *UnresolvedKeyReport // Shared across a run to report every unresolved key at once.
+
+	Partials map[string]string // Common partials by name -- loaded once per run.
+
+	// Watch mode
+	RenderedTemplates *RenderedTemplates // When set, records each template configured in a run.
+
 	// KeyStore Output tooling
-	KeyStore         *keystore.KeyStore
-	KeystorePassword string
-	WantKeystore     string // If provided and non nil, pem files will be put into a java compatible keystore.
+	KeyStore               *keystore.KeyStore
+	KeystorePassword       string
+	WantKeystore           string            // If provided and non nil, pem files will be put into a java compatible keystore (.jks, or .p12/.pfx for PKCS#12).
+	KeystoreAliasPasswords map[string][]byte // Private key entry password by alias.
+
+	// Preview
+	Preview       bool // Mask every value read from super-secrets: «secret:Project/Service/key@v12»
+	PreviewStdout bool // With Preview, print configured templates rather than writing them.
+
+	// Region rendering
+	RenderRegions   []string              // When set, each template is configured once per region into EndDir/<region>.
+	RegionVariances *RegionVarianceReport // Keys whose values vary by region across a -regions run.
+
+	// Certificate audit
+	CertReport *CertReport // When set, certificates are audited instead of written.
 
 	// Diff tooling
 	Diff          bool
 	DiffCounter   int
 	VersionInfo   func(map[string]interface{}, bool, string, bool)
 	VersionFilter []string
+	AsOf          time.Time    // Point in time to configure from -- each path read as of this instant.
+	DiffVersion   string       // Labels this side of a diff when it isn't a vault version, e.g. asOf.
+	DiffSecrets   *DiffSecrets // When set, collects super-secret values for diffs to mask.
 
 	// Vault Pathing....
 	// This section stores information useful in directing I/O with Vault.
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RegionVariance describes a template key whose value differs between regions.
+type RegionVariance struct {
+	Project  string
+	Service  string
+	Template string
+	Key      string
+	Regions  []string // Regions overriding the key with a region specific value.
+}
+
+// RegionVarianceReport collects the keys that vary by region across every template rendered in a run.
+type RegionVarianceReport struct {
+	mutex     sync.Mutex
+	variances []RegionVariance
+}
+
+// Add records a key that varies by region.  Safe for concurrent use.
+func (r *RegionVarianceReport) Add(variance RegionVariance) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	r.variances = append(r.variances, variance)
+	r.mutex.Unlock()
+}
+
+// Variances returns a sorted copy of the keys recorded.
+func (r *RegionVarianceReport) Variances() []RegionVariance {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	variances := append([]RegionVariance{}, r.variances...)
+	r.mutex.Unlock()
+
+	sort.Slice(variances, func(i, j int) bool {
+		a, b := variances[i], variances[j]
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		if a.Template != b.Template {
+			return a.Template < b.Template
+		}
+		return a.Key < b.Key
+	})
+	return variances
+}
+
+// String renders the consolidated report, one key per line.
+func (r *RegionVarianceReport) String() string {
+	variances := r.Variances()
+	var report strings.Builder
+	report.WriteString(fmt.Sprintf("Regions: %d key(s) vary by region\n", len(variances)))
+	report.WriteString(fmt.Sprintf("%-20s %-25s %-30s %-30s %s\n", "PROJECT", "SERVICE", "TEMPLATE", "KEY", "REGIONS"))
+	for _, variance := range variances {
+		report.WriteString(fmt.Sprintf("%-20s %-25s %-30s %-30s %s\n", variance.Project, variance.Service, variance.Template, variance.Key, strings.Join(variance.Regions, ",")))
+	}
+	return strings.TrimSuffix(report.String(), "\n")
+}
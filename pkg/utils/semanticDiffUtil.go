@@ -0,0 +1,365 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+const maskedValue = "********"
+
+// DiffSecrets collects the config keys assigned super-secret values during a run so diffs can
+// mask them.  Masking goes by key, so a secret embedded in a larger value is still hidden.
+type DiffSecrets struct {
+	mutex sync.Mutex
+	keys  map[string]bool
+}
+
+// AddKey records a key holding a super-secret value.  Safe for concurrent use.
+func (s *DiffSecrets) AddKey(key string) {
+	if s == nil || key == "" {
+		return
+	}
+	s.mutex.Lock()
+	if s.keys == nil {
+		s.keys = map[string]bool{}
+	}
+	s.keys[key] = true
+	s.mutex.Unlock()
+}
+
+// AddTemplateKey records the super-secret templateKey along with every config key template
+// assigns it to, e.g. db.password for db.password={{.password}}.
+func (s *DiffSecrets) AddTemplateKey(template string, templateKey string) {
+	if s == nil || templateKey == "" {
+		return
+	}
+	s.AddKey(templateKey)
+	templateKeyRegex := regexp.MustCompile(`{{[^}]*\.` + regexp.QuoteMeta(templateKey) + `\b`)
+	for _, line := range strings.Split(template, "\n") {
+		if templateKeyRegex.MatchString(line) {
+			s.AddKey(lineKey(line))
+		}
+	}
+}
+
+// IsSecret - true if the dotted key path, or its last named element, holds a super-secret.
+func (s *DiffSecrets) IsSecret(path string) bool {
+	if s == nil || path == "" {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.keys[path] {
+		return true
+	}
+	elements := strings.Split(path, ".")
+	for i := len(elements) - 1; i >= 0; i-- {
+		element := strings.TrimPrefix(elements[i], "@")
+		if bracket := strings.Index(element, "["); bracket > 0 {
+			element = element[:bracket]
+		}
+		if _, err := strconv.Atoi(element); err == nil {
+			// List entry: the list's key decides.
+			continue
+		}
+		return s.keys[element]
+	}
+	return false
+}
+
+// Mask hides value if the key at path holds a super-secret.
+func (s *DiffSecrets) Mask(path string, value string) string {
+	if s.IsSecret(path) {
+		return maskedValue
+	}
+	return value
+}
+
+// MaskLine hides the value a line assigns to a super-secret key.
+func (s *DiffSecrets) MaskLine(line string) string {
+	if s == nil {
+		return line
+	}
+	if match := diffKeyRegex.FindStringSubmatchIndex(line); match != nil {
+		if s.IsSecret(line[match[2]:match[3]]) {
+			assignment := line[:match[1]]
+			if strings.HasSuffix(assignment, ":") {
+				assignment += " "
+			}
+			return assignment + maskedValue
+		}
+		return line
+	}
+	if match := xmlElementLineRegex.FindStringSubmatch(line); match != nil && s.IsSecret(match[2]) {
+		return match[1] + maskedValue + match[3]
+	}
+	return line
+}
+
+// Matches <element attr="...">text</element>
+var xmlElementLineRegex = regexp.MustCompile(`^(\s*<([A-Za-z0-9_.\-:]+)[^>]*>)[^<]*(</.*)$`)
+
+// lineKey - key assigned on a line of properties, yml, json or xml, if recognizable.
+func lineKey(line string) string {
+	if key := diffLineKey(line); key != "" {
+		return key
+	}
+	if match := xmlElementLineRegex.FindStringSubmatch(line); match != nil {
+		return match[2]
+	}
+	return ""
+}
+
+// KeyDiff is a key added, removed or changed between two environments.
+type KeyDiff struct {
+	Op   string `json:"op"`   // added, removed or changed
+	Path string `json:"path"` // Dotted path to the key.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// IsSemanticDiffFile - true if fileName is a format the semantic diff understands.
+func IsSemanticDiffFile(fileName string) bool {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yml", ".yaml", ".json", ".properties", ".ini", ".xml":
+		return true
+	}
+	return false
+}
+
+// SemanticDiff compares two rendered outputs by key rather than by line, so reordered or
+// reformatted keys aren't reported.  The format comes from fileName's extension.  Returns false
+// when the format isn't understood or either output can't be parsed; use a line diff instead.
+func SemanticDiff(fileName string, stringA *string, stringB *string, secrets *DiffSecrets) ([]KeyDiff, bool) {
+	if !IsSemanticDiffFile(fileName) || stringA == nil || stringB == nil {
+		return nil, false
+	}
+	keysA, errA := flattenConfig(fileName, *stringA)
+	keysB, errB := flattenConfig(fileName, *stringB)
+	if errA != nil || errB != nil {
+		return nil, false
+	}
+	return diffKeys(keysA, keysB, secrets), true
+}
+
+// DataDiff compares two versions of the data at a vault path by key.
+func DataDiff(dataA map[string]interface{}, dataB map[string]interface{}, secrets *DiffSecrets) []KeyDiff {
+	keysA := map[string]string{}
+	keysB := map[string]string{}
+	for key, value := range dataA {
+		flattenValue(key, value, keysA)
+	}
+	for key, value := range dataB {
+		flattenValue(key, value, keysB)
+	}
+	return diffKeys(keysA, keysB, secrets)
+}
+
+// diffKeys compares flattened keys, masking secrets.
+func diffKeys(keysA map[string]string, keysB map[string]string, secrets *DiffSecrets) []KeyDiff {
+	paths := []string{}
+	for path := range keysA {
+		paths = append(paths, path)
+	}
+	for path := range keysB {
+		if _, ok := keysA[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	keyDiffs := []KeyDiff{}
+	for _, path := range paths {
+		valueA, inA := keysA[path]
+		valueB, inB := keysB[path]
+		switch {
+		case inA && !inB:
+			keyDiffs = append(keyDiffs, KeyDiff{Op: "removed", Path: path, From: secrets.Mask(path, valueA)})
+		case !inA && inB:
+			keyDiffs = append(keyDiffs, KeyDiff{Op: "added", Path: path, To: secrets.Mask(path, valueB)})
+		case valueA != valueB:
+			keyDiffs = append(keyDiffs, KeyDiff{Op: "changed", Path: path, From: secrets.Mask(path, valueA), To: secrets.Mask(path, valueB)})
+		}
+	}
+	return keyDiffs
+}
+
+// FormatKeyDiffs renders key differences one per line: + added, - removed, ~ changed.
+func FormatKeyDiffs(keyDiffs []KeyDiff) string {
+	if len(keyDiffs) == 0 {
+		return "@@ No Differences @@"
+	}
+	var result strings.Builder
+	for _, keyDiff := range keyDiffs {
+		switch keyDiff.Op {
+		case "added":
+			result.WriteString("+ " + keyDiff.Path + ": " + keyDiff.To + "\n")
+		case "removed":
+			result.WriteString("- " + keyDiff.Path + ": " + keyDiff.From + "\n")
+		default:
+			result.WriteString("~ " + keyDiff.Path + ": " + keyDiff.From + " -> " + keyDiff.To + "\n")
+		}
+	}
+	return strings.TrimSuffix(result.String(), "\n")
+}
+
+// flattenConfig parses a rendered config into dotted key paths and their values.
+func flattenConfig(fileName string, config string) (map[string]string, error) {
+	keys := map[string]string{}
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yml", ".yaml":
+		var parsed interface{}
+		if err := yaml.Unmarshal([]byte(config), &parsed); err != nil {
+			return nil, err
+		}
+		flattenValue("", parsed, keys)
+	case ".json":
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(config), &parsed); err != nil {
+			return nil, err
+		}
+		flattenValue("", parsed, keys)
+	case ".properties", ".ini":
+		flattenProperties(config, keys)
+	case ".xml":
+		if err := flattenXml(config, keys); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("unsupported format: " + fileName)
+	}
+	return keys, nil
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func flattenValue(path string, value interface{}, keys map[string]string) {
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		for key, entry := range typedValue {
+			flattenValue(joinPath(path, fmt.Sprint(key)), entry, keys)
+		}
+	case map[string]interface{}:
+		for key, entry := range typedValue {
+			flattenValue(joinPath(path, key), entry, keys)
+		}
+	case []interface{}:
+		for i, entry := range typedValue {
+			flattenValue(joinPath(path, strconv.Itoa(i)), entry, keys)
+		}
+	case nil:
+		keys[path] = "null"
+	default:
+		keys[path] = fmt.Sprint(typedValue)
+	}
+}
+
+// flattenProperties handles key=value, key: value and key value lines.  ini sections prefix their keys.
+func flattenProperties(config string, keys map[string]string) {
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(config))
+	logicalLine := ""
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if logicalLine == "" && (line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") || strings.HasPrefix(line, ";")) {
+			continue
+		}
+		if strings.HasSuffix(line, "\\") {
+			// Continued on the next line.
+			logicalLine += strings.TrimSuffix(line, "\\")
+			continue
+		}
+		line = logicalLine + line
+		logicalLine = ""
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		separator := strings.IndexAny(line, "=:")
+		if separator < 0 {
+			separator = strings.IndexAny(line, " \t")
+		}
+		key, value := line, ""
+		if separator >= 0 {
+			key, value = strings.TrimSpace(line[:separator]), strings.TrimSpace(line[separator+1:])
+		}
+		keys[joinPath(section, key)] = value
+	}
+	if logicalLine != "" {
+		keys[joinPath(section, logicalLine)] = ""
+	}
+}
+
+// flattenXml keys element text by element path and attributes by path.@attr.
+// Repeated sibling elements are indexed: servers.server, servers.server[1], ...
+func flattenXml(config string, keys map[string]string) error {
+	decoder := xml.NewDecoder(strings.NewReader(config))
+	paths := []string{}
+	siblings := []map[string]int{{}}
+	text := []string{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			name := element.Name.Local
+			count := siblings[len(siblings)-1][name]
+			siblings[len(siblings)-1][name] = count + 1
+			if count > 0 {
+				name = name + "[" + strconv.Itoa(count) + "]"
+			}
+			parent := ""
+			if len(paths) > 0 {
+				parent = paths[len(paths)-1]
+			}
+			path := joinPath(parent, name)
+			for _, attr := range element.Attr {
+				keys[path+".@"+attr.Name.Local] = attr.Value
+			}
+			paths = append(paths, path)
+			siblings = append(siblings, map[string]int{})
+			text = append(text, "")
+		case xml.CharData:
+			if len(text) > 0 {
+				text[len(text)-1] += string(element)
+			}
+		case xml.EndElement:
+			if len(paths) == 0 {
+				return errors.New("unbalanced xml")
+			}
+			if value := strings.TrimSpace(text[len(text)-1]); value != "" || len(siblings[len(siblings)-1]) == 0 {
+				keys[paths[len(paths)-1]] = value
+			}
+			paths = paths[:len(paths)-1]
+			siblings = siblings[:len(siblings)-1]
+			text = text[:len(text)-1]
+		}
+	}
+	if len(paths) > 0 {
+		return errors.New("unbalanced xml")
+	}
+	return nil
+}
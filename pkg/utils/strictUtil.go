@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnresolvedKeys is returned when strict rendering finds template keys with no value.
+var ErrUnresolvedKeys = errors.New("unresolved template keys")
+
+// UnresolvedKey describes a single template key that could not be resolved.
+type UnresolvedKey struct {
+	Project  string
+	Service  string
+	Template string
+	Key      string // "*" when no values exist for the entire template.
+	Env      string
+}
+
+// UnresolvedKeyReport collects unresolved keys across every template rendered in a run.
+type UnresolvedKeyReport struct {
+	mutex sync.Mutex
+	keys  []UnresolvedKey
+}
+
+// Add records an unresolved key.  Safe for concurrent use.
+func (r *UnresolvedKeyReport) Add(unresolvedKey UnresolvedKey) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	r.keys = append(r.keys, unresolvedKey)
+	r.mutex.Unlock()
+}
+
+// Len returns the number of unresolved keys recorded.
+func (r *UnresolvedKeyReport) Len() int {
+	if r == nil {
+		return 0
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.keys)
+}
+
+// Keys returns a sorted copy of the unresolved keys recorded.
+func (r *UnresolvedKeyReport) Keys() []UnresolvedKey {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	keys := append([]UnresolvedKey{}, r.keys...)
+	r.mutex.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		if a.Template != b.Template {
+			return a.Template < b.Template
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Env < b.Env
+	})
+	return keys
+}
+
+// String renders the consolidated report, one unresolved key per line.
+func (r *UnresolvedKeyReport) String() string {
+	keys := r.Keys()
+	var report strings.Builder
+	report.WriteString(fmt.Sprintf("Strict mode: %d unresolved template key(s)\n", len(keys)))
+	report.WriteString(fmt.Sprintf("%-20s %-25s %-30s %-30s %s\n", "PROJECT", "SERVICE", "TEMPLATE", "KEY", "ENV"))
+	for _, key := range keys {
+		report.WriteString(fmt.Sprintf("%-20s %-25s %-30s %-30s %s\n", key.Project, key.Service, key.Template, key.Key, key.Env))
+	}
+	return strings.TrimSuffix(report.String(), "\n")
+}
@@ -0,0 +1,37 @@
+package utils
+
+import "sync"
+
+// RenderedTemplate is an output configured from a template during a run.
+type RenderedTemplate struct {
+	Project      string
+	Service      string
+	TemplatePath string
+	EndPath      string
+}
+
+// RenderedTemplates collects every template configured in a run so watch mode knows what to poll.
+type RenderedTemplates struct {
+	mutex     sync.Mutex
+	templates []RenderedTemplate
+}
+
+// Add records a configured template.  Safe for concurrent use.
+func (r *RenderedTemplates) Add(renderedTemplate RenderedTemplate) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	r.templates = append(r.templates, renderedTemplate)
+	r.mutex.Unlock()
+}
+
+// List returns a copy of the configured templates.
+func (r *RenderedTemplates) List() []RenderedTemplate {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]RenderedTemplate{}, r.templates...)
+}
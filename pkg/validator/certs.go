@@ -1,16 +1,25 @@
 package validator
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/x509"
 	"encoding/asn1"
 	"encoding/pem"
 	"errors"
 	"io"
+	"math"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/trimble-oss/tierceron/pkg/utils"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"github.com/youmark/pkcs8"
+	"golang.org/x/crypto/ssh"
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 // Definition here: https://tools.ietf.org/html/rfc5280
@@ -135,3 +144,186 @@ func VerifyCertificate(cert *x509.Certificate, host string) (bool, error) {
 	}
 	return true, nil
 }
+
+// AuditCertificateBytes decodes a stored certificate source (PEM, DER, PKCS#12 or JKS) and
+// audits it offline as of now: subject, SANs, issuer, expiry, whether the private key matches
+// the certificate and whether the chain verifies without fetching missing issuers.
+// password decrypts protected sources.  Returns nil if the source holds no certificate.
+func AuditCertificateBytes(certBytes []byte, password string, now time.Time) (*utils.CertAudit, error) {
+	certs, privateKey, err := decodeCertificateSource(certBytes, password)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, nil
+	}
+
+	audit := &utils.CertAudit{KeyMatch: "n/a"}
+	leaf := certs[0]
+	if privateKey != nil {
+		audit.KeyMatch = "no"
+		if signer, ok := privateKey.(crypto.Signer); ok {
+			if publicKey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool }); ok {
+				for _, cert := range certs {
+					if publicKey.Equal(cert.PublicKey) {
+						leaf = cert
+						audit.KeyMatch = "yes"
+						break
+					}
+				}
+			}
+		}
+		if audit.KeyMatch == "no" {
+			audit.Problems = append(audit.Problems, "private key does not match certificate")
+		}
+	} else {
+		for _, cert := range certs {
+			if !cert.IsCA {
+				leaf = cert
+				break
+			}
+		}
+	}
+
+	audit.Subject = leaf.Subject.String()
+	audit.Issuer = leaf.Issuer.String()
+	audit.SANs = append(audit.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		audit.SANs = append(audit.SANs, ip.String())
+	}
+	audit.SANs = append(audit.SANs, leaf.EmailAddresses...)
+	for _, uri := range leaf.URIs {
+		audit.SANs = append(audit.SANs, uri.String())
+	}
+	audit.NotAfter = leaf.NotAfter
+	audit.DaysToExpiry = int(math.Floor(leaf.NotAfter.Sub(now).Hours() / 24))
+
+	chainErr := verifyChainOffline(leaf, certs, now)
+	audit.ChainComplete = chainErr == nil
+	if chainErr != nil {
+		audit.Problems = append(audit.Problems, "incomplete chain: "+chainErr.Error())
+	}
+
+	return audit, nil
+}
+
+// decodeCertificateSource extracts every certificate and the private key, if any, from a cert source.
+func decodeCertificateSource(certBytes []byte, password string) ([]*x509.Certificate, interface{}, error) {
+	certs := []*x509.Certificate{}
+	var privateKey interface{}
+
+	if block, _ := pem.Decode(certBytes); block != nil {
+		for rest := certBytes; ; {
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			switch block.Type {
+			case certificateType:
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, nil, errors.New("failed to parse certificate: " + err.Error())
+				}
+				certs = append(certs, cert)
+			case encryptedPrivateKeyType:
+				key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(password))
+				if err != nil {
+					return nil, nil, errors.New("failed to decrypt private key: " + err.Error())
+				}
+				privateKey = key
+			default:
+				if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+					key, err := ssh.ParseRawPrivateKey(pem.EncodeToMemory(block))
+					if err != nil {
+						return nil, nil, errors.New("failed to parse private key: " + err.Error())
+					}
+					privateKey = key
+				}
+			}
+		}
+		return certs, privateKey, nil
+	}
+
+	if bytes.HasPrefix(certBytes, []byte{0xFE, 0xED, 0xFE, 0xED}) {
+		ks := keystore.New()
+		if err := ks.Load(bytes.NewReader(certBytes), []byte(password)); err != nil {
+			return nil, nil, errors.New("failed to load keystore: " + err.Error())
+		}
+		for _, alias := range ks.Aliases() {
+			var chain []keystore.Certificate
+			if ks.IsPrivateKeyEntry(alias) {
+				entry, err := ks.GetPrivateKeyEntry(alias, []byte(password))
+				if err != nil {
+					return nil, nil, errors.New("failed to read keystore entry " + alias + ": " + err.Error())
+				}
+				if privateKey, err = x509.ParsePKCS8PrivateKey(entry.PrivateKey); err != nil {
+					return nil, nil, errors.New("failed to parse private key: " + err.Error())
+				}
+				chain = entry.CertificateChain
+			} else if entry, err := ks.GetTrustedCertificateEntry(alias); err == nil {
+				chain = []keystore.Certificate{entry.Certificate}
+			}
+			for _, chainCert := range chain {
+				cert, err := x509.ParseCertificate(chainCert.Content)
+				if err != nil {
+					return nil, nil, errors.New("failed to parse certificate: " + err.Error())
+				}
+				certs = append(certs, cert)
+			}
+		}
+		return certs, privateKey, nil
+	}
+
+	if isPfx, _ := IsPfxRfc7292(certBytes); isPfx {
+		key, cert, caCerts, err := pkcs12.DecodeChain(certBytes, password)
+		if err != nil {
+			trustedCerts, trustErr := pkcs12.DecodeTrustStore(certBytes, password)
+			if trustErr != nil {
+				return nil, nil, errors.New("failed to decode pfx: " + err.Error())
+			}
+			return trustedCerts, nil, nil
+		}
+		return append([]*x509.Certificate{cert}, caCerts...), key, nil
+	}
+
+	certs, err := x509.ParseCertificates(certBytes)
+	if err != nil {
+		return nil, nil, errors.New("failed to parse certificate: " + err.Error())
+	}
+	return certs, nil, nil
+}
+
+// verifyChainOffline verifies leaf against the system roots plus any certificates bundled with it.
+// Missing issuers are not fetched.  Expiry is reported separately, so an expired leaf is verified
+// as of its last valid moment.
+func verifyChainOffline(leaf *x509.Certificate, certs []*x509.Certificate, now time.Time) error {
+	roots, err := x509.SystemCertPool()
+	if err != nil || roots == nil {
+		roots = x509.NewCertPool()
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs {
+		if cert == leaf {
+			continue
+		}
+		if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+			roots.AddCert(cert)
+		} else {
+			intermediates.AddCert(cert)
+		}
+	}
+	if bytes.Equal(leaf.RawIssuer, leaf.RawSubject) {
+		// Self signed.
+		roots.AddCert(leaf)
+	}
+	if now.After(leaf.NotAfter) {
+		now = leaf.NotAfter.Add(-time.Second)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
@@ -0,0 +1,314 @@
+package validator
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// testChain is a root, an intermediate and a leaf issued by the intermediate.
+type testChain struct {
+	root, intermediate, leaf *x509.Certificate
+	leafKey                  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func newTestChain(t *testing.T, now time.Time, leafNotAfter time.Time) testChain {
+	t.Helper()
+	ca := func(serial int64, name string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.AddDate(10, 0, 0),
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+	}
+	root, rootKey := newTestCert(t, ca(1, "Test Root"), nil, nil)
+	intermediate, intermediateKey := newTestCert(t, ca(2, "Test Intermediate"), root, rootKey)
+	leaf, leafKey := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "service.example.com"},
+		DNSNames:     []string{"service.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     leafNotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, intermediate, intermediateKey)
+	return testChain{root: root, intermediate: intermediate, leaf: leaf, leafKey: leafKey}
+}
+
+func pemCerts(certs ...*x509.Certificate) []byte {
+	var out bytes.Buffer
+	for _, cert := range certs {
+		pem.Encode(&out, &pem.Block{Type: certificateType, Bytes: cert.Raw})
+	}
+	return out.Bytes()
+}
+
+func pemKey(t *testing.T, key *ecdsa.PrivateKey, password string) []byte {
+	t.Helper()
+	if password == "" {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+	der, err := pkcs8.MarshalPrivateKey(key, []byte(password), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: encryptedPrivateKeyType, Bytes: der})
+}
+
+func jksBytes(t *testing.T, chain testChain, password string) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(chain.leafKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := keystore.New()
+	err = ks.SetPrivateKeyEntry("service", keystore.PrivateKeyEntry{
+		CreationTime: time.Now(),
+		PrivateKey:   der,
+		CertificateChain: []keystore.Certificate{
+			{Type: "X509", Content: chain.leaf.Raw},
+			{Type: "X509", Content: chain.intermediate.Raw},
+			{Type: "X509", Content: chain.root.Raw},
+		},
+	}, []byte(password))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := ks.Store(&out, []byte(password)); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestAuditCertificateBytes(t *testing.T) {
+	now := time.Now()
+	chain := newTestChain(t, now, now.AddDate(0, 0, 90))
+	expiring := newTestChain(t, now, now.AddDate(0, 0, 10).Add(time.Hour))
+	expired := newTestChain(t, now.AddDate(0, 0, -30), now.AddDate(0, 0, -2))
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pfx, err := pkcs12.Modern.Encode(chain.leafKey, chain.leaf, []*x509.Certificate{chain.intermediate, chain.root}, "changeit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		source        []byte
+		password      string
+		certs         int
+		keyMatch      string
+		chainComplete bool
+		daysToExpiry  int
+		problem       string
+	}{
+		{
+			name:          "pem with key",
+			source:        append(pemCerts(chain.leaf, chain.intermediate, chain.root), pemKey(t, chain.leafKey, "")...),
+			certs:         3,
+			keyMatch:      "yes",
+			chainComplete: true,
+			daysToExpiry:  89,
+		},
+		{
+			name:          "pem with encrypted key",
+			source:        append(pemKey(t, chain.leafKey, "changeit"), pemCerts(chain.root, chain.intermediate, chain.leaf)...),
+			password:      "changeit",
+			certs:         3,
+			keyMatch:      "yes",
+			chainComplete: true,
+			daysToExpiry:  89,
+		},
+		{
+			name:          "pem without key",
+			source:        pemCerts(chain.root, chain.intermediate, chain.leaf),
+			certs:         3,
+			keyMatch:      "n/a",
+			chainComplete: true,
+			daysToExpiry:  89,
+		},
+		{
+			name:          "pkcs12",
+			source:        pfx,
+			password:      "changeit",
+			certs:         3,
+			keyMatch:      "yes",
+			chainComplete: true,
+			daysToExpiry:  89,
+		},
+		{
+			name:          "jks",
+			source:        jksBytes(t, chain, "changeit"),
+			password:      "changeit",
+			certs:         3,
+			keyMatch:      "yes",
+			chainComplete: true,
+			daysToExpiry:  89,
+		},
+		{
+			name:         "missing intermediate",
+			source:       append(pemCerts(chain.leaf, chain.root), pemKey(t, chain.leafKey, "")...),
+			certs:        2,
+			keyMatch:     "yes",
+			daysToExpiry: 89,
+			problem:      "incomplete chain",
+		},
+		{
+			name:          "mismatched key",
+			source:        append(pemCerts(chain.leaf, chain.intermediate, chain.root), pemKey(t, otherKey, "")...),
+			certs:         3,
+			keyMatch:      "no",
+			chainComplete: true,
+			daysToExpiry:  89,
+			problem:       "private key does not match certificate",
+		},
+		{
+			name:          "expiring",
+			source:        pemCerts(expiring.leaf, expiring.intermediate, expiring.root),
+			certs:         3,
+			keyMatch:      "n/a",
+			chainComplete: true,
+			daysToExpiry:  10,
+		},
+		{
+			name:          "expired",
+			source:        pemCerts(expired.leaf, expired.intermediate, expired.root),
+			certs:         3,
+			keyMatch:      "n/a",
+			chainComplete: true,
+			daysToExpiry:  -3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			certs, _, err := decodeCertificateSource(test.source, test.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(certs) != test.certs {
+				t.Fatalf("Expected %d certificates, got %d", test.certs, len(certs))
+			}
+
+			audit, err := AuditCertificateBytes(test.source, test.password, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if audit.Subject != "CN=service.example.com" || audit.Issuer != "CN=Test Intermediate" {
+				t.Fatalf("Expected the leaf audited, got %s issued by %s", audit.Subject, audit.Issuer)
+			}
+			if len(audit.SANs) != 1 || audit.SANs[0] != "service.example.com" {
+				t.Fatalf("Unexpected sans %v", audit.SANs)
+			}
+			if audit.KeyMatch != test.keyMatch {
+				t.Fatalf("Expected key match %s, got %s", test.keyMatch, audit.KeyMatch)
+			}
+			if audit.ChainComplete != test.chainComplete {
+				t.Fatalf("Expected chain complete %t, got %t: %v", test.chainComplete, audit.ChainComplete, audit.Problems)
+			}
+			if audit.DaysToExpiry != test.daysToExpiry {
+				t.Fatalf("Expected %d days to expiry, got %d", test.daysToExpiry, audit.DaysToExpiry)
+			}
+			if test.problem == "" && len(audit.Problems) > 0 {
+				t.Fatalf("Unexpected problems %v", audit.Problems)
+			}
+			if test.problem != "" && (len(audit.Problems) != 1 || !strings.HasPrefix(audit.Problems[0], test.problem)) {
+				t.Fatalf("Expected problem %s, got %v", test.problem, audit.Problems)
+			}
+		})
+	}
+}
+
+func TestAuditCertificateBytesErrors(t *testing.T) {
+	now := time.Now()
+	chain := newTestChain(t, now, now.AddDate(0, 0, 90))
+	pfx, err := pkcs12.Modern.Encode(chain.leafKey, chain.leaf, nil, "changeit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AuditCertificateBytes(pfx, "wrong", now); err == nil {
+		t.Fatal("Expected a pkcs12 with the wrong password to fail")
+	}
+	if _, err := AuditCertificateBytes(jksBytes(t, chain, "changeit"), "wrong!", now); err == nil {
+		t.Fatal("Expected a jks with the wrong password to fail")
+	}
+	if _, err := AuditCertificateBytes(pemKey(t, chain.leafKey, "changeit"), "wrong", now); err == nil {
+		t.Fatal("Expected an encrypted key with the wrong password to fail")
+	}
+	if audit, err := AuditCertificateBytes(pemKey(t, chain.leafKey, ""), "", now); err != nil || audit != nil {
+		t.Fatalf("Expected no audit for a source without certificates, got %v %v", audit, err)
+	}
+}
+
+func TestVerifyChainOffline(t *testing.T) {
+	now := time.Now()
+	chain := newTestChain(t, now, now.AddDate(0, 0, 90))
+	expired := newTestChain(t, now.AddDate(0, 0, -30), now.AddDate(0, 0, -2))
+
+	tests := []struct {
+		name     string
+		leaf     *x509.Certificate
+		certs    []*x509.Certificate
+		complete bool
+	}{
+		{name: "complete", leaf: chain.leaf, certs: []*x509.Certificate{chain.leaf, chain.intermediate, chain.root}, complete: true},
+		{name: "missing intermediate", leaf: chain.leaf, certs: []*x509.Certificate{chain.leaf, chain.root}},
+		{name: "missing root", leaf: chain.leaf, certs: []*x509.Certificate{chain.leaf, chain.intermediate}},
+		{name: "self signed", leaf: chain.root, certs: []*x509.Certificate{chain.root}, complete: true},
+		{name: "expired leaf", leaf: expired.leaf, certs: []*x509.Certificate{expired.leaf, expired.intermediate, expired.root}, complete: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifyChainOffline(test.leaf, test.certs, now)
+			if test.complete && err != nil {
+				t.Fatalf("Expected the chain to verify, got %v", err)
+			}
+			if !test.complete && err == nil {
+				t.Fatal("Expected the chain not to verify")
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package validator
 import (
 	"bufio"
 	"bytes"
+	"crypto"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/asn1"
@@ -11,6 +12,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/trimble-oss/tierceron/pkg/core"
@@ -21,23 +23,39 @@ import (
 	"github.com/youmark/pkcs8"
 	pkcs "golang.org/x/crypto/pkcs12"
 	"golang.org/x/crypto/ssh"
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 // Copied from pkcs12.go... why can't they just make these public.  Gr...
 // PEM block types
 const (
-	certificateType   = "CERTIFICATE"
-	privateKeyType    = "PRIVATE KEY"
-	rsaPrivateKeyType = "RSA PRIVATE KEY"
+	certificateType         = "CERTIFICATE"
+	privateKeyType          = "PRIVATE KEY"
+	rsaPrivateKeyType       = "RSA PRIVATE KEY"
+	encryptedPrivateKeyType = "ENCRYPTED PRIVATE KEY"
 )
 
+var keystoreLock sync.Mutex
+
+// IsPkcs12Keystore - true if the keystore file requested should be written as PKCS#12 rather than JKS.
+func IsPkcs12Keystore(keystoreFile string) bool {
+	return strings.HasSuffix(keystoreFile, ".p12") || strings.HasSuffix(keystoreFile, ".pfx")
+}
+
+// StoreKeystore serializes the keystore protected by trustStorePassword, as PKCS#12
+// when a .p12 or .pfx keystore was requested and JKS otherwise.
 func StoreKeystore(driverConfig *eUtils.DriverConfig, trustStorePassword string) ([]byte, error) {
+	keystoreLock.Lock()
+	defer keystoreLock.Unlock()
 	buffer := &bytes.Buffer{}
 	keystoreWriter := bufio.NewWriter(buffer)
 
 	if driverConfig.KeyStore == nil {
 		return nil, errors.New("cert bundle not properly named")
 	}
+	if IsPkcs12Keystore(driverConfig.WantKeystore) {
+		return storePkcs12(driverConfig, trustStorePassword)
+	}
 	storeErr := driverConfig.KeyStore.Store(keystoreWriter, []byte(trustStorePassword))
 	if storeErr != nil {
 		return nil, storeErr
@@ -47,10 +65,86 @@ func StoreKeystore(driverConfig *eUtils.DriverConfig, trustStorePassword string)
 	return buffer.Bytes(), nil
 }
 
+// storePkcs12 converts the keystore entries to PKCS#12.  PKCS#12 holds a single private key
+// with its chain, so the key entry is protected by the store password.
+func storePkcs12(driverConfig *eUtils.DriverConfig, trustStorePassword string) ([]byte, error) {
+	var privateKey interface{}
+	var leaf *x509.Certificate
+	caCerts := []*x509.Certificate{}
+
+	for _, alias := range driverConfig.KeyStore.Aliases() {
+		if driverConfig.KeyStore.IsPrivateKeyEntry(alias) {
+			if privateKey != nil {
+				return nil, errors.New("pkcs12 keystores hold a single private key: " + alias)
+			}
+			entry, err := driverConfig.KeyStore.GetPrivateKeyEntry(alias, driverConfig.KeystoreAliasPasswords[alias])
+			if err != nil {
+				return nil, err
+			}
+			privateKey, err = x509.ParsePKCS8PrivateKey(entry.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			for i, chainCert := range entry.CertificateChain {
+				cert, err := x509.ParseCertificate(chainCert.Content)
+				if err != nil {
+					return nil, err
+				}
+				if i == 0 {
+					leaf = cert
+				} else {
+					caCerts = append(caCerts, cert)
+				}
+			}
+		} else if driverConfig.KeyStore.IsTrustedCertificateEntry(alias) {
+			entry, err := driverConfig.KeyStore.GetTrustedCertificateEntry(alias)
+			if err != nil {
+				return nil, err
+			}
+			cert, err := x509.ParseCertificate(entry.Certificate.Content)
+			if err != nil {
+				return nil, err
+			}
+			caCerts = append(caCerts, cert)
+		}
+	}
+
+	if privateKey == nil {
+		return pkcs12.Modern.EncodeTrustStore(caCerts, trustStorePassword)
+	}
+	if leaf == nil {
+		// The key was added without its chain, so its certificate is among the trusted ones.
+		for i, caCert := range caCerts {
+			if matchesPrivateKey(caCert, privateKey) {
+				leaf = caCert
+				caCerts = append(caCerts[:i:i], caCerts[i+1:]...)
+				break
+			}
+		}
+		if leaf == nil {
+			return nil, errors.New("no certificate found for private key")
+		}
+	}
+	return pkcs12.Modern.Encode(privateKey, leaf, caCerts, trustStorePassword)
+}
+
+// matchesPrivateKey - true if cert carries the public half of privateKey.
+func matchesPrivateKey(cert *x509.Certificate, privateKey interface{}) bool {
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return false
+	}
+	publicKey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && publicKey.Equal(cert.PublicKey)
+}
+
+// AddToKeystore adds a certificate or key to the keystore.  password decrypts a protected
+// PKCS#12 or PKCS#8 source and protects the alias's private key entry.
 func AddToKeystore(driverConfig *eUtils.DriverConfig, alias string, password []byte, certBundleJks string, data []byte) error {
-	// TODO: Add support for this format?  golang.org/x/crypto/pkcs12
+	keystoreLock.Lock()
+	defer keystoreLock.Unlock()
 
-	if !strings.HasSuffix(driverConfig.WantKeystore, ".jks") && strings.HasSuffix(certBundleJks, ".jks") {
+	if !strings.HasSuffix(driverConfig.WantKeystore, ".jks") && !IsPkcs12Keystore(driverConfig.WantKeystore) && (strings.HasSuffix(certBundleJks, ".jks") || IsPkcs12Keystore(certBundleJks)) {
 		driverConfig.WantKeystore = certBundleJks
 	}
 
@@ -59,29 +153,39 @@ func AddToKeystore(driverConfig *eUtils.DriverConfig, alias string, password []b
 		ks := keystore.New()
 		driverConfig.KeyStore = &ks
 	}
+	if driverConfig.KeystoreAliasPasswords == nil {
+		driverConfig.KeystoreAliasPasswords = map[string][]byte{}
+	}
 
 	block, _ := pem.Decode(data)
 	if block == nil {
-		key, cert, err := pkcs.Decode(data, string(password)) // Note the order of the return values.
+		key, cert, caCerts, err := pkcs12.DecodeChain(data, string(password))
 		if err != nil {
 			return err
 		}
-		pkcs8Key, err := pkcs8.ConvertPrivateKeyToPKCS8(key, password)
+		pkcs8Key, err := pkcs8.MarshalPrivateKey(key, nil, nil)
 		if err != nil {
 			return err
 		}
-
-		driverConfig.KeyStore.SetPrivateKeyEntry(alias, keystore.PrivateKeyEntry{
-			CreationTime: time.Now(),
-			PrivateKey:   pkcs8Key,
-			CertificateChain: []keystore.Certificate{
-				{
-					Type:    "X509",
-					Content: cert.Raw,
-				},
+		certificateChain := []keystore.Certificate{
+			{
+				Type:    "X509",
+				Content: cert.Raw,
 			},
-		}, password)
+		}
+		for _, caCert := range caCerts {
+			certificateChain = append(certificateChain, keystore.Certificate{Type: "X509", Content: caCert.Raw})
+		}
 
+		err = driverConfig.KeyStore.SetPrivateKeyEntry(alias, keystore.PrivateKeyEntry{
+			CreationTime:     time.Now(),
+			PrivateKey:       pkcs8Key,
+			CertificateChain: certificateChain,
+		}, password)
+		if err != nil {
+			return err
+		}
+		driverConfig.KeystoreAliasPasswords[alias] = password
 	} else {
 		if block.Type == certificateType {
 			aliasCommon := strings.Replace(alias, "cert.pem", "", 1)
@@ -94,18 +198,35 @@ func AddToKeystore(driverConfig *eUtils.DriverConfig, alias string, password []b
 			})
 			return nil
 		}
-		privateKeyBytes, err := ssh.ParseRawPrivateKey(data)
+		var privateKey interface{}
+		var err error
+		switch {
+		case block.Type == encryptedPrivateKeyType:
+			privateKey, err = pkcs8.ParsePKCS8PrivateKey(block.Bytes, password)
+		case len(password) > 0:
+			privateKey, err = ssh.ParseRawPrivateKeyWithPassphrase(data, password)
+			if err != nil {
+				// Not every source is protected.
+				privateKey, err = ssh.ParseRawPrivateKey(data)
+			}
+		default:
+			privateKey, err = ssh.ParseRawPrivateKey(data)
+		}
 		if err == nil {
-			privateKeyBytes, err := pkcs8.MarshalPrivateKey(privateKeyBytes, []byte{}, nil)
+			privateKeyBytes, err := pkcs8.MarshalPrivateKey(privateKey, []byte{}, nil)
 			if err != nil {
 				return err
 			}
 			aliasCommon := strings.Replace(alias, "key.pem", "", 1)
 
-			driverConfig.KeyStore.SetPrivateKeyEntry(aliasCommon, keystore.PrivateKeyEntry{
+			err = driverConfig.KeyStore.SetPrivateKeyEntry(aliasCommon, keystore.PrivateKeyEntry{
 				CreationTime: time.Now(),
 				PrivateKey:   privateKeyBytes,
 			}, password)
+			if err != nil {
+				return err
+			}
+			driverConfig.KeystoreAliasPasswords[aliasCommon] = password
 		} else {
 			return err
 		}
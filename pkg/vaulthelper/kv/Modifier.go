@@ -6,10 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
-	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -34,26 +33,30 @@ var noEnvironments = map[string]bool{
 // can be changed to alter where in the vault the key,value
 // pair is stored
 type Modifier struct {
-	Insecure         bool         // Indicates if connections to vault should be secure
-	Direct           bool         // Bypass vault and utilize alternative source when possible.
-	httpClient       *http.Client // Handle to http client.
-	client           *api.Client  // Client connected to vault
-	logical          *api.Logical // Logical used for read/write options
-	SecretDictionary *api.Secret  // Current Secret Dictionary Cache -- populated by mod.List("templates"
+	Insecure         bool        // Indicates if connections to vault should be secure
+	Direct           bool        // Bypass vault and utilize alternative source when possible.
+	storage          Storage     // Where secrets are kept: vault, memory or a local file.
+	address          string      // Address of the storage, qualified by vault namespace.
+	tokenGeneration  uint64      // tokenGeneration the token was last brought up to date at.
+	checkedOut       bool        // Counted in checkedOutGenerations until released or closed.
+	SecretDictionary *api.Secret // Current Secret Dictionary Cache -- populated by mod.List("templates"
 
 	Env             string // Environment (local/dev/QA; Initialized to secrets)
 	RawEnv          string
-	Regions         []string // Supported regions
-	Version         string   // Version for data
-	VersionFilter   []string // Used to filter vault paths
-	TemplatePath    string   // Path to template we are processing.
-	ProjectIndex    []string // Which projects are indexed.
-	SectionKey      string   // The section key: Index or Restricted.
-	SectionName     string   // The name of the actual section.
-	SubSectionName  string   // The name of the actual subsection.
-	SubSectionValue string   // The actual value for the sub section.
-	SectionPath     string   // The path to the Index (both seed and vault)
-	Stale           bool     // If client is no longer usable, this will be true..
+	Regions         []string  // Supported regions
+	Version         string    // Version for data
+	AsOf            time.Time // When set, read the version of each path that was current at this instant.
+	VersionFilter   []string  // Used to filter vault paths
+	TemplatePath    string    // Path to template we are processing.
+	ProjectIndex    []string  // Which projects are indexed.
+	SectionKey      string    // The section key: Index or Restricted.
+	SectionName     string    // The name of the actual section.
+	SubSectionName  string    // The name of the actual subsection.
+	SubSectionValue string    // The actual value for the sub section.
+	SectionPath     string    // The path to the Index (both seed and vault)
+	Stale           bool      // If client is no longer usable, this will be true..
+
+	RetryPolicy *RetryPolicy // Retries for requests.  nil uses DefaultRetryPolicy.
 }
 
 type modCache struct {
@@ -64,6 +67,20 @@ type modCache struct {
 var modifierCache map[string]*modCache = map[string]*modCache{}
 var modifierCachLock sync.Mutex
 
+// tokenSwap is the token that replaced another on modifiers connected to address.
+type tokenSwap struct {
+	address    string
+	token      string    // Latest token of the lineage.
+	generation uint64    // tokenGeneration of the swap.  Modifiers brought up to date since don't need it.
+	expires    time.Time // When the replaced token expires.  Zero when unknown.
+}
+
+// Guarded by tokenSwapLock, taken after modifierCachLock when both are needed.
+var tokenSwapLock sync.Mutex
+var tokenGeneration uint64                   // Incremented by each SwapCachedToken.
+var tokenSwaps = map[string]tokenSwap{}      // Replaced token -> its replacement.
+var checkedOutGenerations = map[uint64]int{} // Modifiers out of the cache by their tokenGeneration.
+
 // PreCheckEnvironment
 // Returns: env, parts, true if parts is path, false if part of file name, error
 func PreCheckEnvironment(environment string) (string, string, bool, error) {
@@ -96,13 +113,14 @@ func PreCheckEnvironment(environment string) (string, string, bool, error) {
 //	Any errors generated in creating the client
 func NewModifier(insecure bool, token string, address string, env string, regions []string, useCache bool, logger *log.Logger) (*Modifier, error) {
 	if useCache {
-		PruneCache(env, address, 10)
-		checkoutModifier, err := cachedModifierHelper(env, address)
+		PruneCache(env, cacheAddress(address), 10)
+		checkoutModifier, err := cachedModifierHelper(env, cacheAddress(address))
 		if err == nil && checkoutModifier != nil {
 			checkoutModifier.Insecure = insecure
 			checkoutModifier.RawEnv = env
 			checkoutModifier.Regions = regions
 			checkoutModifier.Version = ""               // Version for data
+			checkoutModifier.AsOf = time.Time{}         // Point in time for data
 			checkoutModifier.VersionFilter = []string{} // Used to filter vault paths
 			checkoutModifier.TemplatePath = ""          // Path to template we are processing.
 			checkoutModifier.ProjectIndex = []string{}  // Which projects are indexed.
@@ -116,18 +134,35 @@ func NewModifier(insecure bool, token string, address string, env string, region
 		}
 	}
 
+	if IsLocalStorage(address) {
+		storage, err := localStorage(address)
+		if err != nil {
+			return nil, err
+		}
+		modifier := NewModifierWithStorage(storage, env, regions)
+		modifier.address = address
+		modifier.Insecure = insecure
+		return modifier, nil
+	}
+
 	if len(address) == 0 {
 		address = "http://127.0.0.1:8020" // Default address
 	}
+	nodes := ""
+	if IsMultiAddress(address) {
+		nodes = address
+		leader, err := ResolveAddress(insecure, nodes, env, logger)
+		if err != nil {
+			return nil, err
+		}
+		address = leader
+	}
 	httpClient, err := CreateHTTPClient(insecure, address, env, false)
 	if err != nil {
 		return nil, err
 	}
 	// Create client
-	modClient, err := api.NewClient(&api.Config{
-		Address:    address,
-		HttpClient: httpClient,
-	})
+	modClient, err := NewVaultClient(address, httpClient)
 	if err != nil {
 		if logger != nil {
 			logger.Printf("vaultHost: %s\n", modClient.Address())
@@ -139,10 +174,34 @@ func NewModifier(insecure bool, token string, address string, env string, region
 	modClient.SetToken(token)
 
 	// Return the modifier
-	newModifier := &Modifier{httpClient: httpClient, client: modClient, logical: modClient.Logical(), Env: "secret", RawEnv: env, Regions: regions, Version: "", Insecure: insecure}
+	storage := &vaultStorage{httpClient: httpClient, client: modClient, nodes: nodes, insecure: insecure, env: env, logger: logger}
+	modAddress := modClient.Address()
+	if nodes != "" {
+		// Cached by the list of nodes it was asked for.
+		modAddress = nodes
+	}
+	newModifier := &Modifier{storage: storage, address: cacheAddress(modAddress), Env: "secret", RawEnv: env, Regions: regions, Version: "", Insecure: insecure}
+	tokenSwapLock.Lock()
+	newModifier.tokenGeneration = tokenGeneration
+	newModifier.checkOutLocked()
+	tokenSwapLock.Unlock()
 	return newModifier, nil
 }
 
+// NewModifierWithStorage constructs a modifier keeping secrets in storage, such as NewMemoryStorage
+// for tests.  NewModifier does the same for mem:// and file:// addresses.
+func NewModifierWithStorage(storage Storage, env string, regions []string) *Modifier {
+	return &Modifier{storage: storage, Env: "secret", RawEnv: env, Regions: regions, Version: ""}
+}
+
+// vault - the vault client when secrets are kept in vault.
+func (m *Modifier) vault() (*api.Client, bool) {
+	if vs, ok := m.storage.(*vaultStorage); ok {
+		return vs.vaultClient(), true
+	}
+	return nil, false
+}
+
 func checkInitModCache(env string, addr string) {
 	if _, ok := modifierCache[fmt.Sprintf("%s+%s", env, addr)]; !ok {
 		modifierCachLock.Lock()
@@ -158,6 +217,10 @@ func cachedModifierHelper(env string, addr string) (*Modifier, error) {
 		select {
 		case checkoutModifier := <-modifierCache[fmt.Sprintf("%s+%s", env, addr)].modifierChan:
 			atomic.AddUint64(&modifierCache[fmt.Sprintf("%s+%s", env, addr)].modCount, ^uint64(0))
+			tokenSwapLock.Lock()
+			checkoutModifier.refreshTokenLocked()
+			checkoutModifier.checkOutLocked()
+			tokenSwapLock.Unlock()
 			return checkoutModifier, nil
 		case <-time.After(time.Millisecond * 200):
 			// Nothing here...
@@ -169,9 +232,13 @@ func cachedModifierHelper(env string, addr string) (*Modifier, error) {
 // Release - releases the modifier back to the cache.
 func (m *Modifier) Release() {
 	if m.Stale {
-		m.httpClient.CloseIdleConnections()
+		m.Close()
 		return
 	}
+	tokenSwapLock.Lock()
+	m.refreshTokenLocked()
+	m.checkInLocked()
+	tokenSwapLock.Unlock()
 	if _, ok := modifierCache[m.Env]; ok {
 		m.releaseHelper(m.Env)
 	} else {
@@ -180,16 +247,132 @@ func (m *Modifier) Release() {
 }
 
 func (m *Modifier) releaseHelper(env string) {
-	checkInitModCache(env, m.client.Address())
+	checkInitModCache(env, m.address)
 
 	// Since modifiers are re-used now, this may not be necessary or even desired for that
 	// matter.
-	if modifierCache[fmt.Sprintf("%s+%s", env, m.client.Address())].modCount > 10 {
+	if modifierCache[fmt.Sprintf("%s+%s", env, m.address)].modCount > 10 {
 		m.CleanCache(10)
 	}
 
-	atomic.AddUint64(&modifierCache[fmt.Sprintf("%s+%s", env, m.client.Address())].modCount, 1)
-	modifierCache[fmt.Sprintf("%s+%s", env, m.client.Address())].modifierChan <- m
+	atomic.AddUint64(&modifierCache[fmt.Sprintf("%s+%s", env, m.address)].modCount, 1)
+	modifierCache[fmt.Sprintf("%s+%s", env, m.address)].modifierChan <- m
+}
+
+// SetToken replaces the token the modifier connects to vault with.
+func (m *Modifier) SetToken(token string) {
+	if client, ok := m.vault(); ok {
+		client.SetToken(token)
+	}
+}
+
+// SwapCachedToken replaces oldToken with token on every cached modifier connected to address, or
+// to one of its nodes when several are listed.  Modifiers checked out at the time are brought up
+// to date when they are released or next checked out.  expires is when oldToken expires, after
+// which the swap is forgotten; zero keeps it until no modifier can still hold oldToken.
+func SwapCachedToken(address string, oldToken string, token string, expires time.Time) {
+	modifierCachLock.Lock()
+	defer modifierCachLock.Unlock()
+	tokenSwapLock.Lock()
+	defer tokenSwapLock.Unlock()
+	tokenGeneration++
+	// Only the latest token of each lineage is kept.
+	for replaced, swap := range tokenSwaps {
+		if swap.token == oldToken && swap.address == address {
+			swap.token = token
+			tokenSwaps[replaced] = swap
+		}
+	}
+	tokenSwaps[oldToken] = tokenSwap{address: address, token: token, generation: tokenGeneration, expires: expires}
+	for _, cache := range modifierCache {
+		cached := []*Modifier{}
+	drained:
+		for {
+			select {
+			case mod := <-cache.modifierChan:
+				cached = append(cached, mod)
+			default:
+				break drained
+			}
+		}
+		for _, mod := range cached {
+			mod.refreshTokenLocked()
+			cache.modifierChan <- mod
+		}
+	}
+	pruneTokenSwapsLocked()
+}
+
+// refreshToken replaces the modifier's token if it has been swapped since the modifier was last
+// brought up to date.
+func (m *Modifier) refreshToken() {
+	tokenSwapLock.Lock()
+	defer tokenSwapLock.Unlock()
+	m.refreshTokenLocked()
+}
+
+// refreshTokenLocked - refreshToken, called with tokenSwapLock held.
+func (m *Modifier) refreshTokenLocked() {
+	if m.tokenGeneration == tokenGeneration {
+		return
+	}
+	if m.checkedOut {
+		countCheckedOut(m.tokenGeneration, -1)
+		countCheckedOut(tokenGeneration, 1)
+	}
+	m.tokenGeneration = tokenGeneration
+	client, ok := m.vault()
+	if !ok {
+		return
+	}
+	if swap, swapped := tokenSwaps[client.Token()]; swapped && containsNode(swap.address, client.Address()) {
+		client.SetToken(swap.token)
+	}
+}
+
+// checkOutLocked counts the modifier as out of the cache, called with tokenSwapLock held.
+func (m *Modifier) checkOutLocked() {
+	if m.checkedOut {
+		return
+	}
+	m.checkedOut = true
+	countCheckedOut(m.tokenGeneration, 1)
+}
+
+// checkInLocked stops counting the modifier as out of the cache, forgetting swaps no modifier
+// still needs.  Called with tokenSwapLock held.
+func (m *Modifier) checkInLocked() {
+	if !m.checkedOut {
+		return
+	}
+	m.checkedOut = false
+	countCheckedOut(m.tokenGeneration, -1)
+	pruneTokenSwapsLocked()
+}
+
+// countCheckedOut adds delta to the modifiers out of the cache at generation.  Called with
+// tokenSwapLock held.
+func countCheckedOut(generation uint64, delta int) {
+	if checkedOutGenerations[generation] += delta; checkedOutGenerations[generation] <= 0 {
+		delete(checkedOutGenerations, generation)
+	}
+}
+
+// pruneTokenSwapsLocked forgets swaps every modifier has been brought up to date past, and swaps
+// of expired tokens.  Called with tokenSwapLock held.
+func pruneTokenSwapsLocked() {
+	oldest := tokenGeneration
+	for generation := range checkedOutGenerations {
+		if generation < oldest {
+			oldest = generation
+		}
+	}
+	now := time.Now()
+	for replaced, swap := range tokenSwaps {
+		if swap.generation <= oldest || (!swap.expires.IsZero() && now.After(swap.expires)) {
+			delete(tokenSwaps, replaced)
+		}
+	}
 }
 
 func (m *Modifier) RemoveFromCache() {
@@ -226,9 +409,9 @@ func PruneCache(env string, addr string, limit uint64) {
 func (m *Modifier) CleanCache(limit uint64) {
 	m.Close()
 	if _, ok := modifierCache[m.Env]; ok {
-		cleanCacheHelper(m.Env, m.client.Address(), limit)
+		cleanCacheHelper(m.Env, m.address, limit)
 	} else {
-		cleanCacheHelper(m.RawEnv, m.client.Address(), limit)
+		cleanCacheHelper(m.RawEnv, m.address, limit)
 	}
 }
 
@@ -254,7 +437,12 @@ func (m *Modifier) ValidateEnvironment(environment string, init bool, policySuff
 		desiredPolicy = "vault_pub_" + strings.ToLower(environment)
 	}
 
-	secret, err := m.client.Auth().Token().LookupSelf()
+	client, ok := m.vault()
+	if !ok {
+		// Memory and file storage have no policies.
+		return true, desiredPolicy, nil
+	}
+	secret, err := client.Auth().Token().LookupSelf()
 
 	if err != nil {
 		logger.Printf("LookupSelf Auth failure: %v\n", err)
@@ -297,9 +485,18 @@ func (m *Modifier) ValidateEnvironment(environment string, init bool, policySuff
 //
 //	errors generated by writing
 func (m *Modifier) Write(path string, data map[string]interface{}, logger *log.Logger) ([]string, error) {
+	return m.WriteWithContext(context.Background(), path, data, logger)
+}
+
+// WriteWithContext writes as Write does, giving up when ctx is done.
+func (m *Modifier) WriteWithContext(ctx context.Context, path string, data map[string]interface{}, logger *log.Logger) ([]string, error) {
 	// Wrap data and send
 	sendData := map[string]interface{}{"data": data}
+	return m.write(ctx, m.writePath(path), sendData, logger)
+}
 
+// writePath - the full data path Write writes path to.
+func (m *Modifier) writePath(path string) string {
 	// Create full path
 	pathBlocks := strings.SplitAfterN(path, "/", 2)
 	if len(pathBlocks) == 1 {
@@ -326,20 +523,14 @@ func (m *Modifier) Write(path string, data map[string]interface{}, logger *log.L
 	if strings.Contains(fullPath, "/super-secrets/") {
 		fullPath = strings.ReplaceAll(fullPath, "/super-secrets/", "/")
 	}
-	retries := 0
-retryQuery:
-	Secret, err := m.logical.Write(fullPath, sendData)
-	if netErr, netErrOk := err.(*url.Error); netErrOk && netErr.Unwrap().Error() == "EOF" {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	} else if err == context.DeadlineExceeded || os.IsTimeout(err) {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	}
+	return fullPath
+}
+
+// write sends sendData to fullPath, retrying per the modifier's RetryPolicy.
+func (m *Modifier) write(ctx context.Context, fullPath string, sendData map[string]interface{}, logger *log.Logger) ([]string, error) {
+	Secret, retries, err := m.retry(ctx, func(ctx context.Context) (*api.Secret, error) {
+		return m.storage.Write(ctx, fullPath, sendData)
+	})
 	if err != nil {
 		logger.Printf("Modifier failing after %d retries.\n", retries)
 	}
@@ -355,6 +546,21 @@ retryQuery:
 //
 //	errors generated from reading
 func (m *Modifier) ReadData(path string) (map[string]interface{}, error) {
+	return m.ReadDataWithContext(context.Background(), path)
+}
+
+// ReadDataWithContext reads as ReadData does, giving up when ctx is done.
+func (m *Modifier) ReadDataWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	data, _, err := m.readDataVersion(ctx, path)
+	return data, err
+}
+
+// ReadDataWithVersion reads as ReadData does, also returning the version read, 0 if unknown.
+func (m *Modifier) ReadDataWithVersion(path string) (map[string]interface{}, int, error) {
+	return m.readDataVersion(context.Background(), path)
+}
+
+func (m *Modifier) readDataVersion(ctx context.Context, path string) (map[string]interface{}, int, error) {
 	bucket := path
 	// Create full path
 	if len(m.SectionPath) > 0 && !strings.HasPrefix(path, "templates") && !strings.HasPrefix(path, "value-metrics") { //Template paths are not indexed -> values & super-secrets are
@@ -375,36 +581,36 @@ func (m *Modifier) ReadData(path string) (map[string]interface{}, error) {
 	if len(pathBlocks) > 1 {
 		fullPath += pathBlocks[1]
 	}
-	retryCount := 0
-retryVaultAccess:
 
-	var secret *api.Secret
-	var err error
-	var versionMap = make(map[string][]string)
-	if strings.HasSuffix(m.Version, "***X-Mode") { //x path
+	// Which version to read, "" for the latest.
+	readVersion := ""
+	if !m.AsOf.IsZero() { //point in time path
+		asOfVersion, asOfErr := m.asOfVersion(ctx, path, m.AsOf)
+		if asOfErr != nil || asOfVersion == "" {
+			// Nothing existed here at that instant.
+			return nil, 0, asOfErr
+		}
+		readVersion = asOfVersion
+	} else if strings.HasSuffix(m.Version, "***X-Mode") { //x path
 		if m.Version != "" && m.Version != "0" && strings.HasPrefix(path, "templates") {
 			m.Version = strings.Split(m.Version, "***")[0]
-			versionSlice := []string{m.Version}
-			versionMap["version"] = versionSlice
-			secret, err = m.logical.ReadWithData(fullPath, versionMap)
+			readVersion = m.Version
+		} else {
+			return nil, 0, nil
 		}
 	} else if m.Version != "" && !strings.HasPrefix(path, "templates") { //config path
-		versionSlice := []string{m.Version}
-		versionMap["version"] = versionSlice
-		secret, err = m.logical.ReadWithData(fullPath, versionMap)
-	} else {
-		secret, err = m.logical.Read(fullPath)
+		readVersion = m.Version
 	}
 
-	if err != nil {
-		if retryCount < 7 {
-			retryCount = retryCount + 1
-			goto retryVaultAccess
+	secret, _, err := m.retry(ctx, func(ctx context.Context) (*api.Secret, error) {
+		if readVersion != "" {
+			return m.storage.ReadWithVersion(ctx, fullPath, readVersion)
 		}
-	}
+		return m.storage.Read(ctx, fullPath)
+	})
 
 	if secret == nil {
-		return nil, err
+		return nil, 0, err
 	}
 	if data, ok := secret.Data["data"].(map[string]interface{}); ok {
 		//
@@ -453,7 +659,7 @@ retryVaultAccess:
 							//memprotectopts.MemProtect(nil, &dataValueString)
 							// don't lock but accept json.Number.
 						} else {
-							return nil, fmt.Errorf("unexpected datatype. Refusing to read what we cannot lock. Nested. %T", dataValues)
+							return nil, 0, fmt.Errorf("unexpected datatype. Refusing to read what we cannot lock. Nested. %T", dataValues)
 						}
 					}
 				} else if dataValueString, isString := dataValues.(string); isString {
@@ -471,20 +677,24 @@ retryVaultAccess:
 					//memprotectopts.MemProtect(nil, &dataValueString)
 					// don't lock but accept json.Number.
 				} else {
-					return nil, fmt.Errorf("unexpected datatype. Refusing to read what we cannot lock. %T", dataValues)
+					return nil, 0, fmt.Errorf("unexpected datatype. Refusing to read what we cannot lock. %T", dataValues)
 				}
 			}
 		}
 		if len(data) == 0 {
-			return nil, errors.New("could not get data from vault.  Provided token may lack permission to access provided path")
+			return nil, 0, errors.New("could not get data from vault.  Provided token may lack permission to access provided path")
 		}
-		return data, err
+		version := 0
+		if metadata, ok := secret.Data["metadata"].(map[string]interface{}); ok {
+			version, _ = versionNumber(metadata["version"])
+		}
+		return data, version, err
 	}
 
 	if err == nil && secret != nil {
-		return nil, nil //Handle deleted, but not destroyed data from vault.
+		return nil, 0, nil //Handle deleted, but not destroyed data from vault.
 	}
-	return nil, errors.New("could not get data from vault response")
+	return nil, 0, errors.New("could not get data from vault response")
 }
 
 // ReadMapValue takes a valueMap, path, and a key and returns the corresponding value from the vault
@@ -524,24 +734,17 @@ func (m *Modifier) ReadMetadata(path string, logger *log.Logger) (map[string]int
 		fullPath += m.Env + "/"
 	}
 	fullPath += pathBlocks[1]
-	retries := 0
-retryQuery:
-	secret, err := m.logical.Read(fullPath)
-	if netErr, netErrOk := err.(*url.Error); netErrOk && netErr.Unwrap().Error() == "EOF" {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	} else if err == context.DeadlineExceeded || os.IsTimeout(err) {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	}
+	secret, retries, err := m.retry(context.Background(), func(ctx context.Context) (*api.Secret, error) {
+		return m.storage.Read(ctx, fullPath)
+	})
 	if err != nil {
 		logger.Printf("modifier failing after %d retries.\n", retries)
+		return nil, err
 	}
 
+	if secret == nil {
+		return nil, errors.New("could not get metadata from vault response")
+	}
 	if data, ok := secret.Data["metadata"].(map[string]interface{}); ok {
 		return data, err
 	}
@@ -550,6 +753,11 @@ retryQuery:
 
 // ReadVersionMetadata Reads the Metadata of all versions from the path referenced by this Modifier
 func (m *Modifier) ReadVersionMetadata(path string, logger *log.Logger) (map[string]interface{}, error) {
+	return m.ReadVersionMetadataWithContext(context.Background(), path, logger)
+}
+
+// ReadVersionMetadataWithContext reads as ReadVersionMetadata does, giving up when ctx is done.
+func (m *Modifier) ReadVersionMetadataWithContext(ctx context.Context, path string, logger *log.Logger) (map[string]interface{}, error) {
 	// Create full path
 	pathBlocks := strings.SplitAfterN(path, "/", 2)
 	fullPath := pathBlocks[0] + "metadata/"
@@ -560,25 +768,16 @@ func (m *Modifier) ReadVersionMetadata(path string, logger *log.Logger) (map[str
 	if len(pathBlocks) > 1 {
 		fullPath += pathBlocks[1]
 	}
-	retries := 0
-retryQuery:
-	secret, err := m.logical.Read(fullPath)
-	if netErr, netErrOk := err.(*url.Error); netErrOk && netErr.Unwrap().Error() == "EOF" {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	} else if err == context.DeadlineExceeded || os.IsTimeout(err) {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	} else if secret == nil || secret.Data["versions"] == nil {
+	secret, retries, err := m.retry(ctx, func(ctx context.Context) (*api.Secret, error) {
+		return m.storage.Metadata(ctx, fullPath)
+	})
+	if err == nil && (secret == nil || secret.Data["versions"] == nil) {
 		return nil, errors.New("no version data")
 	}
 
 	if err != nil {
 		logger.Printf("Modifier failing after %d retries.\n", retries)
+		return nil, err
 	}
 
 	if versionsData, ok := secret.Data["versions"].(map[string]interface{}); ok {
@@ -587,8 +786,59 @@ retryQuery:
 	return nil, errors.New("could not get metadata of versions from vault response")
 }
 
+// asOfVersion finds the version of path that was current at asOf.
+// Returns "" if the path did not exist or had been deleted at that instant, and an error if
+// the versions from that instant are no longer retained.
+func (m *Modifier) asOfVersion(ctx context.Context, path string, asOf time.Time) (string, error) {
+	versionsData, err := m.ReadVersionMetadataWithContext(ctx, path, log.New(io.Discard, "", 0))
+	if err != nil {
+		if err.Error() == "no version data" {
+			return "", nil
+		}
+		return "", err
+	}
+	asOfVersion := 0
+	oldestVersion := 0
+	var asOfMetadata map[string]interface{}
+	for versionKey, versionData := range versionsData {
+		version, convErr := strconv.Atoi(versionKey)
+		metadata, metadataOk := versionData.(map[string]interface{})
+		if convErr != nil || !metadataOk {
+			continue
+		}
+		if oldestVersion == 0 || version < oldestVersion {
+			oldestVersion = version
+		}
+		if version <= asOfVersion {
+			continue
+		}
+		createdTime, timeErr := time.Parse(time.RFC3339Nano, fmt.Sprintf("%v", metadata["created_time"]))
+		if timeErr != nil || createdTime.After(asOf) {
+			continue
+		}
+		asOfVersion = version
+		asOfMetadata = metadata
+	}
+	if asOfVersion == 0 {
+		if oldestVersion > 1 {
+			// Older versions were pruned, so what was current then can't be known.
+			return "", fmt.Errorf("%s has no retained version as of %s: oldest retained version is %d", path, asOf.Format(time.RFC3339), oldestVersion)
+		}
+		return "", nil
+	}
+	if deletionTime, timeErr := time.Parse(time.RFC3339Nano, fmt.Sprintf("%v", asOfMetadata["deletion_time"])); timeErr == nil && !deletionTime.After(asOf) {
+		return "", nil
+	}
+	return strconv.Itoa(asOfVersion), nil
+}
+
 // List lists the paths underneath this one
 func (m *Modifier) List(path string, logger *log.Logger) (*api.Secret, error) {
+	return m.ListWithContext(context.Background(), path, logger)
+}
+
+// ListWithContext lists as List does, giving up when ctx is done.
+func (m *Modifier) ListWithContext(ctx context.Context, path string, logger *log.Logger) (*api.Secret, error) {
 	pathBlocks := strings.SplitAfterN(path, "/", 2)
 	if len(pathBlocks) == 1 {
 		pathBlocks[0] += "/"
@@ -606,20 +856,9 @@ func (m *Modifier) List(path string, logger *log.Logger) (*api.Secret, error) {
 		}
 		fullPath += pathBlocks[1]
 	}
-	retries := 0
-retryQuery:
-	result, err := m.logical.List(fullPath)
-	if netErr, netErrOk := err.(*url.Error); netErrOk && netErr.Unwrap().Error() == "EOF" {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	} else if err == context.DeadlineExceeded || os.IsTimeout(err) {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	}
+	result, retries, err := m.retry(ctx, func(ctx context.Context) (*api.Secret, error) {
+		return m.storage.List(ctx, fullPath)
+	})
 	if err != nil {
 		logger.Printf("Modifier failing after %d retries.\n", retries)
 		logger.Printf(err.Error())
@@ -638,20 +877,9 @@ func (m *Modifier) ListEnv(path string, logger *log.Logger) (*api.Secret, error)
 		fullPath = pathBlocks[0] + "metadata/"
 		fullPath = fullPath + pathBlocks[1]
 	}
-	retries := 0
-retryQuery:
-	result, err := m.logical.List(fullPath)
-	if netErr, netErrOk := err.(*url.Error); netErrOk && netErr.Unwrap().Error() == "EOF" {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	} else if err == context.DeadlineExceeded || os.IsTimeout(err) {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	}
+	result, retries, err := m.retry(context.Background(), func(ctx context.Context) (*api.Secret, error) {
+		return m.storage.List(ctx, fullPath)
+	})
 	if err != nil {
 		logger.Printf("Modifier failing after %d retries.\n", retries)
 	}
@@ -691,11 +919,14 @@ func (m *Modifier) AdjustValue(path string, data map[string]interface{}, n int,
 
 // Proper shutdown of modifier.
 func (m *Modifier) Close() {
-	m.httpClient.CloseIdleConnections()
+	tokenSwapLock.Lock()
+	m.checkInLocked()
+	tokenSwapLock.Unlock()
+	m.storage.Close()
 }
 
 func (m *Modifier) Exists(path string) bool {
-	secret, err := m.logical.List(path)
+	secret, err := m.storage.List(context.Background(), path)
 
 	if err != nil {
 		return false
@@ -1023,7 +1254,11 @@ indexFound:
 }
 
 func (m *Modifier) SoftDelete(path string, logger *log.Logger) (map[string]interface{}, error) {
+	return m.SoftDeleteWithContext(context.Background(), path, logger)
+}
 
+// SoftDeleteWithContext deletes as SoftDelete does, giving up when ctx is done.
+func (m *Modifier) SoftDeleteWithContext(ctx context.Context, path string, logger *log.Logger) (map[string]interface{}, error) {
 	if !strings.HasPrefix(path, "super-secrets") && !strings.HasPrefix(path, "values") {
 		path = "super-secrets/" + path
 	}
@@ -1034,20 +1269,9 @@ func (m *Modifier) SoftDelete(path string, logger *log.Logger) (map[string]inter
 		fullDataPath += m.Env + "/"
 	}
 	fullDataPath += pathBlocks[1]
-	retries := 0
-retryQuery:
-	secret, err := m.logical.Delete(fullDataPath)
-	if netErr, netErrOk := err.(*url.Error); netErrOk && netErr.Unwrap().Error() == "EOF" {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	} else if err == context.DeadlineExceeded || os.IsTimeout(err) {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	}
+	secret, retries, err := m.retry(ctx, func(ctx context.Context) (*api.Secret, error) {
+		return m.storage.Delete(ctx, fullDataPath)
+	})
 	if err != nil {
 		logger.Printf("Modifier failing after %d retries.\n", retries)
 	}
@@ -1073,37 +1297,18 @@ func (m *Modifier) HardDelete(path string, logger *log.Logger) (map[string]inter
 	}
 	fullDataPath += pathBlocks[1]
 	fullMetadataPath += pathBlocks[1]
-	retries := 0
-retryQuery:
-	secret, err := m.logical.Delete(fullDataPath)
-	if netErr, netErrOk := err.(*url.Error); netErrOk && netErr.Unwrap().Error() == "EOF" {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	} else if err == context.DeadlineExceeded || os.IsTimeout(err) {
-		if retries < 3 {
-			retries = retries + 1
-			goto retryQuery
-		}
-	}
+	ctx := context.Background()
+	secret, retries, err := m.retry(ctx, func(ctx context.Context) (*api.Secret, error) {
+		return m.storage.Delete(ctx, fullDataPath)
+	})
 	if err != nil {
 		logger.Printf("Modifier failing after %d retries.\n", retries)
 	}
 
 	if secret == nil && err == nil {
-		metadataSecret, err := m.logical.Delete(fullMetadataPath)
-		if netErr, netErrOk := err.(*url.Error); netErrOk && netErr.Unwrap().Error() == "EOF" {
-			if retries < 3 {
-				retries = retries + 1
-				goto retryQuery
-			}
-		} else if err == context.DeadlineExceeded || os.IsTimeout(err) {
-			if retries < 3 {
-				retries = retries + 1
-				goto retryQuery
-			}
-		}
+		metadataSecret, retries, err := m.retry(ctx, func(ctx context.Context) (*api.Secret, error) {
+			return m.storage.Delete(ctx, fullMetadataPath)
+		})
 		if err != nil {
 			logger.Printf("Modifier failing after %d retries.\n", retries)
 		}
@@ -1117,3 +1322,101 @@ retryQuery:
 	}
 	return nil, errors.New("could not get metadata from vault response")
 }
+
+// kvPath - the full path for op (data, metadata or undelete) on path, resolved as SoftDelete
+// resolves it.
+func (m *Modifier) kvPath(op string, path string) string {
+	if !strings.HasPrefix(path, "super-secrets") && !strings.HasPrefix(path, "values") {
+		path = "super-secrets/" + path
+	}
+	pathBlocks := strings.SplitAfterN(path, "/", 2)
+	fullPath := pathBlocks[0] + op + "/"
+	if !noEnvironments[pathBlocks[0]] {
+		fullPath += m.Env + "/"
+	}
+	if len(pathBlocks) > 1 {
+		fullPath += pathBlocks[1]
+	}
+	return fullPath
+}
+
+// currentVersion - the latest version of path, 0 if nothing has been written there.
+func (m *Modifier) currentVersion(ctx context.Context, path string) (int, error) {
+	fullPath := m.kvPath("metadata", path)
+	secret, _, err := m.retry(ctx, func(ctx context.Context) (*api.Secret, error) {
+		return m.storage.Metadata(ctx, fullPath)
+	})
+	if err != nil || secret == nil {
+		return 0, err
+	}
+	return versionNumber(secret.Data["current_version"])
+}
+
+// VersionAsOf finds the version of path that was current at asOf.  Returns 0 if the path did not
+// exist or had been deleted at that instant, and an error if versions that old aren't retained.
+func (m *Modifier) VersionAsOf(path string, asOf time.Time) (int, error) {
+	version, err := m.asOfVersion(context.Background(), path, asOf)
+	if err != nil || version == "" {
+		return 0, err
+	}
+	return strconv.Atoi(version)
+}
+
+// ReadDataAtVersion reads version of the data at path, 0 for the latest.  Paths resolve as they do
+// for SoftDelete.  Returns nil if that version doesn't exist or was deleted.
+func (m *Modifier) ReadDataAtVersion(path string, version int) (map[string]interface{}, error) {
+	fullPath := m.kvPath("data", path)
+	secret, _, err := m.retry(context.Background(), func(ctx context.Context) (*api.Secret, error) {
+		if version > 0 {
+			return m.storage.ReadWithVersion(ctx, fullPath, strconv.Itoa(version))
+		}
+		return m.storage.Read(ctx, fullPath)
+	})
+	if err != nil || secret == nil {
+		return nil, err
+	}
+	data, _ := secret.Data["data"].(map[string]interface{})
+	return data, nil
+}
+
+// Undelete restores versions of path removed by SoftDelete, the latest version if none are given.
+// Destroyed versions can't be restored.
+func (m *Modifier) Undelete(path string, versions []int, logger *log.Logger) error {
+	ctx := context.Background()
+	if len(versions) == 0 {
+		current, err := m.currentVersion(ctx, path)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			return fmt.Errorf("nothing to undelete at %s", path)
+		}
+		versions = []int{current}
+	}
+	fullPath := m.kvPath("undelete", path)
+	_, retries, err := m.retry(ctx, func(ctx context.Context) (*api.Secret, error) {
+		return m.storage.Write(ctx, fullPath, map[string]interface{}{"versions": versions})
+	})
+	if err != nil {
+		logger.Printf("Modifier failing after %d retries.\n", retries)
+	}
+	return err
+}
+
+// RollbackTo writes the data path had at version as its newest version, leaving the versions in
+// between in its history.  current is the version path is rolled back from, as read when the
+// rollback was reviewed.  Returns a *CASConflictError if path is no longer at current.
+func (m *Modifier) RollbackTo(path string, version int, current int, logger *log.Logger) ([]string, error) {
+	ctx := context.Background()
+	if version <= 0 || version > current {
+		return nil, fmt.Errorf("%s has no version %d", path, version)
+	}
+	data, err := m.ReadDataAtVersion(path, version)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("version %d of %s was deleted", version, path)
+	}
+	return m.writeCAS(ctx, path, m.kvPath("data", path), data, current, logger)
+}
@@ -0,0 +1,86 @@
+package kv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/trimble-oss/tierceron/buildopts/coreopts"
+)
+
+func TestSwapCachedTokenCheckedOut(t *testing.T) {
+	if coreopts.BuildOptions == nil {
+		coreopts.NewOptionsBuilder(coreopts.LoadOptions())
+	}
+	address := "http://127.0.0.1:8299"
+	mod, err := NewModifier(false, "old", address, "swap", nil, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Swapped while checked out, then swapped again.
+	SwapCachedToken(address, "old", "new", time.Time{})
+	SwapCachedToken(address, "new", "newer", time.Time{})
+	tokenSwapLock.Lock()
+	if swap := tokenSwaps["old"]; swap.token != "newer" {
+		t.Fatalf("Expected old to swap straight to newer, got %s", swap.token)
+	}
+	tokenSwapLock.Unlock()
+	mod.Release()
+	tokenSwapLock.Lock()
+	if _, ok := tokenSwaps["old"]; ok {
+		t.Fatal("Expected the swap forgotten once the modifier was brought up to date")
+	}
+	tokenSwapLock.Unlock()
+
+	checkedOut, err := NewModifier(false, "old", address, "swap", nil, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checkedOut.Close()
+	if checkedOut != mod {
+		t.Fatal("Expected the cached modifier")
+	}
+	if client, _ := checkedOut.vault(); client.Token() != "newer" {
+		t.Fatalf("Expected newer, got %s", client.Token())
+	}
+
+	// Tokens of other addresses are left alone.
+	other, err := NewModifier(false, "newer", "http://127.0.0.1:8298", "swap", nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	SwapCachedToken(address, "newer", "newest", time.Time{})
+	other.refreshToken()
+	if client, _ := other.vault(); client.Token() != "newer" {
+		t.Fatalf("Expected newer, got %s", client.Token())
+	}
+}
+
+func TestSwapCachedTokenExpired(t *testing.T) {
+	if coreopts.BuildOptions == nil {
+		coreopts.NewOptionsBuilder(coreopts.LoadOptions())
+	}
+	address := "http://127.0.0.1:8297"
+	mod, err := NewModifier(false, "expired", address, "swapexpired", nil, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mod.Close()
+
+	SwapCachedToken(address, "expired", "renewed", time.Now().Add(-time.Second))
+	tokenSwapLock.Lock()
+	_, ok := tokenSwaps["expired"]
+	tokenSwapLock.Unlock()
+	if ok {
+		t.Fatal("Expected the swap of an expired token forgotten")
+	}
+
+	SwapCachedToken(address, "renewed", "current", time.Now().Add(time.Hour))
+	tokenSwapLock.Lock()
+	_, ok = tokenSwaps["renewed"]
+	tokenSwapLock.Unlock()
+	if !ok {
+		t.Fatal("Expected the swap kept while a checked out modifier may hold the token")
+	}
+}
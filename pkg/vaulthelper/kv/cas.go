@@ -0,0 +1,233 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// casMismatch is how vault rejects a check-and-set write.
+const casMismatch = "check-and-set parameter did not match the current version"
+
+// casRetries - times MergeWrite merges in another writer's changes before giving up.
+const casRetries = 3
+
+// CASConflictError is returned by check-and-set writes when the path changed after it was read.
+type CASConflictError struct {
+	Path    string   // Path written.
+	Version int      // Version the write expected.
+	Fields  []string // Fields changed by both writers, when merging.
+}
+
+func (e *CASConflictError) Error() string {
+	if len(e.Fields) > 0 {
+		return fmt.Sprintf("%s changed since version %d: conflicting fields %s", e.Path, e.Version, strings.Join(e.Fields, ", "))
+	}
+	return fmt.Sprintf("%s changed since version %d", e.Path, e.Version)
+}
+
+// IsCASConflict - true if err reports a path changed after it was read.
+func IsCASConflict(err error) bool {
+	var conflict *CASConflictError
+	return errors.As(err, &conflict)
+}
+
+// ReadDataVersion reads the data at path along with its version for WriteCAS.  Paths resolve
+// as they do for Write.  Version 0 means nothing has been written to path.  Data is nil when the
+// latest version was deleted.
+func (m *Modifier) ReadDataVersion(path string) (map[string]interface{}, int, error) {
+	return m.ReadDataVersionWithContext(context.Background(), path)
+}
+
+// ReadDataVersionWithContext reads as ReadDataVersion does, giving up when ctx is done.
+func (m *Modifier) ReadDataVersionWithContext(ctx context.Context, path string) (map[string]interface{}, int, error) {
+	fullPath := m.writePath(path)
+	secret, _, err := m.retry(ctx, func(ctx context.Context) (*api.Secret, error) {
+		return m.storage.Read(ctx, fullPath)
+	})
+	if err != nil || secret == nil {
+		return nil, 0, err
+	}
+	version := 0
+	if metadata, ok := secret.Data["metadata"].(map[string]interface{}); ok {
+		if version, err = versionNumber(metadata["version"]); err != nil {
+			return nil, 0, err
+		}
+	}
+	data, _ := secret.Data["data"].(map[string]interface{})
+	return data, version, nil
+}
+
+// WriteCAS writes data to path only if path is still at version: 0 if nothing may have been written
+// there yet.  Returns a *CASConflictError if path changed.
+func (m *Modifier) WriteCAS(path string, data map[string]interface{}, version int, logger *log.Logger) ([]string, error) {
+	return m.WriteCASWithContext(context.Background(), path, data, version, logger)
+}
+
+// WriteCASWithContext writes as WriteCAS does, giving up when ctx is done.
+func (m *Modifier) WriteCASWithContext(ctx context.Context, path string, data map[string]interface{}, version int, logger *log.Logger) ([]string, error) {
+	return m.writeCAS(ctx, path, m.writePath(path), data, version, logger)
+}
+
+// writeCAS writes data to fullPath, the full path of path, only if it is still at version.  A retry
+// rejected by check-and-set may follow an attempt that was written but whose response was lost, so
+// finding data written as the next version counts as success.
+func (m *Modifier) writeCAS(ctx context.Context, path string, fullPath string, data map[string]interface{}, version int, logger *log.Logger) ([]string, error) {
+	sendData := map[string]interface{}{
+		"options": map[string]interface{}{"cas": version},
+		"data":    data,
+	}
+	secret, retries, err := m.retry(ctx, func(ctx context.Context) (*api.Secret, error) {
+		return m.storage.Write(ctx, fullPath, sendData)
+	})
+	if err != nil && strings.Contains(err.Error(), casMismatch) {
+		if retries > 0 && m.wroteVersion(ctx, fullPath, data, version+1) {
+			return nil, nil
+		}
+		return nil, &CASConflictError{Path: path, Version: version}
+	}
+	if err != nil {
+		logger.Printf("Modifier failing after %d retries.\n", retries)
+	}
+	if secret == nil {
+		return nil, err
+	}
+	return secret.Warnings, err
+}
+
+// wroteVersion - true if fullPath is at version and holds data.
+func (m *Modifier) wroteVersion(ctx context.Context, fullPath string, data map[string]interface{}, version int) bool {
+	secret, _, err := m.retry(ctx, func(ctx context.Context) (*api.Secret, error) {
+		return m.storage.Read(ctx, fullPath)
+	})
+	if err != nil || secret == nil {
+		return false
+	}
+	metadata, _ := secret.Data["metadata"].(map[string]interface{})
+	if current, err := versionNumber(metadata["version"]); err != nil || current != version {
+		return false
+	}
+	current, _ := secret.Data["data"].(map[string]interface{})
+	return len(changedFields(data, current)) == 0
+}
+
+// MergeWrite writes data, which began as base read from path at version, using check-and-set.  If
+// another writer changed path in the meantime and the fields each changed don't overlap, their
+// changes are merged with these and the write is retried.  Otherwise a *CASConflictError names the
+// fields both changed.
+func (m *Modifier) MergeWrite(path string, base map[string]interface{}, data map[string]interface{}, version int, logger *log.Logger) ([]string, error) {
+	return m.MergeWriteWithContext(context.Background(), path, base, data, version, logger)
+}
+
+// MergeWriteWithContext writes as MergeWrite does, giving up when ctx is done.
+func (m *Modifier) MergeWriteWithContext(ctx context.Context, path string, base map[string]interface{}, data map[string]interface{}, version int, logger *log.Logger) ([]string, error) {
+	changes := changedFields(base, data)
+	for retries := 0; ; retries++ {
+		warnings, err := m.WriteCASWithContext(ctx, path, data, version, logger)
+		if !IsCASConflict(err) || retries == casRetries {
+			return warnings, err
+		}
+
+		current, currentVersion, readErr := m.ReadDataVersionWithContext(ctx, path)
+		if readErr != nil {
+			return nil, readErr
+		}
+		conflicts := []string{}
+		for field := range changedFields(base, current) {
+			if changes[field] && !sameField(current, data, field) {
+				conflicts = append(conflicts, field)
+			}
+		}
+		if len(conflicts) > 0 {
+			sort.Strings(conflicts)
+			return nil, &CASConflictError{Path: path, Version: version, Fields: conflicts}
+		}
+
+		merged := map[string]interface{}{}
+		for field, value := range current {
+			merged[field] = value
+		}
+		for field := range changes {
+			if value, ok := data[field]; ok {
+				merged[field] = value
+			} else {
+				delete(merged, field)
+			}
+		}
+		base, data, version = current, merged, currentVersion
+	}
+}
+
+// Update reads path, applies change to a copy of its data and writes the result with MergeWrite.
+func (m *Modifier) Update(path string, change func(map[string]interface{}) (map[string]interface{}, error), logger *log.Logger) ([]string, error) {
+	return m.UpdateWithContext(context.Background(), path, change, logger)
+}
+
+// UpdateWithContext updates as Update does, giving up when ctx is done.
+func (m *Modifier) UpdateWithContext(ctx context.Context, path string, change func(map[string]interface{}) (map[string]interface{}, error), logger *log.Logger) ([]string, error) {
+	base, version, err := m.ReadDataVersionWithContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]interface{}{}
+	for field, value := range base {
+		data[field] = value
+	}
+	data, err = change(data)
+	if err != nil {
+		return nil, err
+	}
+	return m.MergeWriteWithContext(ctx, path, base, data, version, logger)
+}
+
+// changedFields - fields added, removed or changed going from base to data.
+func changedFields(base map[string]interface{}, data map[string]interface{}) map[string]bool {
+	changed := map[string]bool{}
+	for field := range base {
+		if !sameField(base, data, field) {
+			changed[field] = true
+		}
+	}
+	for field := range data {
+		if !sameField(base, data, field) {
+			changed[field] = true
+		}
+	}
+	return changed
+}
+
+// sameField - true if field is missing from both a and b or has the same value in each.  Values
+// are compared as printed since vault returns numbers as json.Number.
+func sameField(a map[string]interface{}, b map[string]interface{}, field string) bool {
+	aValue, aOk := a[field]
+	bValue, bOk := b[field]
+	if aOk != bOk {
+		return false
+	}
+	return !aOk || fmt.Sprint(aValue) == fmt.Sprint(bValue)
+}
+
+// versionNumber - a version from vault metadata.
+func versionNumber(version interface{}) (int, error) {
+	switch v := version.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err
+	case float64:
+		return int(v), nil
+	case int:
+		return v, nil
+	case string:
+		return strconv.Atoi(v)
+	}
+	return 0, fmt.Errorf("unexpected version: %v", version)
+}
@@ -0,0 +1,50 @@
+package kv
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+func TestMergeWrite(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	mod := NewModifierWithStorage(NewMemoryStorage(), "dev", nil)
+	mod.Env = "dev"
+	path := "super-secrets/Project/Service"
+
+	if _, err := mod.WriteCAS(path, map[string]interface{}{"a": "1", "b": "1"}, 0, logger); err != nil {
+		t.Fatalf("WriteCAS failed: %v", err)
+	}
+	if _, err := mod.WriteCAS(path, map[string]interface{}{"a": "2"}, 0, logger); !IsCASConflict(err) {
+		t.Fatalf("Expected conflict, got %v", err)
+	}
+
+	base, version, err := mod.ReadDataVersion(path)
+	if err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %d %v", version, err)
+	}
+
+	// Another writer changes b, adds c.
+	if _, err := mod.Update(path, func(data map[string]interface{}) (map[string]interface{}, error) {
+		data["b"] = "2"
+		data["c"] = "2"
+		return data, nil
+	}, logger); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	// Changing a merges with their change.
+	if _, err := mod.MergeWrite(path, base, map[string]interface{}{"a": "3", "b": "1"}, version, logger); err != nil {
+		t.Fatalf("MergeWrite failed: %v", err)
+	}
+	data, version, err := mod.ReadDataVersion(path)
+	if err != nil || version != 3 || data["a"] != "3" || data["b"] != "2" || data["c"] != "2" {
+		t.Fatalf("Expected merged version 3, got %v %d %v", data, version, err)
+	}
+
+	// Changing b again conflicts.
+	_, err = mod.MergeWrite(path, base, map[string]interface{}{"a": "1", "b": "4"}, 1, logger)
+	if conflict, ok := err.(*CASConflictError); !ok || len(conflict.Fields) != 1 || conflict.Fields[0] != "b" {
+		t.Fatalf("Expected conflict on b, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// How long to wait on a node's sys/health before trying the next.
+const healthProbeTimeout = 5 * time.Second
+
+// leaders - the last healthy leader found for each list of nodes.
+var leaders = map[string]string{}
+var leadersLock sync.Mutex
+
+// IsMultiAddress - true if address lists the nodes of a vault cluster: https://vault1:8200,https://vault2:8200
+func IsMultiAddress(address string) bool {
+	return strings.Contains(address, ",")
+}
+
+// SplitAddresses - the nodes listed in address.
+func SplitAddresses(address string) []string {
+	nodes := []string{}
+	for _, node := range strings.Split(address, ",") {
+		if node = strings.TrimRight(strings.TrimSpace(node), "/"); node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// ResolveAddress - the active node of the vault cluster listed in address.  The last healthy leader
+// found by the process is used until it fails.  Otherwise each node's sys/health is probed in turn.
+// Addresses of a single node are returned as is.
+func ResolveAddress(insecure bool, address string, env string, logger *log.Logger) (string, error) {
+	if !IsMultiAddress(address) {
+		return address, nil
+	}
+	leadersLock.Lock()
+	leader, ok := leaders[address]
+	leadersLock.Unlock()
+	if ok {
+		return leader, nil
+	}
+	return findLeader(insecure, address, env, "", logger)
+}
+
+// findLeader probes the nodes listed in address for the active one, trying failed, a node that just
+// failed, last.
+func findLeader(insecure bool, address string, env string, failed string, logger *log.Logger) (string, error) {
+	nodes := SplitAddresses(address)
+	leadersLock.Lock()
+	leader := leaders[address]
+	leadersLock.Unlock()
+
+	ordered := []string{}
+	if leader != "" && leader != failed {
+		ordered = append(ordered, leader)
+	}
+	for _, node := range nodes {
+		if node != leader && node != failed {
+			ordered = append(ordered, node)
+		}
+	}
+	if failed != "" {
+		ordered = append(ordered, failed)
+	}
+
+	statuses := []string{}
+	for _, node := range ordered {
+		status, err := probeHealth(insecure, node, env)
+		if err == nil && status == http.StatusOK {
+			leadersLock.Lock()
+			leaders[address] = node
+			leadersLock.Unlock()
+			if logger != nil {
+				logger.Printf("Selected vault node %s\n", node)
+			}
+			return node, nil
+		}
+		if err != nil {
+			statuses = append(statuses, fmt.Sprintf("%s: %v", node, err))
+		} else {
+			statuses = append(statuses, fmt.Sprintf("%s: %s", node, healthStatus(status)))
+		}
+	}
+	return "", fmt.Errorf("no active vault node - %s", strings.Join(statuses, ", "))
+}
+
+// FailoverAddress - the active node of the cluster listed in nodes when err means failed, the node
+// connected to, is down.  False if nodes is empty or no other node is active.
+func FailoverAddress(insecure bool, nodes string, env string, failed string, err error, logger *log.Logger) (string, bool) {
+	if nodes == "" || !nodeDown(err) {
+		return "", false
+	}
+	failed = strings.TrimRight(failed, "/")
+	if logger != nil {
+		logger.Printf("Vault node %s failed: %v\n", failed, err)
+	}
+	leader, findErr := findLeader(insecure, nodes, env, failed, logger)
+	if findErr != nil || leader == failed {
+		return "", false
+	}
+	return leader, true
+}
+
+// probeHealth - the status sys/health reports for node.
+func probeHealth(insecure bool, node string, env string) (int, error) {
+	httpClient, err := CreateHTTPClient(insecure, node, env, false)
+	if err != nil {
+		return 0, err
+	}
+	defer httpClient.CloseIdleConnections()
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthProbeTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, node+"/v1/sys/health", nil)
+	if err != nil {
+		return 0, err
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	return response.StatusCode, nil
+}
+
+// healthStatus describes a sys/health status.
+func healthStatus(status int) string {
+	switch status {
+	case 429:
+		return "standby"
+	case 472:
+		return "disaster recovery secondary"
+	case 473:
+		return "performance standby"
+	case 501:
+		return "not initialized"
+	case 503:
+		return "sealed"
+	}
+	return fmt.Sprintf("status %d", status)
+}
+
+// containsNode - true if node is one of the nodes listed in address.
+func containsNode(address string, node string) bool {
+	node = strings.TrimRight(node, "/")
+	for _, listed := range SplitAddresses(address) {
+		if listed == node {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeDown - true if err means the node can't serve requests: it's unreachable or sealed.
+func nodeDown(err error) bool {
+	var responseErr *api.ResponseError
+	if errors.As(err, &responseErr) {
+		return responseErr.StatusCode == http.StatusServiceUnavailable
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !misconfigured(err)
+}
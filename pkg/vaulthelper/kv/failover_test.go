@@ -0,0 +1,67 @@
+package kv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/trimble-oss/tierceron/buildopts/coreopts"
+)
+
+// stubNode serves sys/health with status and a single secret.
+func stubNode(status *int, value string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sys/health":
+			w.WriteHeader(*status)
+		case "/v1/super-secrets/data/dev/Project/Service":
+			w.Write([]byte(`{"data": {"data": {"key": "` + value + `"}, "metadata": {"version": 1}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestFailover(t *testing.T) {
+	if coreopts.BuildOptions == nil {
+		coreopts.NewOptionsBuilder(coreopts.LoadOptions())
+	}
+	sealed, active, standby := http.StatusServiceUnavailable, http.StatusOK, http.StatusTooManyRequests
+	nodeA := stubNode(&sealed, "a")
+	defer nodeA.Close()
+	nodeB := stubNode(&active, "b")
+	defer nodeB.Close()
+	nodeC := stubNode(&standby, "c")
+	defer nodeC.Close()
+
+	nodes := nodeA.URL + ", " + nodeB.URL + "/," + nodeC.URL
+	if leader, err := ResolveAddress(false, nodes, "dev", nil); err != nil || leader != nodeB.URL {
+		t.Fatalf("Expected %s active, got %s %v", nodeB.URL, leader, err)
+	}
+
+	mod, err := NewModifier(false, "token", nodes, "dev", nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod.Env = "dev"
+	mod.RetryPolicy = &RetryPolicy{}
+	if data, err := mod.ReadData("super-secrets/Project/Service"); err != nil || data["key"] != "b" {
+		t.Fatalf("Expected b, got %v %v", data, err)
+	}
+
+	// B goes away and C takes over.
+	nodeB.Close()
+	standby = http.StatusOK
+	if data, err := mod.ReadData("super-secrets/Project/Service"); err != nil || data["key"] != "c" {
+		t.Fatalf("Expected failover to c, got %v %v", data, err)
+	}
+	if leader, err := ResolveAddress(false, nodes, "dev", nil); err != nil || leader != nodeC.URL {
+		t.Fatalf("Expected %s cached as leader, got %s %v", nodeC.URL, leader, err)
+	}
+
+	standby = http.StatusTooManyRequests
+	nodeC.Close()
+	if _, err := findLeader(false, nodes, "dev", nodeC.URL, nil); err == nil {
+		t.Fatal("Expected no active node")
+	}
+}
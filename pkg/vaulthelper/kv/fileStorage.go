@@ -0,0 +1,169 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// fileStorage keeps secrets in a local json file so they outlast the process.  The file is
+// reloaded whenever another process changes it.  Intended for local development only:
+// secrets are stored unencrypted, readable only by the owner.
+type fileStorage struct {
+	mutex   sync.Mutex
+	path    string
+	modTime time.Time
+	store   kvStore
+}
+
+// NewFileStorage returns storage kept in the json file at path, created on first write.
+func NewFileStorage(path string) (Storage, error) {
+	if path == "" {
+		return nil, errors.New("file storage requires a path")
+	}
+	fs := &fileStorage{path: path, store: kvStore{entries: map[string]*kvEntry{}}}
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// load reads the file if it has changed since last read.
+func (fs *fileStorage) load() error {
+	fileInfo, err := os.Stat(fs.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fileInfo.ModTime().Equal(fs.modTime) {
+		return nil
+	}
+	storeBytes, err := os.ReadFile(fs.path)
+	if err != nil {
+		return err
+	}
+	entries := map[string]*kvEntry{}
+	if len(storeBytes) > 0 {
+		if err := json.Unmarshal(storeBytes, &entries); err != nil {
+			return errors.New("unable to read " + fs.path + ": " + err.Error())
+		}
+	}
+	fs.store.entries = entries
+	fs.modTime = fileInfo.ModTime()
+	return nil
+}
+
+// save replaces the file with the current entries.
+func (fs *fileStorage) save() error {
+	storeBytes, err := json.MarshalIndent(fs.store.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fs.path), 0700); err != nil {
+		return err
+	}
+	tempFile := fs.path + ".tmp"
+	if err := os.WriteFile(tempFile, storeBytes, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tempFile, fs.path); err != nil {
+		return err
+	}
+	if fileInfo, err := os.Stat(fs.path); err == nil {
+		fs.modTime = fileInfo.ModTime()
+	}
+	return nil
+}
+
+func (fs *fileStorage) Read(ctx context.Context, path string) (*api.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	return fs.store.read(path, 0)
+}
+
+func (fs *fileStorage) ReadWithVersion(ctx context.Context, path string, version string) (*api.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	n, err := readVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	return fs.store.read(path, n)
+}
+
+func (fs *fileStorage) Write(ctx context.Context, path string, data map[string]interface{}) (*api.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	secret, err := fs.store.write(path, data)
+	if err != nil {
+		return nil, err
+	}
+	return secret, fs.save()
+}
+
+func (fs *fileStorage) List(ctx context.Context, path string) (*api.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	return fs.store.list(path)
+}
+
+func (fs *fileStorage) Metadata(ctx context.Context, path string) (*api.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	return fs.store.metadata(path)
+}
+
+func (fs *fileStorage) Delete(ctx context.Context, path string) (*api.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	secret, err := fs.store.remove(path)
+	if err != nil {
+		return nil, err
+	}
+	return secret, fs.save()
+}
+
+func (fs *fileStorage) Close() {}
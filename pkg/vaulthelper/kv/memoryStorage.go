@@ -0,0 +1,358 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// kvVersion is one version of the data at a path.
+type kvVersion struct {
+	Data         map[string]interface{} `json:"data,omitempty"`
+	CreatedTime  time.Time              `json:"created_time"`
+	DeletionTime time.Time              `json:"deletion_time,omitempty"`
+	Destroyed    bool                   `json:"destroyed,omitempty"`
+}
+
+// kvEntry holds every version of the data at a path.
+type kvEntry struct {
+	CurrentVersion int                `json:"current_version"`
+	Versions       map[int]*kvVersion `json:"versions"`
+}
+
+// kvStore implements KV v2 semantics over entries kept by engine/path: templates/Project/Service/config
+// Callers synchronize access.
+type kvStore struct {
+	entries map[string]*kvEntry
+}
+
+// splitKvPath splits a logical path, super-secrets/data/dev/Project/..., into its operation, data,
+// and its key, super-secrets/dev/Project/...
+func splitKvPath(path string) (string, string, error) {
+	pathParts := strings.SplitN(strings.Trim(path, "/"), "/", 3)
+	if len(pathParts) < 2 {
+		return "", "", fmt.Errorf("unsupported path: %s", path)
+	}
+	key := pathParts[0]
+	if len(pathParts) == 3 && pathParts[2] != "" {
+		key = key + "/" + strings.TrimSuffix(pathParts[2], "/")
+	}
+	return pathParts[1], key, nil
+}
+
+func kvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func kvNumber(n int) json.Number {
+	return json.Number(strconv.Itoa(n))
+}
+
+// normalizeData returns data as vault returns it: decoded from json with numbers as json.Number.
+func normalizeData(data map[string]interface{}) (map[string]interface{}, error) {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(dataBytes))
+	decoder.UseNumber()
+	normalized := map[string]interface{}{}
+	if err := decoder.Decode(&normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+func (version *kvVersion) metadata(n int) map[string]interface{} {
+	return map[string]interface{}{
+		"version":       kvNumber(n),
+		"created_time":  kvTime(version.CreatedTime),
+		"deletion_time": kvTime(version.DeletionTime),
+		"destroyed":     version.Destroyed,
+	}
+}
+
+func (store *kvStore) read(path string, version int) (*api.Secret, error) {
+	op, key, err := splitKvPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if op != "data" {
+		return nil, fmt.Errorf("unsupported path: %s", path)
+	}
+	entry, ok := store.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	if version <= 0 {
+		version = entry.CurrentVersion
+	}
+	stored, ok := entry.Versions[version]
+	if !ok {
+		return nil, nil
+	}
+	secretData := map[string]interface{}{"data": nil, "metadata": stored.metadata(version)}
+	if stored.DeletionTime.IsZero() && !stored.Destroyed {
+		// Copies so callers can't change what's stored.
+		data, err := normalizeData(stored.Data)
+		if err != nil {
+			return nil, err
+		}
+		secretData["data"] = data
+	}
+	return &api.Secret{Data: secretData}, nil
+}
+
+func (store *kvStore) write(path string, body map[string]interface{}) (*api.Secret, error) {
+	op, key, err := splitKvPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if op == "undelete" {
+		return nil, store.undelete(key, body)
+	}
+	if op != "data" {
+		return nil, fmt.Errorf("unsupported path: %s", path)
+	}
+	data, ok := body["data"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("no data provided")
+	}
+	data, err = normalizeData(data)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := store.entries[key]
+	if options, ok := body["options"].(map[string]interface{}); ok && options["cas"] != nil {
+		cas, err := versionNumber(options["cas"])
+		if err != nil {
+			return nil, err
+		}
+		currentVersion := 0
+		if entry != nil {
+			currentVersion = entry.CurrentVersion
+		}
+		if cas != currentVersion {
+			return nil, errors.New(casMismatch)
+		}
+	}
+	if !ok {
+		entry = &kvEntry{Versions: map[int]*kvVersion{}}
+		store.entries[key] = entry
+	}
+	entry.CurrentVersion++
+	stored := &kvVersion{Data: data, CreatedTime: time.Now()}
+	entry.Versions[entry.CurrentVersion] = stored
+	return &api.Secret{Data: stored.metadata(entry.CurrentVersion)}, nil
+}
+
+// undelete restores the deleted versions listed in body: {"versions": [1, 2]}.
+func (store *kvStore) undelete(key string, body map[string]interface{}) error {
+	versions, err := normalizeData(body)
+	if err != nil {
+		return err
+	}
+	versionList, ok := versions["versions"].([]interface{})
+	if !ok || len(versionList) == 0 {
+		return errors.New("no versions provided")
+	}
+	entry, ok := store.entries[key]
+	if !ok {
+		return nil
+	}
+	for _, version := range versionList {
+		n, err := versionNumber(version)
+		if err != nil {
+			return err
+		}
+		if stored, ok := entry.Versions[n]; ok && !stored.Destroyed {
+			stored.DeletionTime = time.Time{}
+		}
+	}
+	return nil
+}
+
+func (store *kvStore) list(path string) (*api.Secret, error) {
+	op, key, err := splitKvPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if op != "metadata" {
+		return nil, fmt.Errorf("unsupported path: %s", path)
+	}
+	prefix := key + "/"
+	found := map[string]bool{}
+	for entryKey := range store.entries {
+		if !strings.HasPrefix(entryKey, prefix) {
+			continue
+		}
+		child := strings.TrimPrefix(entryKey, prefix)
+		if i := strings.Index(child, "/"); i >= 0 {
+			found[child[:i+1]] = true
+		} else {
+			found[child] = true
+		}
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	children := []string{}
+	for child := range found {
+		children = append(children, child)
+	}
+	sort.Strings(children)
+	keys := []interface{}{}
+	for _, child := range children {
+		keys = append(keys, child)
+	}
+	return &api.Secret{Data: map[string]interface{}{"keys": keys}}, nil
+}
+
+func (store *kvStore) metadata(path string) (*api.Secret, error) {
+	op, key, err := splitKvPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if op != "metadata" {
+		return nil, fmt.Errorf("unsupported path: %s", path)
+	}
+	entry, ok := store.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	versions := map[string]interface{}{}
+	var createdTime, updatedTime time.Time
+	for n, stored := range entry.Versions {
+		versionMetadata := stored.metadata(n)
+		delete(versionMetadata, "version")
+		versions[strconv.Itoa(n)] = versionMetadata
+		if createdTime.IsZero() || stored.CreatedTime.Before(createdTime) {
+			createdTime = stored.CreatedTime
+		}
+		if stored.CreatedTime.After(updatedTime) {
+			updatedTime = stored.CreatedTime
+		}
+	}
+	return &api.Secret{Data: map[string]interface{}{
+		"current_version": kvNumber(entry.CurrentVersion),
+		"created_time":    kvTime(createdTime),
+		"updated_time":    kvTime(updatedTime),
+		"versions":        versions,
+	}}, nil
+}
+
+// remove soft deletes the latest version at a data path or every version at a metadata path.
+func (store *kvStore) remove(path string) (*api.Secret, error) {
+	op, key, err := splitKvPath(path)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "data":
+		if entry, ok := store.entries[key]; ok {
+			if stored, ok := entry.Versions[entry.CurrentVersion]; ok && stored.DeletionTime.IsZero() {
+				stored.DeletionTime = time.Now()
+			}
+		}
+	case "metadata":
+		delete(store.entries, key)
+	default:
+		return nil, fmt.Errorf("unsupported path: %s", path)
+	}
+	return nil, nil
+}
+
+// readVersion - the version to read for a version parameter.  0 and "" are the latest.
+func readVersion(version string) (int, error) {
+	if version == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(version)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version: %s", version)
+	}
+	return n, nil
+}
+
+// memoryStorage keeps secrets in memory for the life of the process.
+type memoryStorage struct {
+	mutex sync.Mutex
+	store kvStore
+}
+
+// NewMemoryStorage returns empty storage held in memory.
+func NewMemoryStorage() Storage {
+	return &memoryStorage{store: kvStore{entries: map[string]*kvEntry{}}}
+}
+
+func (ms *memoryStorage) Read(ctx context.Context, path string) (*api.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	return ms.store.read(path, 0)
+}
+
+func (ms *memoryStorage) ReadWithVersion(ctx context.Context, path string, version string) (*api.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	n, err := readVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	return ms.store.read(path, n)
+}
+
+func (ms *memoryStorage) Write(ctx context.Context, path string, data map[string]interface{}) (*api.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	return ms.store.write(path, data)
+}
+
+func (ms *memoryStorage) List(ctx context.Context, path string) (*api.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	return ms.store.list(path)
+}
+
+func (ms *memoryStorage) Metadata(ctx context.Context, path string) (*api.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	return ms.store.metadata(path)
+}
+
+func (ms *memoryStorage) Delete(ctx context.Context, path string) (*api.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	return ms.store.remove(path)
+}
+
+func (ms *memoryStorage) Close() {}
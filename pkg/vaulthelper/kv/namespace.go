@@ -0,0 +1,45 @@
+package kv
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// VaultNamespaceHeader carries the Vault Enterprise namespace a request is for.
+const VaultNamespaceHeader = "X-Vault-Namespace"
+
+// vaultNamespace - the Vault Enterprise namespace vault clients work in.  Empty for the root namespace.
+var vaultNamespace string
+
+// SetVaultNamespace sets the Vault Enterprise namespace, tenant1 or tenants/tenant1, that vault clients
+// created afterwards send their requests to.  Empty for the root namespace.
+func SetVaultNamespace(namespace string) {
+	vaultNamespace = strings.Trim(namespace, "/")
+}
+
+// GetVaultNamespace - the namespace set by SetVaultNamespace.
+func GetVaultNamespace() string {
+	return vaultNamespace
+}
+
+// NewVaultClient creates a client for the vault at address in the namespace set by SetVaultNamespace.
+func NewVaultClient(address string, httpClient *http.Client) (*api.Client, error) {
+	client, err := api.NewClient(&api.Config{Address: address, HttpClient: httpClient})
+	if err != nil {
+		return client, err
+	}
+	if vaultNamespace != "" {
+		client.SetNamespace(vaultNamespace)
+	}
+	return client, nil
+}
+
+// cacheAddress - address qualified by namespace so cached modifiers aren't shared across namespaces.
+func cacheAddress(address string) string {
+	if vaultNamespace == "" {
+		return address
+	}
+	return address + "#" + vaultNamespace
+}
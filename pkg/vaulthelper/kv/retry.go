@@ -0,0 +1,102 @@
+package kv
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// RetryPolicy controls how the context-aware Modifier calls retry failed requests.
+type RetryPolicy struct {
+	MaxRetries     int           // Retries after the first attempt.
+	BaseDelay      time.Duration // Wait before the first retry, doubled for each retry after.
+	MaxDelay       time.Duration // Longest wait between attempts.
+	Jitter         float64       // Fraction of each wait randomized: 0.2 waits 80% to 120% of it.
+	AttemptTimeout time.Duration // Deadline for each attempt.  0 leaves only the caller's deadline.
+}
+
+// DefaultRetryPolicy is used by modifiers without a RetryPolicy of their own.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 5,
+	BaseDelay:  250 * time.Millisecond,
+	MaxDelay:   10 * time.Second,
+	Jitter:     0.2,
+}
+
+// delay - how long to wait before retry number retry, counting from 0.
+func (policy RetryPolicy) delay(retry int) time.Duration {
+	delay := policy.BaseDelay
+	for i := 0; i < retry && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	if policy.Jitter > 0 {
+		delay += time.Duration(float64(delay) * policy.Jitter * (2*rand.Float64() - 1))
+	}
+	return delay
+}
+
+// retryable - true if err is likely transient: dropped connections, timeouts, rate limiting,
+// server errors and standby or sealed nodes.
+func retryable(err error) bool {
+	var responseErr *api.ResponseError
+	if errors.As(err, &responseErr) {
+		return responseErr.StatusCode == 429 || responseErr.StatusCode >= 500 || strings.Contains(responseErr.Error(), "standby")
+	}
+	if errors.Is(err, context.Canceled) || misconfigured(err) {
+		return false
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) || errors.Is(err, io.EOF) || errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err)
+}
+
+// misconfigured - true if err means no attempt can succeed: the server's certificate isn't trusted
+// or its host doesn't exist.
+func misconfigured(err error) bool {
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var verificationErr *tls.CertificateVerificationError
+	var dnsErr *net.DNSError
+	return errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &verificationErr) ||
+		(errors.As(err, &dnsErr) && dnsErr.IsNotFound)
+}
+
+// retry makes request, retrying per the modifier's RetryPolicy until it succeeds, fails with an
+// error not worth retrying, runs out of retries or ctx is done.  Returns the number of retries made.
+func (m *Modifier) retry(ctx context.Context, request func(context.Context) (*api.Secret, error)) (*api.Secret, int, error) {
+	policy := DefaultRetryPolicy
+	if m.RetryPolicy != nil {
+		policy = *m.RetryPolicy
+	}
+	for retries := 0; ; retries++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if policy.AttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.AttemptTimeout)
+		}
+		secret, err := request(attemptCtx)
+		cancel()
+		if err == nil || retries >= policy.MaxRetries || ctx.Err() != nil || !retryable(err) {
+			return secret, retries, err
+		}
+
+		timer := time.NewTimer(policy.delay(retries))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return secret, retries, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
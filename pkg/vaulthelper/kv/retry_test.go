@@ -0,0 +1,96 @@
+package kv
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// flakyStorage fails its first `failures` writes with err, after making them when lost is set.
+type flakyStorage struct {
+	Storage
+	failures int
+	err      error
+	lost     bool
+	writes   int
+}
+
+func (fs *flakyStorage) Write(ctx context.Context, path string, data map[string]interface{}) (*api.Secret, error) {
+	fs.writes++
+	if fs.writes <= fs.failures {
+		if fs.lost {
+			fs.Storage.Write(ctx, path, data)
+		}
+		return nil, fs.err
+	}
+	return fs.Storage.Write(ctx, path, data)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	storage := &flakyStorage{Storage: NewMemoryStorage(), failures: 2, err: &api.ResponseError{StatusCode: 503}}
+	mod := NewModifierWithStorage(storage, "dev", nil)
+	mod.Env = "dev"
+	mod.RetryPolicy = &RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Jitter: 0.5}
+
+	if _, err := mod.WriteWithContext(context.Background(), "super-secrets/Project/Service", map[string]interface{}{"key": "value"}, logger); err != nil || storage.writes != 3 {
+		t.Fatalf("Expected success on the third write, got %d %v", storage.writes, err)
+	}
+
+	// Permission errors aren't retried.
+	storage.writes, storage.err = 0, &api.ResponseError{StatusCode: 403}
+	if _, err := mod.Write("super-secrets/Project/Service", map[string]interface{}{"key": "value"}, logger); err == nil || storage.writes != 1 {
+		t.Fatalf("Expected one failed write, got %d %v", storage.writes, err)
+	}
+
+	// Untrusted certificates and unknown hosts aren't retried.
+	for _, err := range []error{
+		&url.Error{Op: "Put", URL: "https://vault", Err: x509.UnknownAuthorityError{}},
+		&url.Error{Op: "Put", URL: "https://vault", Err: &net.DNSError{Err: "no such host", Name: "vault", IsNotFound: true}},
+	} {
+		storage.writes, storage.err = 0, err
+		if _, err := mod.Write("super-secrets/Project/Service", map[string]interface{}{"key": "value"}, logger); err == nil || storage.writes != 1 {
+			t.Fatalf("Expected one failed write, got %d %v", storage.writes, err)
+		}
+	}
+
+	// Cancellation stops retrying.
+	storage.writes, storage.failures, storage.err = 0, 10, &api.ResponseError{StatusCode: 429}
+	mod.RetryPolicy.BaseDelay, mod.RetryPolicy.MaxDelay = time.Hour, time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := mod.WriteWithContext(ctx, "super-secrets/Project/Service", map[string]interface{}{"key": "value"}, logger); !errors.Is(err, context.DeadlineExceeded) || storage.writes != 1 {
+		t.Fatalf("Expected deadline exceeded after one write, got %d %v", storage.writes, err)
+	}
+}
+
+func TestWriteCASLostResponse(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	storage := &flakyStorage{Storage: NewMemoryStorage(), failures: 1, err: io.EOF, lost: true}
+	mod := NewModifierWithStorage(storage, "dev", nil)
+	mod.Env = "dev"
+	mod.RetryPolicy = &RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond}
+	path := "super-secrets/Project/Service"
+
+	// The first attempt is written but its response lost: the rejected retry isn't a conflict.
+	if _, err := mod.WriteCAS(path, map[string]interface{}{"key": "value"}, 0, logger); err != nil || storage.writes != 2 {
+		t.Fatalf("Expected success on the retry, got %d %v", storage.writes, err)
+	}
+	if data, version, err := mod.ReadDataVersion(path); err != nil || version != 1 || data["key"] != "value" {
+		t.Fatalf("Expected version 1, got %v %d %v", data, version, err)
+	}
+
+	// Other data written in the meantime still conflicts.
+	storage.writes = 0
+	if _, err := mod.WriteCAS(path, map[string]interface{}{"key": "other"}, 0, logger); !IsCASConflict(err) {
+		t.Fatalf("Expected conflict, got %v", err)
+	}
+}
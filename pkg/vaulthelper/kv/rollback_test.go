@@ -0,0 +1,56 @@
+package kv
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestRollbackTo(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	mod := NewModifierWithStorage(NewMemoryStorage(), "dev", nil)
+	mod.Env = "dev"
+	path := "super-secrets/Project/Service"
+
+	if _, err := mod.Write(path, map[string]interface{}{"key": "1"}, logger); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	asOf := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if _, err := mod.Write(path, map[string]interface{}{"key": "2"}, logger); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if version, err := mod.VersionAsOf(path, asOf); err != nil || version != 1 {
+		t.Fatalf("Expected version 1 as of %v, got %d %v", asOf, version, err)
+	}
+	if _, err := mod.RollbackTo(path, 3, 2, logger); err == nil {
+		t.Fatal("Expected rolling back to a missing version to fail")
+	}
+	// Changed since the rollback was reviewed at version 1.
+	if _, err := mod.RollbackTo(path, 1, 1, logger); !IsCASConflict(err) {
+		t.Fatalf("Expected conflict, got %v", err)
+	}
+	if _, err := mod.RollbackTo(path, 1, 2, logger); err != nil {
+		t.Fatalf("RollbackTo failed: %v", err)
+	}
+	data, version, err := mod.ReadDataVersion(path)
+	if err != nil || version != 3 || data["key"] != "1" {
+		t.Fatalf("Expected version 1's data as version 3, got %v %d %v", data, version, err)
+	}
+
+	if _, err := mod.SoftDelete(path, logger); err != nil {
+		t.Fatalf("SoftDelete failed: %v", err)
+	}
+	if data, _ := mod.ReadDataAtVersion(path, 0); data != nil {
+		t.Fatalf("Expected deleted data, got %v", data)
+	}
+	if err := mod.Undelete(path, nil, logger); err != nil {
+		t.Fatalf("Undelete failed: %v", err)
+	}
+	if data, err := mod.ReadDataAtVersion(path, 0); err != nil || data["key"] != "1" {
+		t.Fatalf("Expected restored data, got %v %v", data, err)
+	}
+}
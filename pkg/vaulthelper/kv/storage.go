@@ -0,0 +1,189 @@
+package kv
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// Storage is where a Modifier's secrets are kept.  Paths are full KV v2 logical paths:
+// <engine>/data/<path> to read, write and delete data, <engine>/metadata/<path> to list, read
+// version metadata and delete every version, and <engine>/undelete/<path> to restore deleted
+// versions.  Responses are shaped as vault's are.
+// Requests stop when ctx is done.
+type Storage interface {
+	Read(ctx context.Context, path string) (*api.Secret, error)                               // Latest version.
+	ReadWithVersion(ctx context.Context, path string, version string) (*api.Secret, error)    // A specific version.
+	Write(ctx context.Context, path string, data map[string]interface{}) (*api.Secret, error) // data is the request body: {"data": {...}}
+	List(ctx context.Context, path string) (*api.Secret, error)                               // Keys under path, folders end in "/".
+	Metadata(ctx context.Context, path string) (*api.Secret, error)                           // Versions of path.
+	Delete(ctx context.Context, path string) (*api.Secret, error)                             // Soft deletes data, destroys metadata.
+	Close()
+}
+
+// Addresses for vault-less storage, for hermetic tests and local development.
+const (
+	memoryStoragePrefix = "mem://"  // mem://name -- shared by every modifier in the process using name.
+	fileStoragePrefix   = "file://" // file:///path/to/store.json
+)
+
+// IsLocalStorage - true if address refers to memory or file storage rather than vault.
+func IsLocalStorage(address string) bool {
+	return strings.HasPrefix(address, memoryStoragePrefix) || strings.HasPrefix(address, fileStoragePrefix)
+}
+
+var memoryStoresLock sync.Mutex
+var memoryStores = map[string]Storage{}
+
+// localStorage opens the memory or file storage at address.
+func localStorage(address string) (Storage, error) {
+	if strings.HasPrefix(address, fileStoragePrefix) {
+		return NewFileStorage(strings.TrimPrefix(address, fileStoragePrefix))
+	}
+	name := strings.TrimPrefix(address, memoryStoragePrefix)
+	memoryStoresLock.Lock()
+	defer memoryStoresLock.Unlock()
+	if storage, ok := memoryStores[name]; ok {
+		return storage, nil
+	}
+	storage := NewMemoryStorage()
+	memoryStores[name] = storage
+	return storage, nil
+}
+
+// vaultStorage keeps secrets in vault.
+type vaultStorage struct {
+	mutex      sync.RWMutex // Guards the clients, replaced on failover.
+	httpClient *http.Client // Handle to http client.
+	client     *api.Client  // Client connected to vault
+
+	// Nodes of the vault cluster when several are listed: failed over to when the connected node
+	// goes down.
+	nodes    string
+	insecure bool
+	env      string
+	logger   *log.Logger
+}
+
+// request makes a logical request as api.Logical does, but stopping when ctx is done.  Requests
+// failing because the node is down are retried once on the cluster's new leader.
+func (vs *vaultStorage) request(ctx context.Context, method string, path string, params url.Values, body map[string]interface{}) (*api.Secret, error) {
+	secret, err := vs.nodeRequest(ctx, method, path, params, body)
+	if err != nil && vs.failover(ctx, err) {
+		return vs.nodeRequest(ctx, method, path, params, body)
+	}
+	return secret, err
+}
+
+// vaultClient - the client connected to vault.
+func (vs *vaultStorage) vaultClient() *api.Client {
+	vs.mutex.RLock()
+	defer vs.mutex.RUnlock()
+	return vs.client
+}
+
+// failover connects to the cluster's leader when err means the connected node is down.  True if
+// another node was found.  The leader gets a client of its own, as its TLS settings depend on its
+// address.
+func (vs *vaultStorage) failover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	leader, ok := FailoverAddress(vs.insecure, vs.nodes, vs.env, vs.vaultClient().Address(), err, vs.logger)
+	if !ok {
+		return false
+	}
+	httpClient, err := CreateHTTPClient(vs.insecure, leader, vs.env, false)
+	if err != nil {
+		return false
+	}
+	client, err := NewVaultClient(leader, httpClient)
+	if err != nil {
+		return false
+	}
+	vs.mutex.Lock()
+	client.SetToken(vs.client.Token())
+	failedHttpClient := vs.httpClient
+	vs.httpClient, vs.client = httpClient, client
+	vs.mutex.Unlock()
+	failedHttpClient.CloseIdleConnections()
+	return true
+}
+
+// nodeRequest makes a request of the connected node.
+func (vs *vaultStorage) nodeRequest(ctx context.Context, method string, path string, params url.Values, body map[string]interface{}) (*api.Secret, error) {
+	client := vs.vaultClient()
+	r := client.NewRequest(method, "/v1/"+path)
+	if method == "LIST" {
+		// As api.Logical lists, for broader compatibility.
+		r.Method = "GET"
+		r.Params.Set("list", "true")
+	}
+	for key, values := range params {
+		r.Params[key] = values
+	}
+	if body != nil {
+		if err := r.SetJSONBody(body); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := client.RawRequestWithContext(ctx, r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if resp != nil && resp.StatusCode == 404 {
+		secret, parseErr := api.ParseSecret(resp.Body)
+		switch parseErr {
+		case nil:
+		case io.EOF:
+			return nil, nil
+		default:
+			return nil, err
+		}
+		if secret != nil && (len(secret.Warnings) > 0 || len(secret.Data) > 0) {
+			return secret, nil
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return api.ParseSecret(resp.Body)
+}
+
+func (vs *vaultStorage) Read(ctx context.Context, path string) (*api.Secret, error) {
+	return vs.request(ctx, "GET", path, nil, nil)
+}
+
+func (vs *vaultStorage) ReadWithVersion(ctx context.Context, path string, version string) (*api.Secret, error) {
+	return vs.request(ctx, "GET", path, url.Values{"version": {version}}, nil)
+}
+
+func (vs *vaultStorage) Write(ctx context.Context, path string, data map[string]interface{}) (*api.Secret, error) {
+	return vs.request(ctx, "PUT", path, nil, data)
+}
+
+func (vs *vaultStorage) List(ctx context.Context, path string) (*api.Secret, error) {
+	return vs.request(ctx, "LIST", path, nil, nil)
+}
+
+func (vs *vaultStorage) Metadata(ctx context.Context, path string) (*api.Secret, error) {
+	return vs.request(ctx, "GET", path, nil, nil)
+}
+
+func (vs *vaultStorage) Delete(ctx context.Context, path string) (*api.Secret, error) {
+	return vs.request(ctx, "DELETE", path, nil, nil)
+}
+
+func (vs *vaultStorage) Close() {
+	vs.mutex.RLock()
+	defer vs.mutex.RUnlock()
+	vs.httpClient.CloseIdleConnections()
+}
@@ -0,0 +1,79 @@
+package kv
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/trimble-oss/tierceron/buildopts"
+)
+
+func init() {
+	buildopts.NewOptionsBuilder(buildopts.LoadOptions())
+}
+
+func testStorageRoundTrip(t *testing.T, mod *Modifier) {
+	mod.Env = "dev"
+	for _, value := range []string{"first", "second"} {
+		if _, err := mod.Write("super-secrets/Project/Service", map[string]interface{}{"key": value}, nil); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	data, err := mod.ReadData("super-secrets/Project/Service")
+	if err != nil || data["key"] != "second" {
+		t.Fatalf("Expected second, got %v %v", data, err)
+	}
+	mod.Version = "1"
+	data, err = mod.ReadData("super-secrets/Project/Service")
+	if err != nil || data["key"] != "first" {
+		t.Fatalf("Expected first, got %v %v", data, err)
+	}
+	mod.Version = ""
+
+	versions, err := mod.ReadVersionMetadata("super-secrets/Project/Service", nil)
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %v %v", versions, err)
+	}
+
+	list, err := mod.List("super-secrets/Project", nil)
+	if err != nil || list == nil || len(list.Data["keys"].([]interface{})) != 1 || list.Data["keys"].([]interface{})[0] != "Service" {
+		t.Fatalf("Expected [Service], got %v %v", list, err)
+	}
+	list, err = mod.List("super-secrets", nil)
+	if err != nil || list == nil || list.Data["keys"].([]interface{})[0] != "Project/" {
+		t.Fatalf("Expected [Project/], got %v %v", list, err)
+	}
+
+	if _, err := mod.SoftDelete("super-secrets/Project/Service", nil); err != nil {
+		t.Fatalf("SoftDelete failed: %v", err)
+	}
+	data, err = mod.ReadData("super-secrets/Project/Service")
+	if err != nil || data != nil {
+		t.Fatalf("Expected deleted, got %v %v", data, err)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorageRoundTrip(t, NewModifierWithStorage(NewMemoryStorage(), "dev", nil))
+}
+
+func TestFileStorage(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "store.json")
+	storage, err := NewFileStorage(storePath)
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	testStorageRoundTrip(t, NewModifierWithStorage(storage, "dev", nil))
+
+	// A second process sees what the first wrote.
+	reopened, err := NewFileStorage(storePath)
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	mod := NewModifierWithStorage(reopened, "dev", nil)
+	mod.Env = "dev"
+	versions, err := mod.ReadVersionMetadata("super-secrets/Project/Service", nil)
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %v %v", versions, err)
+	}
+}
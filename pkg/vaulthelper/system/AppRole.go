@@ -182,6 +182,14 @@ func (v *Vault) GetListApproles() (string, error) {
 
 // AppRoleLogin tries logging into the vault using app role and returns a client token on success
 func (v *Vault) AppRoleLogin(roleID string, secretID string) (string, error) {
+	token, err := v.appRoleLogin(roleID, secretID)
+	if err != nil && v.failover(err) {
+		return v.appRoleLogin(roleID, secretID)
+	}
+	return token, err
+}
+
+func (v *Vault) appRoleLogin(roleID string, secretID string) (string, error) {
 	r := v.client.NewRequest("POST", "/v1/auth/approle/login")
 
 	payload := map[string]interface{}{
@@ -0,0 +1,231 @@
+package system
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trimble-oss/tierceron/buildopts/memonly"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// Auth methods AuthConfig.Method supports besides approle.
+const (
+	AuthMethodKubernetes = "kubernetes"
+	AuthMethodJWT        = "jwt"
+	AuthMethodCert       = "cert"
+)
+
+// Where kubernetes mounts a pod's service account token.
+const kubernetesJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Environment variable holding the token for jwt login by default.
+const defaultJWTEnv = "TRC_JWT"
+
+// Cached tokens are replaced this long before they expire.
+const tokenCacheMargin = time.Minute
+
+// AuthConfig configures login through the kubernetes, jwt or cert auth methods.
+type AuthConfig struct {
+	Method     string // kubernetes, jwt or cert.
+	Role       string // Role to log in as.  Optional for cert.
+	Mount      string // Path the auth method is mounted at.  The method's name by default.
+	JWTFile    string // kubernetes: the service account token, its usual location by default.  jwt: read when JWTEnv is unset.
+	JWTEnv     string // jwt: environment variable holding the token, TRC_JWT by default.
+	ClientCert string // cert: client certificate file.
+	ClientKey  string // cert: client key file.
+}
+
+// IsAuthMethod - true if method is one AuthConfig supports.
+func IsAuthMethod(method string) bool {
+	return method == AuthMethodKubernetes || method == AuthMethodJWT || method == AuthMethodCert
+}
+
+// cachedToken is a login token saved for reuse until it expires.
+type cachedToken struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"` // Zero if the token doesn't expire.
+}
+
+func (t *cachedToken) valid() bool {
+	return t != nil && t.Token != "" && (t.Expires.IsZero() || time.Now().Add(tokenCacheMargin).Before(t.Expires))
+}
+
+var tokenCache = map[string]*cachedToken{}
+var tokenCacheLock sync.Mutex
+
+// Login logs in through the auth method in config and returns the client token and how long it
+// lasts, 0 if it doesn't expire.
+func (v *Vault) Login(config AuthConfig) (string, time.Duration, error) {
+	token, ttl, err := v.login(config)
+	if err != nil && v.failover(err) {
+		return v.login(config)
+	}
+	return token, ttl, err
+}
+
+func (v *Vault) login(config AuthConfig) (string, time.Duration, error) {
+	mount := config.Mount
+	if mount == "" {
+		mount = config.Method
+	}
+	payload := map[string]interface{}{}
+	client := v.client
+	switch config.Method {
+	case AuthMethodKubernetes:
+		jwtFile := config.JWTFile
+		if jwtFile == "" {
+			jwtFile = kubernetesJWTFile
+		}
+		jwt, err := os.ReadFile(jwtFile)
+		if err != nil {
+			return "", 0, fmt.Errorf("unable to read service account token: %v", err)
+		}
+		payload["jwt"] = strings.TrimSpace(string(jwt))
+	case AuthMethodJWT:
+		jwtEnv := config.JWTEnv
+		if jwtEnv == "" {
+			jwtEnv = defaultJWTEnv
+		}
+		jwt := os.Getenv(jwtEnv)
+		if jwt == "" && config.JWTFile != "" {
+			jwtBytes, err := os.ReadFile(config.JWTFile)
+			if err != nil {
+				return "", 0, fmt.Errorf("unable to read jwt: %v", err)
+			}
+			jwt = string(jwtBytes)
+		}
+		if jwt = strings.TrimSpace(jwt); jwt == "" {
+			return "", 0, fmt.Errorf("no jwt in %s or a jwt file", jwtEnv)
+		}
+		payload["jwt"] = jwt
+	case AuthMethodCert:
+		certClient, err := v.certClient(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return "", 0, err
+		}
+		client = certClient
+	default:
+		return "", 0, fmt.Errorf("unsupported auth method: %s", config.Method)
+	}
+	if config.Role != "" {
+		if config.Method == AuthMethodCert {
+			payload["name"] = config.Role
+		} else {
+			payload["role"] = config.Role
+		}
+	} else if config.Method != AuthMethodCert {
+		return "", 0, fmt.Errorf("a role is required for %s login", config.Method)
+	}
+	return authLogin(client, mount, payload)
+}
+
+// LoginWithCache returns a token from the auth method in config, logging in only when no token
+// cached for it is still good.  Tokens are cached for the life of the process and, when cacheDir
+// is given, in files there.  Memonly builds keep tokens off disk, so never use cacheDir.
+func (v *Vault) LoginWithCache(config AuthConfig, cacheDir string) (string, error) {
+	key := tokenCacheKey(v.client.Address(), config)
+	tokenCacheLock.Lock()
+	defer tokenCacheLock.Unlock()
+
+	if memonly.IsMemonly() {
+		cacheDir = ""
+	}
+
+	if cached := tokenCache[key]; cached.valid() {
+		return cached.Token, nil
+	}
+	cacheFile := ""
+	if cacheDir != "" {
+		cacheFile = filepath.Join(cacheDir, "token_"+key)
+		if cacheBytes, err := os.ReadFile(cacheFile); err == nil {
+			cached := &cachedToken{}
+			if json.Unmarshal(cacheBytes, cached) == nil && cached.valid() {
+				tokenCache[key] = cached
+				return cached.Token, nil
+			}
+		}
+	}
+
+	token, ttl, err := v.Login(config)
+	if err != nil {
+		return "", err
+	}
+	cached := &cachedToken{Token: token}
+	if ttl > 0 {
+		cached.Expires = time.Now().Add(ttl)
+	}
+	tokenCache[key] = cached
+	if cacheFile != "" {
+		// Unable to cache only costs a login next time.
+		if cacheBytes, err := json.Marshal(cached); err == nil {
+			if os.MkdirAll(cacheDir, 0700) == nil {
+				os.WriteFile(cacheFile, cacheBytes, 0600)
+			}
+		}
+	}
+	return token, nil
+}
+
+// tokenCacheKey - names the cached token for an address, namespace, auth method and role.
+func tokenCacheKey(address string, config AuthConfig) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{address, helperkv.GetVaultNamespace(), config.Method, config.Mount, config.Role, config.ClientCert}, "\n")))
+	return config.Method + "_" + hex.EncodeToString(sum[:8])
+}
+
+// certClient - a copy of this vault's client presenting the client certificate in certFile.
+func (v *Vault) certClient(certFile string, keyFile string) (*api.Client, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("a client certificate and key are required for cert login")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	transport, ok := v.client.CloneConfig().HttpClient.Transport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("unable to present a client certificate")
+	}
+	transport = transport.Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	return helperkv.NewVaultClient(v.client.Address(), &http.Client{Transport: transport})
+}
+
+// authLogin posts payload to the login endpoint of the auth method at mount.
+func authLogin(client *api.Client, mount string, payload map[string]interface{}) (string, time.Duration, error) {
+	r := client.NewRequest("POST", "/v1/auth/"+strings.Trim(mount, "/")+"/login")
+	if err := r.SetJSONBody(payload); err != nil {
+		return "", 0, err
+	}
+
+	response, err := client.RawRequest(r)
+	if response != nil && response.Body != nil {
+		defer response.Body.Close()
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	secret, err := api.ParseSecret(response.Body)
+	if err != nil {
+		return "", 0, err
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return "", 0, fmt.Errorf("error parsing response for key 'auth.client_token'")
+	}
+	return secret.Auth.ClientToken, time.Duration(secret.Auth.LeaseDuration) * time.Second, nil
+}
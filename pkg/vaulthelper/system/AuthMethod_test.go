@@ -0,0 +1,122 @@
+package system
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+)
+
+// stubLogin answers auth method logins, recording each login's path and payload.
+type stubLogin struct {
+	logins   []string
+	payloads []map[string]interface{}
+}
+
+func (stub *stubLogin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload := map[string]interface{}{}
+	json.NewDecoder(r.Body).Decode(&payload)
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		payload["cn"] = r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	stub.logins = append(stub.logins, r.URL.Path)
+	stub.payloads = append(stub.payloads, payload)
+	fmt.Fprintf(w, `{"auth": {"client_token": "token%d", "lease_duration": 3600}}`, len(stub.logins))
+}
+
+func TestLoginWithCache(t *testing.T) {
+	stub := &stubLogin{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+	client, err := helperkv.NewVaultClient(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Vault{client: client}
+	dir := t.TempDir()
+	jwtFile := filepath.Join(dir, "token")
+	os.WriteFile(jwtFile, []byte("service-account-jwt\n"), 0600)
+
+	kubernetes := AuthConfig{Method: AuthMethodKubernetes, Role: "trcsh", JWTFile: jwtFile}
+	token, err := v.LoginWithCache(kubernetes, dir)
+	if err != nil || token != "token1" || stub.logins[0] != "/v1/auth/kubernetes/login" || stub.payloads[0]["jwt"] != "service-account-jwt" || stub.payloads[0]["role"] != "trcsh" {
+		t.Fatalf("Unexpected kubernetes login: %s %v %v %v", token, err, stub.logins, stub.payloads)
+	}
+
+	// Cached in memory, then on disk.
+	if token, err := v.LoginWithCache(kubernetes, dir); err != nil || token != "token1" || len(stub.logins) != 1 {
+		t.Fatalf("Expected the cached token, got %s %v after %d logins", token, err, len(stub.logins))
+	}
+	tokenCacheLock.Lock()
+	tokenCache = map[string]*cachedToken{}
+	tokenCacheLock.Unlock()
+	if token, err := v.LoginWithCache(kubernetes, dir); err != nil || token != "token1" || len(stub.logins) != 1 {
+		t.Fatalf("Expected the token cached on disk, got %s %v after %d logins", token, err, len(stub.logins))
+	}
+
+	t.Setenv("CI_JWT", "ci-jwt")
+	jwt := AuthConfig{Method: AuthMethodJWT, Role: "ci", Mount: "gitlab", JWTEnv: "CI_JWT"}
+	if token, err := v.LoginWithCache(jwt, ""); err != nil || token != "token2" || stub.logins[1] != "/v1/auth/gitlab/login" || stub.payloads[1]["jwt"] != "ci-jwt" {
+		t.Fatalf("Unexpected jwt login: %s %v %v %v", token, err, stub.logins, stub.payloads)
+	}
+	if _, err := v.LoginWithCache(AuthConfig{Method: AuthMethodJWT, JWTEnv: "CI_JWT"}, ""); err == nil {
+		t.Fatal("Expected jwt login without a role to fail")
+	}
+}
+
+func TestCertLogin(t *testing.T) {
+	stub := &stubLogin{}
+	server := httptest.NewUnstartedServer(stub)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+	client, err := helperkv.NewVaultClient(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Vault{client: client}
+
+	// A self signed client certificate.
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "runner"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	token, ttl, err := v.Login(AuthConfig{Method: AuthMethodCert, Role: "runners", ClientCert: certFile, ClientKey: keyFile})
+	if err != nil || token != "token1" || ttl != time.Hour || stub.logins[0] != "/v1/auth/cert/login" || stub.payloads[0]["name"] != "runners" || stub.payloads[0]["cn"] != "runner" {
+		t.Fatalf("Unexpected cert login: %s %v %v %v %v", token, ttl, err, stub.logins, stub.payloads)
+	}
+}
@@ -0,0 +1,280 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+)
+
+// TokenState is reported to TokenManagerConfig.OnState as the token's lifecycle progresses.
+type TokenState int
+
+const (
+	TokenActive          TokenState = iota // Token looked up; it doesn't expire or its ttl is known.
+	TokenRenewed                           // Token renewed.
+	TokenReauthenticated                   // Renewal no longer possible; a new token was obtained.
+	TokenFailed                            // Lookup, renewal or reauthentication failed.  Retried.
+)
+
+func (state TokenState) String() string {
+	switch state {
+	case TokenActive:
+		return "active"
+	case TokenRenewed:
+		return "renewed"
+	case TokenReauthenticated:
+		return "reauthenticated"
+	case TokenFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("TokenState(%d)", int(state))
+}
+
+// TokenEvent describes a change in a managed token's state.
+type TokenEvent struct {
+	State TokenState
+	TTL   time.Duration // Remaining ttl.  0 if the token doesn't expire or on failure.
+	Err   error
+}
+
+// TokenManagerConfig configures a TokenManager.
+type TokenManagerConfig struct {
+	Insecure bool
+	Address  string
+	Env      string
+	Token    string
+
+	// RenewFraction is the fraction of the token's ttl that passes before it is renewed.  Default 2/3.
+	RenewFraction float64
+	// Increment is the ttl in seconds requested on renewal.  0 requests the token's default.
+	Increment int
+	// RetryInterval is how long to wait after a failure.  Default 30 seconds.
+	RetryInterval time.Duration
+	// Reauth obtains a new token once renewal is no longer possible, typically through AppRoleLogin.
+	// Without Reauth the token is renewed until it reaches its max ttl.
+	Reauth func() (string, error)
+	// OnState is called with each change in the token's state.  Optional.
+	OnState func(TokenEvent)
+
+	Log *log.Logger
+}
+
+// tokenVault is the part of Vault the manager uses.
+type tokenVault interface {
+	GetTokenTTL() (time.Duration, bool, error)
+	RenewSelf(increment int) error
+	SetToken(token string)
+}
+
+// tokenHolder is anything connecting to vault with the managed token, such as a Modifier.
+type tokenHolder interface {
+	SetToken(token string)
+}
+
+// TokenManager renews a token in the background before it expires and reauthenticates when it can
+// no longer be renewed.  New tokens are set on every added holder and on cached modifiers.
+type TokenManager struct {
+	config TokenManagerConfig
+	vault  tokenVault
+
+	mutex   sync.Mutex
+	token   string
+	holders []tokenHolder
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewTokenManager creates a manager for config.Token.  Call Start to begin renewing.
+func NewTokenManager(config TokenManagerConfig) (*TokenManager, error) {
+	if config.Token == "" || config.Address == "" {
+		return nil, errors.New("token and address are required")
+	}
+	address, err := helperkv.ResolveAddress(config.Insecure, config.Address, config.Env, config.Log)
+	if err != nil {
+		return nil, err
+	}
+	vault := &Vault{insecure: config.Insecure, env: config.Env, logger: config.Log}
+	if helperkv.IsMultiAddress(config.Address) {
+		vault.nodes = config.Address
+	}
+	if vault.httpClient, vault.client, err = vault.connect(address, false); err != nil {
+		return nil, err
+	}
+	vault.client.SetToken(config.Token)
+	return newTokenManager(config, vault), nil
+}
+
+func newTokenManager(config TokenManagerConfig, vault tokenVault) *TokenManager {
+	if config.RenewFraction <= 0 || config.RenewFraction >= 1 {
+		config.RenewFraction = 2.0 / 3.0
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = 30 * time.Second
+	}
+	if config.Log == nil {
+		config.Log = log.New(io.Discard, "", 0)
+	}
+	return &TokenManager{config: config, vault: vault, token: config.Token}
+}
+
+// Token returns the current token.
+func (tm *TokenManager) Token() string {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	return tm.token
+}
+
+// AddModifier sets the current token on mod now and on every token change until removed.
+func (tm *TokenManager) AddModifier(mod *helperkv.Modifier) {
+	tm.addHolder(mod)
+}
+
+// RemoveModifier stops updating mod's token.
+func (tm *TokenManager) RemoveModifier(mod *helperkv.Modifier) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	for i, holder := range tm.holders {
+		if holder == tokenHolder(mod) {
+			tm.holders = append(tm.holders[:i], tm.holders[i+1:]...)
+			return
+		}
+	}
+}
+
+func (tm *TokenManager) addHolder(holder tokenHolder) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	holder.SetToken(tm.token)
+	tm.holders = append(tm.holders, holder)
+}
+
+// Start begins renewing in the background.
+func (tm *TokenManager) Start() {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	if tm.done != nil {
+		return
+	}
+	tm.done = make(chan struct{})
+	tm.wg.Add(1)
+	go tm.run(tm.done)
+}
+
+// Stop ends renewal.  The token is left as is.
+func (tm *TokenManager) Stop() {
+	tm.mutex.Lock()
+	done := tm.done
+	tm.done = nil
+	tm.mutex.Unlock()
+	if done != nil {
+		close(done)
+		tm.wg.Wait()
+	}
+}
+
+func (tm *TokenManager) run(done chan struct{}) {
+	defer tm.wg.Done()
+	wait, ok := tm.lookup()
+	for ok {
+		select {
+		case <-done:
+			return
+		case <-time.After(wait):
+		}
+		wait, ok = tm.renew()
+	}
+}
+
+// lookup reads the token's ttl, returning how long to wait before renewing.  Returns false
+// when the token doesn't expire and there's nothing to manage.
+func (tm *TokenManager) lookup() (time.Duration, bool) {
+	ttl, _, err := tm.vault.GetTokenTTL()
+	if err != nil {
+		tm.report(TokenEvent{State: TokenFailed, Err: err})
+		return tm.config.RetryInterval, true
+	}
+	tm.report(TokenEvent{State: TokenActive, TTL: ttl})
+	return tm.renewWait(ttl)
+}
+
+// renewWait - how long to wait before renewing a token with ttl remaining.
+func (tm *TokenManager) renewWait(ttl time.Duration) (time.Duration, bool) {
+	if ttl == 0 {
+		return 0, false
+	}
+	return time.Duration(float64(ttl) * tm.config.RenewFraction), true
+}
+
+// renew renews the token, reauthenticating when renewal no longer extends it.  Returns how long
+// to wait before renewing again.
+func (tm *TokenManager) renew() (time.Duration, bool) {
+	ttl, renewable, err := tm.vault.GetTokenTTL()
+	if err != nil {
+		tm.report(TokenEvent{State: TokenFailed, Err: err})
+		return tm.config.RetryInterval, true
+	}
+	if ttl == 0 {
+		tm.report(TokenEvent{State: TokenActive})
+		return 0, false
+	}
+	if renewable {
+		if err = tm.vault.RenewSelf(tm.config.Increment); err == nil {
+			var renewedTTL time.Duration
+			if renewedTTL, _, err = tm.vault.GetTokenTTL(); err == nil && renewedTTL > ttl {
+				tm.report(TokenEvent{State: TokenRenewed, TTL: renewedTTL})
+				return tm.renewWait(renewedTTL)
+			}
+			// At its max ttl.
+		}
+	}
+	if tm.config.Reauth == nil {
+		if err == nil {
+			err = errors.New("token can no longer be renewed")
+		}
+		tm.report(TokenEvent{State: TokenFailed, TTL: ttl, Err: err})
+		return tm.config.RetryInterval, true
+	}
+
+	token, err := tm.config.Reauth()
+	if err != nil {
+		tm.report(TokenEvent{State: TokenFailed, TTL: ttl, Err: fmt.Errorf("unable to reauthenticate: %v", err)})
+		return tm.config.RetryInterval, true
+	}
+	tm.setToken(token, time.Now().Add(ttl))
+	ttl, _, err = tm.vault.GetTokenTTL()
+	if err != nil {
+		tm.report(TokenEvent{State: TokenFailed, Err: err})
+		return tm.config.RetryInterval, true
+	}
+	tm.report(TokenEvent{State: TokenReauthenticated, TTL: ttl})
+	return tm.renewWait(ttl)
+}
+
+// setToken swaps token in for the current token, which expires at oldExpires, everywhere it's used.
+func (tm *TokenManager) setToken(token string, oldExpires time.Time) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	oldToken := tm.token
+	tm.token = token
+	tm.vault.SetToken(token)
+	for _, holder := range tm.holders {
+		holder.SetToken(token)
+	}
+	helperkv.SwapCachedToken(tm.config.Address, oldToken, token, oldExpires)
+}
+
+func (tm *TokenManager) report(event TokenEvent) {
+	if event.Err != nil {
+		tm.config.Log.Printf("Token %s: %v\n", event.State, event.Err)
+	} else {
+		tm.config.Log.Printf("Token %s, ttl %s\n", event.State, event.TTL)
+	}
+	if tm.config.OnState != nil {
+		tm.config.OnState(event)
+	}
+}
@@ -0,0 +1,137 @@
+package system
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trimble-oss/tierceron/buildopts/coreopts"
+)
+
+type fakeTokenVault struct {
+	token     string
+	ttl       time.Duration
+	maxTTL    time.Duration
+	renewable bool
+}
+
+func (v *fakeTokenVault) GetTokenTTL() (time.Duration, bool, error) {
+	return v.ttl, v.renewable, nil
+}
+
+func (v *fakeTokenVault) RenewSelf(increment int) error {
+	if v.ttl+time.Minute > v.maxTTL {
+		v.ttl = v.maxTTL
+	} else {
+		v.ttl += time.Minute
+	}
+	return nil
+}
+
+func (v *fakeTokenVault) SetToken(token string) {
+	v.token = token
+	v.ttl = time.Hour
+	v.maxTTL = 2 * time.Hour
+}
+
+type fakeTokenHolder struct {
+	token string
+}
+
+func (h *fakeTokenHolder) SetToken(token string) {
+	h.token = token
+}
+
+func TestTokenManagerRenewAndReauth(t *testing.T) {
+	vault := &fakeTokenVault{token: "first", ttl: time.Hour, maxTTL: 2 * time.Hour, renewable: true}
+	states := []TokenState{}
+	reauths := 0
+	tm := newTokenManager(TokenManagerConfig{
+		Address: "https://127.0.0.1:8200",
+		Token:   "first",
+		Reauth: func() (string, error) {
+			reauths++
+			if reauths > 1 {
+				return "", errors.New("approle login failed")
+			}
+			return "second", nil
+		},
+		OnState: func(event TokenEvent) { states = append(states, event.State) },
+	}, vault)
+	holder := &fakeTokenHolder{}
+	tm.addHolder(holder)
+	if holder.token != "first" {
+		t.Fatalf("Expected first, got %s", holder.token)
+	}
+
+	if wait, ok := tm.lookup(); !ok || wait != 40*time.Minute {
+		t.Fatalf("Expected 40m, got %s %t", wait, ok)
+	}
+	if _, ok := tm.renew(); !ok || vault.ttl != time.Hour+time.Minute {
+		t.Fatalf("Expected renewal, got ttl %s", vault.ttl)
+	}
+
+	// At its max ttl the token is replaced.
+	vault.ttl = vault.maxTTL
+	if _, ok := tm.renew(); !ok || tm.Token() != "second" || holder.token != "second" || vault.token != "second" {
+		t.Fatalf("Expected second, got %s %s %s", tm.Token(), holder.token, vault.token)
+	}
+
+	// Failed reauthentication is retried.
+	vault.renewable = false
+	if wait, ok := tm.renew(); !ok || wait != 30*time.Second || tm.Token() != "second" {
+		t.Fatalf("Expected retry, got %s %t %s", wait, ok, tm.Token())
+	}
+
+	expected := []TokenState{TokenActive, TokenRenewed, TokenReauthenticated, TokenFailed}
+	if len(states) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, states)
+	}
+	for i := range expected {
+		if states[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, states)
+		}
+	}
+}
+
+// stubNode serves sys/health with status and token lookups with ttl.
+func stubNode(status *int, ttl string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sys/health":
+			w.WriteHeader(*status)
+		case "/v1/auth/token/lookup-self":
+			w.Write([]byte(`{"data": {"ttl": ` + ttl + `, "renewable": true}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestTokenManagerFailover(t *testing.T) {
+	if coreopts.BuildOptions == nil {
+		coreopts.NewOptionsBuilder(coreopts.LoadOptions())
+	}
+	active, standby := http.StatusOK, http.StatusTooManyRequests
+	nodeA := stubNode(&active, "60")
+	defer nodeA.Close()
+	nodeB := stubNode(&standby, "120")
+	defer nodeB.Close()
+
+	tokens, err := NewTokenManager(TokenManagerConfig{Address: nodeA.URL + "," + nodeB.URL, Env: "dev", Token: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _, err := tokens.vault.GetTokenTTL(); err != nil || ttl != time.Minute {
+		t.Fatalf("Expected a minute from a, got %v %v", ttl, err)
+	}
+
+	// A goes away and B takes over.
+	nodeA.Close()
+	standby = http.StatusOK
+	if ttl, _, err := tokens.vault.GetTokenTTL(); err != nil || ttl != 2*time.Minute {
+		t.Fatalf("Expected failover to b, got %v %v", ttl, err)
+	}
+}
@@ -21,6 +21,14 @@ type Vault struct {
 	httpClient *http.Client // Handle to http client.
 	client     *api.Client  // Client connected to vault
 	shards     []string     // Master key shards used to unseal vault
+
+	// Nodes of the vault cluster when several are listed: failed over to when the connected node
+	// goes down.
+	nodes         string
+	insecure      bool
+	env           string
+	allowNonLocal bool
+	logger        *log.Logger
 }
 
 // KeyTokenWrapper Contains the unseal keys and root token
@@ -43,26 +51,39 @@ func NewVault(insecure bool, address string, env string, newVault bool, pingVaul
 
 // NewVault Constructs a new vault at the given address with the given access token allowing insecure for non local.
 func NewVaultWithNonlocal(insecure bool, address string, env string, newVault bool, pingVault bool, scanVault bool, allowNonLocal bool, logger *log.Logger) (*Vault, error) {
-	var httpClient *http.Client
 	var err error
+	nodes := ""
 
-	if allowNonLocal {
-		httpClient, err = helperkv.CreateHTTPClientAllowNonLocal(insecure, address, env, scanVault, true)
-	} else {
-		httpClient, err = helperkv.CreateHTTPClient(insecure, address, env, scanVault)
+	if helperkv.IsMultiAddress(address) {
+		if newVault {
+			// Nothing is active before initialization.
+			address = helperkv.SplitAddresses(address)[0]
+		} else {
+			nodes = address
+			if address, err = helperkv.ResolveAddress(insecure, nodes, env, logger); err != nil {
+				logger.Println("Connection to vault couldn't be made - " + err.Error())
+				return nil, err
+			}
+		}
 	}
 
-	if err != nil {
-		logger.Println("Connection to vault couldn't be made - vaultHost: " + address)
+	v := &Vault{
+		shards:        nil,
+		nodes:         nodes,
+		insecure:      insecure,
+		env:           env,
+		allowNonLocal: allowNonLocal,
+		logger:        logger,
+	}
+	if v.httpClient, v.client, err = v.connect(address, scanVault); err != nil {
 		return nil, err
 	}
-	client, err := api.NewClient(&api.Config{Address: address, HttpClient: httpClient})
+
+	rootClient, err := v.rootClient()
 	if err != nil {
-		logger.Println("vaultHost: " + address)
 		return nil, err
 	}
-
-	health, err := client.Sys().Health()
+	health, err := rootClient.Sys().Health()
 	if err != nil {
 		return nil, err
 	}
@@ -77,9 +98,51 @@ func NewVaultWithNonlocal(insecure bool, address string, env string, newVault bo
 		return nil, errors.New("Vault is sealed at " + address)
 	}
 
-	return &Vault{
-		client: client,
-		shards: nil}, err
+	return v, err
+}
+
+// connect creates the clients for the vault node at address.
+func (v *Vault) connect(address string, scanVault bool) (*http.Client, *api.Client, error) {
+	var httpClient *http.Client
+	var err error
+	if v.allowNonLocal {
+		httpClient, err = helperkv.CreateHTTPClientAllowNonLocal(v.insecure, address, v.env, scanVault, true)
+	} else {
+		httpClient, err = helperkv.CreateHTTPClient(v.insecure, address, v.env, scanVault)
+	}
+	if err != nil {
+		if v.logger != nil {
+			v.logger.Println("Connection to vault couldn't be made - vaultHost: " + address)
+		}
+		return nil, nil, err
+	}
+	client, err := helperkv.NewVaultClient(address, httpClient)
+	if err != nil {
+		if v.logger != nil {
+			v.logger.Println("vaultHost: " + address)
+		}
+		return nil, nil, err
+	}
+	return httpClient, client, nil
+}
+
+// failover connects to the cluster's leader when err means the connected node is down.  True if
+// another node was found.
+func (v *Vault) failover(err error) bool {
+	leader, ok := helperkv.FailoverAddress(v.insecure, v.nodes, v.env, v.client.Address(), err, v.logger)
+	if !ok {
+		return false
+	}
+	httpClient, client, connectErr := v.connect(leader, false)
+	if connectErr != nil {
+		return false
+	}
+	client.SetToken(v.client.Token())
+	if v.httpClient != nil {
+		v.httpClient.CloseIdleConnections()
+	}
+	v.httpClient, v.client = httpClient, client
+	return true
 }
 
 // Confirms we have a valid and active connection to vault.  If it doesn't, it re-establishes a new connection.
@@ -93,7 +156,7 @@ func (v *Vault) RefreshClient() error {
 				v.httpClient.CloseIdleConnections()
 			}
 
-			client, err := api.NewClient(&api.Config{Address: v.client.Address(), HttpClient: v.client.CloneConfig().HttpClient})
+			client, err := helperkv.NewVaultClient(v.client.Address(), v.client.CloneConfig().HttpClient)
 			if err != nil {
 				refreshErr = err
 			} else {
@@ -110,6 +173,58 @@ func (v *Vault) RefreshClient() error {
 	return refreshErr
 }
 
+// rootClient - a client for the root namespace, which alone serves health, init and unseal.
+func (v *Vault) rootClient() (*api.Client, error) {
+	if v.client.Headers().Get(helperkv.VaultNamespaceHeader) == "" {
+		return v.client, nil
+	}
+	return v.namespaceClient("")
+}
+
+// namespaceClient - a copy of this vault's client working in namespace, "" for the root namespace.
+func (v *Vault) namespaceClient(namespace string) (*api.Client, error) {
+	client, err := v.client.Clone()
+	if err != nil {
+		return nil, err
+	}
+	headers := client.Headers()
+	if headers != nil {
+		headers.Del(helperkv.VaultNamespaceHeader)
+		client.SetHeaders(headers)
+	}
+	if namespace != "" {
+		client.SetNamespace(namespace)
+	}
+	client.SetToken(v.client.Token())
+	return client, nil
+}
+
+// CreateNamespace creates the Vault Enterprise namespace, tenant1 or tenants/tenant1, along with
+// any parents missing.  Namespaces that exist are left alone.  Requires a root namespace token.
+func (v *Vault) CreateNamespace(namespace string) error {
+	parent := ""
+	for _, name := range strings.Split(strings.Trim(namespace, "/"), "/") {
+		client, err := v.namespaceClient(parent)
+		if err != nil {
+			return err
+		}
+		existing, err := client.Logical().Read("sys/namespaces/" + name)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			if _, err := client.Logical().Write("sys/namespaces/"+name, nil); err != nil {
+				return err
+			}
+		}
+		if parent != "" {
+			parent += "/"
+		}
+		parent += name
+	}
+	return nil
+}
+
 // SetToken Stores the access token for this vault
 func (v *Vault) SetToken(token string) {
 	v.client.SetToken(token)
@@ -142,9 +257,36 @@ func (v *Vault) RevokeSelf() error {
 // RenewSelf Renews the token associated with this vault struct
 func (v *Vault) RenewSelf(increment int) error {
 	_, err := v.client.Auth().Token().RenewSelf(increment)
+	if err != nil && v.failover(err) {
+		_, err = v.client.Auth().Token().RenewSelf(increment)
+	}
 	return err
 }
 
+// GetTokenTTL returns the remaining ttl of the token associated with this vault struct and whether it can be renewed.
+// A ttl of 0 means the token doesn't expire.
+func (v *Vault) GetTokenTTL() (time.Duration, bool, error) {
+	secret, err := v.client.Auth().Token().LookupSelf()
+	if err != nil && v.failover(err) {
+		secret, err = v.client.Auth().Token().LookupSelf()
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if secret == nil {
+		return 0, false, errors.New("no token data returned")
+	}
+	ttl, err := secret.TokenTTL()
+	if err != nil {
+		return 0, false, err
+	}
+	renewable, err := secret.TokenIsRenewable()
+	if err != nil {
+		return 0, false, err
+	}
+	return ttl, renewable, nil
+}
+
 // GetOrRevokeTokensInScope()
 func (v *Vault) GetOrRevokeTokensInScope(dir string, tokenFilter string, tokenExpiration bool, logger *log.Logger) error {
 	var tokenPath = dir
@@ -330,7 +472,11 @@ func (v *Vault) InitVault(keyShares int, keyThreshold int) (*KeyTokenWrapper, er
 		SecretShares:    keyShares,
 		SecretThreshold: keyThreshold}
 
-	response, err := v.client.Sys().Init(&request)
+	rootClient, err := v.rootClient()
+	if err != nil {
+		return nil, err
+	}
+	response, err := rootClient.Sys().Init(&request)
 	if err != nil {
 		fmt.Println("There was an error with initializing vault @ " + v.client.Address())
 		return nil, err
@@ -446,9 +592,12 @@ func (v *Vault) AddShard(shard string) {
 // Unseal Performs an unseal wuth this vault's shard. Returns true if unseal is successful
 func (v *Vault) Unseal() (int, int, bool, error) {
 	var status *api.SealStatusResponse
-	var err error
+	rootClient, err := v.rootClient()
+	if err != nil {
+		return 0, 0, false, err
+	}
 	for _, shard := range v.shards {
-		status, err = v.client.Sys().Unseal(shard)
+		status, err = rootClient.Sys().Unseal(shard)
 		if err != nil {
 			return 0, 0, false, err
 		}
@@ -550,7 +699,10 @@ func (v *Vault) CreateTokenFromMap(data map[string]interface{}) (string, error)
 
 // GetStatus checks the health of the vault and retrieves version and status of init/seal
 func (v *Vault) GetStatus() (map[string]interface{}, error) {
-	health, err := v.client.Sys().Health()
+	health, err := v.health()
+	if err != nil && v.failover(err) {
+		health, err = v.health()
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -561,6 +713,14 @@ func (v *Vault) GetStatus() (map[string]interface{}, error) {
 	}, nil
 }
 
+func (v *Vault) health() (*api.HealthResponse, error) {
+	rootClient, err := v.rootClient()
+	if err != nil {
+		return nil, err
+	}
+	return rootClient.Sys().Health()
+}
+
 // Proper shutdown of modifier.
 func (v *Vault) Close() {
 	if v.httpClient != nil {
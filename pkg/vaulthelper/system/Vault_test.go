@@ -0,0 +1,68 @@
+package system
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+)
+
+func TestCreateNamespace(t *testing.T) {
+	var mutex sync.Mutex
+	namespaces := map[string]bool{"tenants": true}
+	created := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		namespace := r.Header.Get(helperkv.VaultNamespaceHeader)
+		name := namespace + "/" + r.URL.Path[len("/v1/sys/namespaces/"):]
+		if namespace == "" {
+			name = name[1:]
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if !namespaces[name] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"data": {"path": "` + name + `/"}}`))
+		default:
+			namespaces[name] = true
+			created = append(created, name)
+			w.Write([]byte(`{"data": {"path": "` + name + `/"}}`))
+		}
+	}))
+	defer server.Close()
+
+	helperkv.SetVaultNamespace("/tenants/tenant1/")
+	defer helperkv.SetVaultNamespace("")
+	client, err := helperkv.NewVaultClient(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if namespace := client.Headers().Get(helperkv.VaultNamespaceHeader); namespace != "tenants/tenant1" {
+		t.Fatalf("Expected namespace tenants/tenant1, got %s", namespace)
+	}
+
+	v := &Vault{client: client}
+	if err := v.CreateNamespace(helperkv.GetVaultNamespace()); err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0] != "tenants/tenant1" {
+		t.Fatalf("Expected tenants/tenant1 created in tenants, got %v", created)
+	}
+	if err := v.CreateNamespace(helperkv.GetVaultNamespace()); err != nil || len(created) != 1 {
+		t.Fatalf("Expected existing namespaces left alone, got %v %v", created, err)
+	}
+
+	// Init and unseal go to the root namespace.
+	rootClient, err := v.rootClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rootClient.Headers()[helperkv.VaultNamespaceHeader]; ok {
+		t.Fatal("Expected no namespace on the root client")
+	}
+}
@@ -1,52 +1,61 @@
 import com.sun.jna.*;
-import java.util.*;
+import com.sun.jna.ptr.*;
 
+// Renders a template and a certificate through nc.so.  Build nc.so with make xlib;
+// the C declarations are in nc.h.
 public class Client {
-  public interface TemplatePopulator extends Library {
-        public class GoString extends Structure {
-            public static class ByValue extends GoString implements Structure.ByValue {}
-            public String p;
-            public long n;
-            protected List getFieldOrder(){
-                return Arrays.asList(new String[]{"p","n"});
-            }
-
-        }
-    public String ConfigTemplateLib(GoString.ByValue token, GoString.ByValue address, GoString.ByValue certPath, GoString.ByValue env, GoString.ByValue templatePath, GoString.ByValue configuredFilePath, boolean secretMode, GoString.ByValue servicesWanted);
+  public interface NcLib extends Library {
+    int NC_OK = 0;
+
+    int NcOpenSession(String token, String address, String env, LongByReference session, PointerByReference errorMessage);
+    int NcRenderTemplate(long session, String templatePath, String project, String service, PointerByReference result, PointerByReference errorMessage);
+    int NcRenderCert(long session, String templatePath, String project, String service, PointerByReference result, PointerByReference errorMessage);
+    int NcListTemplates(long session, String project, String service, PointerByReference result, PointerByReference errorMessage);
+    int NcGetValuesJson(long session, String project, String service, PointerByReference result, PointerByReference errorMessage);
+    int NcCloseSession(long session);
+    void FreeString(Pointer s);
   }
-  static public void main(String argv[]) {
-    //String[] services = {"ST/"};
-    TemplatePopulator populator = (TemplatePopulator) Native.loadLibrary(
-      "./templatePopulator.so", TemplatePopulator.class);
-
-    TemplatePopulator.GoString.ByValue services = new TemplatePopulator.GoString.ByValue();
-    services.p = "ServiceName/";
-    services.n = services.p.length();
-
-    TemplatePopulator.GoString.ByValue token = new TemplatePopulator.GoString.ByValue();
-    token.p = "<randomtoken>";
-    token.n = token.p.length();
-
-    TemplatePopulator.GoString.ByValue addr = new TemplatePopulator.GoString.ByValue();
-    addr.p = "https://<vaulthostandport>";
-    addr.n = addr.p.length();
-
-    TemplatePopulator.GoString.ByValue certPath = new TemplatePopulator.GoString.ByValue();
-    certPath.p = "~/workspace/ServiceDir/certs/cert_files/serv_cert.pem";
-    certPath.n = certPath.p.length();
 
-    TemplatePopulator.GoString.ByValue tempDir = new TemplatePopulator.GoString.ByValue();
-    tempDir.p = "~/workspace/ServiceDir/trc_templates/ST/hibernate.properties.tmpl";
-    tempDir.n = tempDir.p.length();
-
-    TemplatePopulator.GoString.ByValue endDir = new TemplatePopulator.GoString.ByValue();
-    endDir.p = "~/workspace/ServiceDir/config_files/ST/hibernate.properties";
-    endDir.n = endDir.p.length();
+  static NcLib nc = Native.load("./nc.so", NcLib.class);
+
+  // Copies a returned string and frees the library's copy.
+  static String take(PointerByReference ref) {
+    Pointer p = ref.getValue();
+    if (p == null) {
+      return null;
+    }
+    String s = p.getString(0, "UTF-8");
+    nc.FreeString(p);
+    return s;
+  }
 
-    TemplatePopulator.GoString.ByValue env = new TemplatePopulator.GoString.ByValue();
-    env.p = "dev";
-    env.n = env.p.length();
+  static String check(int status, PointerByReference result, PointerByReference errorMessage) {
+    if (status != NcLib.NC_OK) {
+      throw new RuntimeException("nc error " + status + ": " + take(errorMessage));
+    }
+    return take(result);
+  }
 
-    System.out.printf(populator.ConfigTemplateLib(token, addr, certPath, tempDir, endDir, env, false, services));
+  static public void main(String argv[]) {
+    LongByReference session = new LongByReference();
+    PointerByReference errorMessage = new PointerByReference();
+    check(nc.NcOpenSession("<randomtoken>", "https://<vaulthostandport>", "dev", session, errorMessage), null, errorMessage);
+
+    try {
+      PointerByReference result = new PointerByReference();
+      System.out.println(check(nc.NcListTemplates(session.getValue(), "ProjectName", "ServiceName", result, errorMessage), result, errorMessage));
+
+      result = new PointerByReference();
+      System.out.println(check(nc.NcRenderTemplate(session.getValue(), "trc_templates/ProjectName/ServiceName/hibernate.properties.tmpl", "ProjectName", "ServiceName", result, errorMessage), result, errorMessage));
+
+      result = new PointerByReference();
+      System.out.println(check(nc.NcGetValuesJson(session.getValue(), "ProjectName", "ServiceName", result, errorMessage), result, errorMessage));
+
+      result = new PointerByReference();
+      String certBase64 = check(nc.NcRenderCert(session.getValue(), "trc_templates/ProjectName/ServiceName/certs/cert.pfx.mf.tmpl", "ProjectName", "ServiceName", result, errorMessage), result, errorMessage);
+      System.out.println("cert: " + certBase64.length() + " base64 characters");
+    } finally {
+      nc.NcCloseSession(session.getValue());
+    }
   }
 }
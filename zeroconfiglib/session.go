@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	vcutils "github.com/trimble-oss/tierceron/pkg/cli/trcconfigbase/utils"
+	"github.com/trimble-oss/tierceron/pkg/core"
+	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
+	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
+	"github.com/trimble-oss/tierceron/pkg/vaulthelper/system"
+)
+
+// Status codes returned across the C ABI.  Keep in sync with the enum in templatePopulator.go.
+const (
+	ncOk          = 0
+	ncErrArgument = 1 // Missing or malformed argument.
+	ncErrHandle   = 2 // Unknown or closed session.
+	ncErrVault    = 3 // Vault unreachable or the token was refused.
+	ncErrNotFound = 4 // Template or values not found.
+	ncErrRender   = 5 // Template failed to render.
+	ncErrInternal = 6 // Unexpected failure, including recovered panics.
+)
+
+const ncLibraryVersion = "1.21"
+
+// ncError carries the status code a failure is reported with.
+type ncError struct {
+	code int
+	err  error
+}
+
+func (e *ncError) Error() string {
+	return e.err.Error()
+}
+
+func newNcError(code int, format string, args ...interface{}) error {
+	return &ncError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// errorCode - the status code for err.  Errors not raised by the library are internal.
+func errorCode(err error) int {
+	if err == nil {
+		return ncOk
+	}
+	var ncErr *ncError
+	if errors.As(err, &ncErr) {
+		return ncErr.code
+	}
+	return ncErrInternal
+}
+
+// ncSession is an open connection to vault for one environment.  The modifier is reused
+// by every call on the session, so calls on a session are serialized.
+type ncSession struct {
+	mutex        sync.Mutex
+	mod          *helperkv.Modifier
+	tokens       *system.TokenManager
+	driverConfig *eUtils.DriverConfig
+}
+
+var sessionsLock sync.Mutex
+var sessions = map[uint64]*ncSession{}
+var nextSession uint64 = 1
+
+// openSession connects to vault at address and returns the new session's handle.
+func openSession(token string, address string, env string) (uint64, error) {
+	if token == "" || address == "" || env == "" {
+		return 0, newNcError(ncErrArgument, "token, address and env are required")
+	}
+	logger := log.New(os.Stdout, "[nc]", log.LstdFlags)
+	logger.Println("NCLib Version: " + ncLibraryVersion)
+
+	mod, err := helperkv.NewModifier(false, token, address, env, nil, true, logger)
+	if err != nil {
+		return 0, newNcError(ncErrVault, "unable to connect to %s: %v", address, err)
+	}
+	envVersion := eUtils.SplitEnv(env)
+	mod.Env = envVersion[0]
+	if len(envVersion) > 1 {
+		mod.Version = envVersion[1]
+	}
+	// Sessions opened with a token can only renew it.
+	tokens, err := system.NewTokenManager(system.TokenManagerConfig{Address: address, Env: envVersion[0], Token: token, Log: logger})
+	if err != nil {
+		mod.Close()
+		return 0, newNcError(ncErrVault, "unable to connect to %s: %v", address, err)
+	}
+	tokens.AddModifier(mod)
+	tokens.Start()
+
+	session := &ncSession{
+		mod:    mod,
+		tokens: tokens,
+		driverConfig: &eUtils.DriverConfig{
+			CoreConfig: core.CoreConfig{
+				Log: logger,
+			},
+			Token:        token,
+			VaultAddress: address,
+			Env:          env,
+			ZeroConfig:   true,
+			StartDir:     []string{"trc_templates"},
+		},
+	}
+
+	sessionsLock.Lock()
+	handle := nextSession
+	nextSession++
+	sessions[handle] = session
+	sessionsLock.Unlock()
+	return handle, nil
+}
+
+// closeSession releases the session's modifier.  The handle is invalid afterwards.
+func closeSession(handle uint64) error {
+	sessionsLock.Lock()
+	session, ok := sessions[handle]
+	delete(sessions, handle)
+	sessionsLock.Unlock()
+	if !ok {
+		return newNcError(ncErrHandle, "unknown session: %d", handle)
+	}
+	session.tokens.Stop()
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	session.mod.Close()
+	return nil
+}
+
+// withSession runs call with handle's session locked.  Panics are reported as internal errors.
+func withSession(handle uint64, call func(session *ncSession) (string, error)) (result string, err error) {
+	sessionsLock.Lock()
+	session, ok := sessions[handle]
+	sessionsLock.Unlock()
+	if !ok {
+		return "", newNcError(ncErrHandle, "unknown session: %d", handle)
+	}
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = "", newNcError(ncErrInternal, "%v", r)
+		}
+	}()
+	// Template paths are set per call by ConfigTemplate.
+	session.mod.TemplatePath = ""
+	return call(session)
+}
+
+// checkTemplatePath - templatePath must be under a <prefix>_templates folder: trc_templates/Project/Service/config.yml.tmpl
+func checkTemplatePath(templatePath string, project string, service string) error {
+	if project == "" || service == "" {
+		return newNcError(ncErrArgument, "project and service are required")
+	}
+	if !strings.Contains(templatePath, "_templates/") || !strings.HasSuffix(templatePath, ".tmpl") {
+		return newNcError(ncErrArgument, "template path must be a .tmpl under a templates folder: %s", templatePath)
+	}
+	return nil
+}
+
+// templateError - the status code for a ConfigTemplate failure.
+func templateError(templatePath string, err error) error {
+	if strings.Contains(err.Error(), "Trouble with lookup") || strings.Contains(err.Error(), "not found") {
+		return &ncError{code: ncErrNotFound, err: fmt.Errorf("%s: %v", templatePath, err)}
+	}
+	return &ncError{code: ncErrRender, err: fmt.Errorf("%s: %v", templatePath, err)}
+}
+
+// renderTemplate renders the template at templatePath from the session's environment.
+func renderTemplate(handle uint64, templatePath string, project string, service string) (string, error) {
+	if err := checkTemplatePath(templatePath, project, service); err != nil {
+		return "", err
+	}
+	return withSession(handle, func(session *ncSession) (string, error) {
+		session.driverConfig.CoreConfig.WantCerts = false
+		configuredTemplate, _, _, err := vcutils.ConfigTemplate(session.driverConfig, session.mod, templatePath, true, project, service, false, true)
+		if err != nil {
+			return "", templateError(templatePath, err)
+		}
+		return configuredTemplate, nil
+	})
+}
+
+// renderCert returns the base64 encoded certificate configured by the template at templatePath.
+func renderCert(handle uint64, templatePath string, project string, service string) (string, error) {
+	if err := checkTemplatePath(templatePath, project, service); err != nil {
+		return "", err
+	}
+	return withSession(handle, func(session *ncSession) (string, error) {
+		session.driverConfig.CoreConfig.WantCerts = true
+		_, configuredCert, _, err := vcutils.ConfigTemplate(session.driverConfig, session.mod, templatePath, true, project, service, true, true)
+		if err != nil {
+			return "", templateError(templatePath, err)
+		}
+		if len(configuredCert) < 2 || configuredCert[1] == "" {
+			return "", newNcError(ncErrNotFound, "no certificate configured by %s", templatePath)
+		}
+		return base64.StdEncoding.EncodeToString([]byte(configuredCert[1])), nil
+	})
+}
+
+// listTemplates returns a JSON array of the template paths seeded for project/service.
+func listTemplates(handle uint64, project string, service string) (string, error) {
+	if project == "" || service == "" {
+		return "", newNcError(ncErrArgument, "project and service are required")
+	}
+	return withSession(handle, func(session *ncSession) (string, error) {
+		templatePaths, err := session.mod.GetTemplateFilePaths("templates/"+project+"/"+service+"/", session.driverConfig.CoreConfig.Log)
+		if err != nil {
+			return "", newNcError(ncErrNotFound, "%v", err)
+		}
+		templates := []string{}
+		for _, templatePath := range templatePaths {
+			templatePath = strings.TrimSuffix(strings.TrimPrefix(templatePath, "templates/"), "/")
+			if templatePath != project+"/"+service {
+				templates = append(templates, templatePath)
+			}
+		}
+		if len(templates) == 0 {
+			return "", newNcError(ncErrNotFound, "no templates found for %s/%s", project, service)
+		}
+		sort.Strings(templates)
+		return marshalResult(templates)
+	})
+}
+
+// getValues returns a JSON object of every value, secrets included, resolved for project/service.
+func getValues(handle uint64, project string, service string) (string, error) {
+	if project == "" || service == "" {
+		return "", newNcError(ncErrArgument, "project and service are required")
+	}
+	return withSession(handle, func(session *ncSession) (string, error) {
+		session.driverConfig.CoreConfig.WantCerts = false
+		values, err := vcutils.ExportValues(session.driverConfig, session.mod, project, service, "")
+		if err != nil {
+			return "", newNcError(ncErrNotFound, "%v", err)
+		}
+		return marshalResult(values)
+	})
+}
+
+func marshalResult(result interface{}) (string, error) {
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", newNcError(ncErrInternal, "%v", err)
+	}
+	return string(resultBytes), nil
+}
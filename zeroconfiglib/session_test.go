@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSessionLifecycle(t *testing.T) {
+	if _, err := openSession("", "https://127.0.0.1:8200", "dev"); errorCode(err) != ncErrArgument {
+		t.Fatalf("Expected %d, got %v", ncErrArgument, err)
+	}
+
+	handle, err := openSession("token", "https://127.0.0.1:8200", "dev")
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if _, err := renderTemplate(handle, "config.yml", "Project", "Service"); errorCode(err) != ncErrArgument {
+		t.Fatalf("Expected %d, got %v", ncErrArgument, err)
+	}
+	if _, err := getValues(handle, "Project", ""); errorCode(err) != ncErrArgument {
+		t.Fatalf("Expected %d, got %v", ncErrArgument, err)
+	}
+	if err := closeSession(handle); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+
+	// Closed sessions can't be used or closed again.
+	if _, err := renderTemplate(handle, "trc_templates/Project/Service/config.yml.tmpl", "Project", "Service"); errorCode(err) != ncErrHandle {
+		t.Fatalf("Expected %d, got %v", ncErrHandle, err)
+	}
+	if _, err := listTemplates(handle, "Project", "Service"); errorCode(err) != ncErrHandle {
+		t.Fatalf("Expected %d, got %v", ncErrHandle, err)
+	}
+	if err := closeSession(handle); errorCode(err) != ncErrHandle {
+		t.Fatalf("Expected %d, got %v", ncErrHandle, err)
+	}
+}
+
+func TestWithSessionRecovers(t *testing.T) {
+	handle, err := openSession("token", "https://127.0.0.1:8200", "dev")
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	defer closeSession(handle)
+
+	_, err = withSession(handle, func(session *ncSession) (string, error) {
+		panic("boom")
+	})
+	if errorCode(err) != ncErrInternal {
+		t.Fatalf("Expected %d, got %v", ncErrInternal, err)
+	}
+	if errorCode(errors.New("unclassified")) != ncErrInternal || errorCode(nil) != ncOk {
+		t.Fatal("Unexpected error code mapping")
+	}
+}
@@ -1,82 +1,136 @@
 package main
 
-import (
-	"C"
-
-	"encoding/base64"
+/*
+#include <stdint.h>
+#include <stdlib.h>
+
+// Status codes returned by the Nc* functions.
+enum {
+	NC_OK = 0,
+	NC_ERR_ARGUMENT = 1,
+	NC_ERR_HANDLE = 2,
+	NC_ERR_VAULT = 3,
+	NC_ERR_NOT_FOUND = 4,
+	NC_ERR_RENDER = 5,
+	NC_ERR_INTERNAL = 6
+};
+*/
+import "C"
 
-	vcutils "github.com/trimble-oss/tierceron/pkg/cli/trcconfigbase/utils"
-	eUtils "github.com/trimble-oss/tierceron/pkg/utils"
-	helperkv "github.com/trimble-oss/tierceron/pkg/vaulthelper/kv"
-)
 import (
 	"log"
 	"os"
+	"unsafe"
 
-	"github.com/trimble-oss/tierceron/pkg/core"
+	"github.com/trimble-oss/tierceron/buildopts/coreopts"
 )
 
-//export ConfigTemplateLib
-func ConfigTemplateLib(token string, address string, env string, templatePath string, configuredFilePath string, project string, service string) *C.char {
-	logger := log.New(os.Stdout, "[ConfigTemplateLib]", log.LstdFlags)
-
-	logger.Println("NCLib Version: " + "1.20")
-	mod, err := helperkv.NewModifier(false, token, address, env, nil, true, logger)
-	mod.Env = env
-	driverConfig := &eUtils.DriverConfig{
-		CoreConfig: core.CoreConfig{
-			WantCerts: false,
-			Log:       logger,
-		},
-		ZeroConfig: true,
-		StartDir:   append([]string{}, "trc_templates"),
-		Insecure:   false,
-	}
+// Shared libraries don't run main.
+func init() {
+	coreopts.NewOptionsBuilder(coreopts.LoadOptions())
+}
+
+// Every Nc* function returns a status code.  Strings returned through result and errorMessage
+// belong to the caller and must be released with FreeString.  Either may be NULL when not wanted.
 
+// setResult hands result and err to the caller, returning err's status code.
+func setResult(result string, err error, resultOut **C.char, errorMessage **C.char) C.int {
 	if err != nil {
-		eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+		if errorMessage != nil {
+			*errorMessage = C.CString(err.Error())
+		}
+		return C.int(errorCode(err))
 	}
+	if resultOut != nil {
+		*resultOut = C.CString(result)
+	}
+	return C.NC_OK
+}
 
-	configuredTemplate, _, _, err := vcutils.ConfigTemplate(driverConfig, mod, templatePath, true, project, service, false, true)
-	if err != nil {
-		eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+//export NcOpenSession
+func NcOpenSession(token *C.char, address *C.char, env *C.char, session *C.uint64_t, errorMessage **C.char) C.int {
+	if session == nil {
+		return setResult("", newNcError(ncErrArgument, "session is required"), nil, errorMessage)
+	}
+	handle, err := openSession(C.GoString(token), C.GoString(address), C.GoString(env))
+	if err == nil {
+		*session = C.uint64_t(handle)
 	}
+	return setResult("", err, nil, errorMessage)
+}
 
-	mod.Close()
+//export NcRenderTemplate
+func NcRenderTemplate(session C.uint64_t, templatePath *C.char, project *C.char, service *C.char, result **C.char, errorMessage **C.char) C.int {
+	configured, err := renderTemplate(uint64(session), C.GoString(templatePath), C.GoString(project), C.GoString(service))
+	return setResult(configured, err, result, errorMessage)
+}
 
+//export NcRenderCert
+func NcRenderCert(session C.uint64_t, templatePath *C.char, project *C.char, service *C.char, result **C.char, errorMessage **C.char) C.int {
+	cert, err := renderCert(uint64(session), C.GoString(templatePath), C.GoString(project), C.GoString(service))
+	return setResult(cert, err, result, errorMessage)
+}
+
+//export NcListTemplates
+func NcListTemplates(session C.uint64_t, project *C.char, service *C.char, result **C.char, errorMessage **C.char) C.int {
+	templates, err := listTemplates(uint64(session), C.GoString(project), C.GoString(service))
+	return setResult(templates, err, result, errorMessage)
+}
+
+//export NcGetValuesJson
+func NcGetValuesJson(session C.uint64_t, project *C.char, service *C.char, result **C.char, errorMessage **C.char) C.int {
+	values, err := getValues(uint64(session), C.GoString(project), C.GoString(service))
+	return setResult(values, err, result, errorMessage)
+}
+
+//export NcCloseSession
+func NcCloseSession(session C.uint64_t) C.int {
+	return C.int(errorCode(closeSession(uint64(session))))
+}
+
+//export FreeString
+func FreeString(s *C.char) {
+	if s != nil {
+		C.free(unsafe.Pointer(s))
+	}
+}
+
+// ConfigTemplateLib - deprecated: use NcOpenSession and NcRenderTemplate.  Returns "" on failure.
+// The result must be released with FreeString.
+//
+//export ConfigTemplateLib
+func ConfigTemplateLib(token string, address string, env string, templatePath string, configuredFilePath string, project string, service string) *C.char {
+	configuredTemplate, err := singleCall(token, address, env, func(handle uint64) (string, error) {
+		return renderTemplate(handle, templatePath, project, service)
+	})
+	if err != nil {
+		log.New(os.Stdout, "[ConfigTemplateLib]", log.LstdFlags).Println(err)
+	}
 	return C.CString(configuredTemplate)
 }
 
+// ConfigCertLib - deprecated: use NcOpenSession and NcRenderCert.  Returns "" on failure.
+// The result must be released with FreeString.
+//
 //export ConfigCertLib
 func ConfigCertLib(token string, address string, env string, templatePath string, configuredFilePath string, project string, service string) *C.char {
-	logger := log.New(os.Stdout, "[ConfigTemplateLib]", log.LstdFlags)
-	logger.Println("NCLib Version: " + "1.20")
-	mod, err := helperkv.NewModifier(false, token, address, env, nil, true, logger)
-	mod.Env = env
-	driverConfig := &eUtils.DriverConfig{
-		CoreConfig: core.CoreConfig{
-			WantCerts: true,
-			Log:       logger,
-		},
-
-		ZeroConfig: true,
-		StartDir:   append([]string{}, "trc_templates"),
-		Insecure:   false,
-	}
+	certBase64, err := singleCall(token, address, env, func(handle uint64) (string, error) {
+		return renderCert(handle, templatePath, project, service)
+	})
 	if err != nil {
-		eUtils.LogErrorMessage(&driverConfig.CoreConfig, err.Error(), false)
-		return C.CString("")
+		log.New(os.Stdout, "[ConfigCertLib]", log.LstdFlags).Println(err)
 	}
-	_, configuredCert, _, err := vcutils.ConfigTemplate(driverConfig, mod, templatePath, true, project, service, true, true)
+	return C.CString(certBase64)
+}
+
+// singleCall runs call in a session opened just for it.
+func singleCall(token string, address string, env string, call func(handle uint64) (string, error)) (string, error) {
+	handle, err := openSession(token, address, env)
 	if err != nil {
-		eUtils.LogErrorObject(&driverConfig.CoreConfig, err, false)
+		return "", err
 	}
-
-	mod.Close()
-
-	certBase64 := base64.StdEncoding.EncodeToString([]byte(configuredCert[1]))
-
-	return C.CString(certBase64)
+	defer closeSession(handle)
+	return call(handle)
 }
 
 func main() {}